@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/encryption"
+)
+
+// runDecryptRecord implements `gateway decrypt-record`, which decrypts the
+// value of an encrypted record with its data key, exported from the secret
+// store into a file, and writes the plaintext to stdout
+func runDecryptRecord(args []string) error {
+	fs := flag.NewFlagSet("decrypt-record", flag.ExitOnError)
+	keyFile := fs.String("key-file", "", "File holding the data key named by the record's frkr-encryption-key-id header, raw or base64 (required)")
+	streamID := fs.String("stream-id", "", "ID of the stream the record was published to (required)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gateway decrypt-record --key-file <file> --stream-id <id> [file]")
+		fmt.Fprintln(fs.Output(), "Reads the raw record value from file, or stdin when omitted.")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *keyFile == "" || *streamID == "" {
+		return fmt.Errorf("--key-file and --stream-id are required")
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return fmt.Errorf("expected at most one file")
+	}
+
+	secret, err := os.ReadFile(*keyFile)
+	if err != nil {
+		return err
+	}
+	key, err := encryption.ParseKey(secret)
+	if err != nil {
+		return fmt.Errorf("invalid data key: %w", err)
+	}
+
+	in := os.Stdin
+	if fs.NArg() == 1 {
+		if in, err = os.Open(fs.Arg(0)); err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+	value, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	plaintext, err := encryption.Decrypt(key, value, []byte(*streamID))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt record: %w", err)
+	}
+	_, err = os.Stdout.Write(plaintext)
+	return err
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/har"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/server"
+)
+
+// runImportHAR implements `gateway import-har`, which uploads a HAR file to a
+// running gateway's POST /ingest/har endpoint so its entries go through the
+// normal authenticated ingest pipeline
+func runImportHAR(args []string) error {
+	fs := flag.NewFlagSet("import-har", flag.ExitOnError)
+	stream := fs.String("stream", "", "Stream to publish the entries to (required)")
+	client := registerClientFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "Parse the file and list its entries without uploading it")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gateway import-har --stream <stream> [flags] <file.har>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one HAR file")
+	}
+	path := fs.Arg(0)
+
+	if *dryRun {
+		return listHAR(path)
+	}
+
+	if *stream == "" {
+		return fmt.Errorf("--stream is required")
+	}
+	if err := client.init(); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	resp, err := client.post(ctx, "/ingest/har?stream="+url.QueryEscape(*stream), nil, f)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	var result server.HARImportResult
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if json.Unmarshal(body, &result) != nil || result.Stream == "" {
+		return fmt.Errorf("import failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	for _, failure := range result.Failures {
+		fmt.Printf("entry %d\t%d\t%s\n", failure.Entry, failure.Status, failure.Message)
+	}
+	fmt.Printf("\n%d entries, %d published, %d failed\n", result.Entries, result.Published, len(result.Failures))
+	if len(result.Failures) > 0 {
+		return fmt.Errorf("%d entries could not be published", len(result.Failures))
+	}
+	return nil
+}
+
+// listHAR prints the entries of a HAR file
+func listHAR(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	harLog, err := har.Parse(f)
+	if err != nil {
+		return err
+	}
+	invalid := 0
+	for i := range harLog.Entries {
+		entry := &harLog.Entries[i]
+		if _, err := entry.MirroredRequest(); err != nil {
+			invalid++
+			fmt.Printf("%d\t%s\t%s\t%s\tinvalid: %v\n", i, entry.StartedDateTime.Format(time.RFC3339), entry.Request.Method, entry.Request.URL, err)
+			continue
+		}
+		fmt.Printf("%d\t%s\t%s\t%s\t%d\n", i, entry.StartedDateTime.Format(time.RFC3339), entry.Request.Method, entry.Request.URL, entry.Response.Status)
+	}
+	fmt.Printf("\n%d entries, %d invalid\n", len(harLog.Entries), invalid)
+	return nil
+}
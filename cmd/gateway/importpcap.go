@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/pcap"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/server"
+)
+
+// runImportPCAP implements `gateway import-pcap`, which reassembles the
+// HTTP/1.x requests in a pcap or pcapng capture and publishes them to a stream
+// through a running gateway's POST /ingest endpoint
+func runImportPCAP(args []string) error {
+	fs := flag.NewFlagSet("import-pcap", flag.ExitOnError)
+	stream := fs.String("stream", "", "Stream to publish the requests to (required unless --dry-run)")
+	client := registerClientFlags(fs)
+	hosts := fs.String("host", "", "Comma-separated Host header names or server IPs to import (default all)")
+	ports := fs.String("port", "", "Comma-separated server ports to import (default all)")
+	limit := fs.Int("limit", 0, "Maximum number of requests to publish (0 for no limit)")
+	dryRun := fs.Bool("dry-run", false, "Summarize the requests found without publishing them")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: gateway import-pcap --stream <stream> [flags] <capture.pcap|capture.pcapng>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one capture file")
+	}
+
+	filter := pcap.Filter{Hosts: splitComma(*hosts)}
+	for _, p := range splitComma(*ports) {
+		port, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid --port %q", p)
+		}
+		filter.Ports = append(filter.Ports, uint16(port))
+	}
+	if !*dryRun {
+		if *stream == "" {
+			return fmt.Errorf("--stream is required")
+		}
+		if err := client.init(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	all, summary, err := pcap.ReadRequests(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	var requests []*pcap.Request
+	for _, req := range all {
+		if filter.Matches(req) {
+			requests = append(requests, req)
+		}
+	}
+	if *limit > 0 && len(requests) > *limit {
+		requests = requests[:*limit]
+	}
+
+	fmt.Printf("%d packets, %d TCP segments, %d streams (%d truncated), %d HTTP requests, %d selected\n",
+		summary.Packets, summary.Segments, summary.Streams, summary.Truncated, summary.Requests, len(requests))
+	if *dryRun {
+		printPCAPSummary(requests)
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Captured timestamps are historical; mark the requests as imported so
+	// the gateway keeps them
+	header := http.Header{server.HeaderImport: {"true"}}
+	published, failed := 0, 0
+	for _, req := range requests {
+		body, err := json.Marshal(&capture.IngestRequest{StreamID: *stream, Request: req.MirroredRequest()})
+		if err != nil {
+			return err
+		}
+		resp, err := client.post(ctx, "/ingest", header, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("failed to publish: %w", err)
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusAccepted:
+			published++
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusNotFound:
+			// Every other request would fail the same way
+			return fmt.Errorf("publish failed: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+		default:
+			failed++
+			fmt.Printf("%s\t%s %s\t%s: %s\n", req.Timestamp.Format(time.RFC3339Nano), req.HTTP.Method, req.HTTP.URL, resp.Status, strings.TrimSpace(string(msg)))
+		}
+	}
+	fmt.Printf("\n%d published, %d failed\n", published, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d requests could not be published", failed)
+	}
+	return nil
+}
+
+// printPCAPSummary prints the time range and request counts by server and method
+func printPCAPSummary(requests []*pcap.Request) {
+	if len(requests) == 0 {
+		return
+	}
+	fmt.Printf("%s to %s\n\n", requests[0].Timestamp.Format(time.RFC3339Nano), requests[len(requests)-1].Timestamp.Format(time.RFC3339Nano))
+
+	counts := map[string]int{}
+	for _, req := range requests {
+		counts[fmt.Sprintf("%s\t%s\t%s", req.Host(), req.Server, req.HTTP.Method)]++
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("%d\t%s\n", counts[k], k)
+	}
+}
+
+// splitComma splits a comma-separated flag value
+func splitComma(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,18 +1,38 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	gwcommon "github.com/frkr-io/frkr-common/gateway"
 	"github.com/frkr-io/frkr-common/plugins"
 	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/config"
 )
 
 func main() {
+	// Subcommands are dispatched before flag parsing; the bare binary runs the gateway
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		if err := runSubcommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	ingestCfg := config.RegisterFlags(flag.CommandLine)
 	cfg, err := gwcommon.LoadConfigFromFlags()
 	if err != nil {
 		log.Fatal(err)
 	}
+	if err := config.LoadConfig(ingestCfg); err != nil {
+		log.Fatal(err)
+	}
+	if err := ingestCfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
 
 	db, err := gwcommon.ConnectGatewayDB(cfg)
 	if err != nil {
@@ -34,7 +54,7 @@ func main() {
 	log.Println("Using CompositeAuthPlugin (Basic + OIDC)")
 	authPlugin := plugins.NewCompositeAuthPlugin(basicAuth, oidcAuth)
 
-	gw, err := gateway.NewIngestGateway(authPlugin, secretPlugin)
+	gw, err := gateway.NewIngestGateway(authPlugin, secretPlugin, gateway.WithIngestConfig(ingestCfg))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -43,3 +63,19 @@ func main() {
 		log.Fatalf("Gateway failed: %v", err)
 	}
 }
+
+// runSubcommand runs an operator subcommand such as `gateway redrive-dlq`
+func runSubcommand(name string, args []string) error {
+	switch name {
+	case "redrive-dlq":
+		return runRedriveDLQ(args)
+	case "import-har":
+		return runImportHAR(args)
+	case "import-pcap":
+		return runImportPCAP(args)
+	case "decrypt-record":
+		return runDecryptRecord(args)
+	default:
+		return fmt.Errorf("unknown subcommand: %s", name)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	gwcommon "github.com/frkr-io/frkr-common/gateway"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/deadletter"
+	"github.com/segmentio/kafka-go"
+)
+
+// runRedriveDLQ implements `gateway redrive-dlq`, which lists dead letters and
+// re-publishes them to the topic they were originally destined for
+func runRedriveDLQ(args []string) error {
+	fs := flag.NewFlagSet("redrive-dlq", flag.ExitOnError)
+	brokerURL := fs.String("broker-url", "", "Broker URL (can use BROKER_URL env var instead)")
+	topic := fs.String("dead-letter-topic", "", "Dead-letter topic to read (can use DEAD_LETTER_TOPIC env var instead)")
+	stream := fs.String("stream", "", "Only re-drive dead letters for this stream")
+	reason := fs.String("reason", "", "Only re-drive dead letters with this reason (publish_failed, message_too_large, validation_failed)")
+	since := fs.String("since", "", "Only re-drive dead letters newer than this (RFC3339 timestamp or duration such as 24h)")
+	limit := fs.Int("limit", 0, "Maximum number of dead letters to process (0 for no limit)")
+	dryRun := fs.Bool("dry-run", false, "List matching dead letters without re-driving them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *brokerURL == "" {
+		*brokerURL = os.Getenv("BROKER_URL")
+	}
+	if *topic == "" {
+		*topic = os.Getenv("DEAD_LETTER_TOPIC")
+	}
+	if *brokerURL == "" || *topic == "" {
+		return fmt.Errorf("--broker-url and --dead-letter-topic are required")
+	}
+
+	filter := deadletter.Filter{StreamID: *stream, Reason: *reason}
+	if *since != "" {
+		t, err := parseSince(*since)
+		if err != nil {
+			return err
+		}
+		filter.Since = t
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	writer := gwcommon.NewBrokerWriter(&gwcommon.GatewayBaseConfig{BrokerURL: *brokerURL})
+	defer writer.Close()
+
+	summary, err := deadletter.Redrive(ctx, writer, *brokerURL, *topic, filter, *limit, *dryRun, func(msg kafka.Message, rec *deadletter.Record) error {
+		fmt.Printf("%d/%d\t%s\t%s\t%s\t%s\n", msg.Partition, msg.Offset, rec.FailedAt.Format(time.RFC3339), rec.StreamID, rec.Reason, rec.Error)
+		return nil
+	})
+	if summary != nil {
+		action, count := "re-driven", summary.Redriven
+		if *dryRun {
+			action, count = "would be re-driven", summary.Matched-summary.Unredrivable
+		}
+		fmt.Printf("\n%d matched, %d %s, %d not re-drivable\n", summary.Matched, count, action, summary.Unredrivable)
+	}
+	return err
+}
+
+// parseSince accepts either an RFC3339 timestamp or a duration relative to now
+func parseSince(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since %q: expected RFC3339 timestamp or duration", s)
+	}
+	return time.Now().Add(-d), nil
+}
@@ -0,0 +1,109 @@
+// Package apierror defines the JSON error model of the ingest API: stable
+// error codes, the HTTP status and retry advice for each, and the errors auth
+// plugins return so authentication failures map to a code.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error codes
+const (
+	// CodeInvalidRequest is a request that could not be decoded or validated
+	CodeInvalidRequest = "invalid_request"
+	// CodeMethodNotAllowed is a request with an unsupported HTTP method
+	CodeMethodNotAllowed = "method_not_allowed"
+	// CodeAuthMissing is a request without credentials
+	CodeAuthMissing = "auth_missing"
+	// CodeAuthInvalid is a request whose credentials were rejected
+	CodeAuthInvalid = "auth_invalid"
+	// CodeAuthForbidden is an authenticated caller without write access to the stream
+	CodeAuthForbidden = "auth_forbidden"
+	// CodeStreamNotFound is a request for a stream that does not exist
+	CodeStreamNotFound = "stream_not_found"
+	// CodeDurabilityNotAllowed is a durability mode outside the stream's bounds
+	CodeDurabilityNotAllowed = "durability_not_allowed"
+	// CodePayloadTooLarge is a request above one of the size limits
+	CodePayloadTooLarge = "payload_too_large"
+	// CodeTopicMissing is a stream whose topic does not exist while topic
+	// auto-creation is disabled
+	CodeTopicMissing = "topic_missing"
+	// CodeServiceUnavailable is a gateway whose dependencies are not ready
+	CodeServiceUnavailable = "service_unavailable"
+	// CodeBrokerUnavailable is a record the broker did not accept
+	CodeBrokerUnavailable = "broker_unavailable"
+	// CodeInternal is any other failure
+	CodeInternal = "internal_error"
+)
+
+var statuses = map[string]int{
+	CodeInvalidRequest:       http.StatusBadRequest,
+	CodeMethodNotAllowed:     http.StatusMethodNotAllowed,
+	CodeAuthMissing:          http.StatusUnauthorized,
+	CodeAuthInvalid:          http.StatusUnauthorized,
+	CodeAuthForbidden:        http.StatusForbidden,
+	CodeStreamNotFound:       http.StatusNotFound,
+	CodeDurabilityNotAllowed: http.StatusBadRequest,
+	CodePayloadTooLarge:      http.StatusRequestEntityTooLarge,
+	CodeTopicMissing:         http.StatusConflict,
+	CodeServiceUnavailable:   http.StatusServiceUnavailable,
+	CodeBrokerUnavailable:    http.StatusServiceUnavailable,
+	CodeInternal:             http.StatusInternalServerError,
+}
+
+// Status returns the HTTP status of an error code, 500 for unknown codes
+func Status(code string) int {
+	if status, ok := statuses[code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Retryable reports whether the same request may succeed if sent again later
+func Retryable(code string) bool {
+	return code == CodeServiceUnavailable || code == CodeBrokerUnavailable
+}
+
+// Error is the JSON body of an error response
+type Error struct {
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Retryable  bool   `json:"retryable"`
+	RequestID  string `json:"request_id,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"` // Seconds, for retryable errors
+	Limit      string `json:"limit,omitempty"`       // Exceeded limit, for payload_too_large
+	Max        int64  `json:"max,omitempty"`
+
+	// Fields lists the invalid fields of an invalid_request, when known
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError is a validation failure of one request field
+type FieldError struct {
+	Field   string `json:"field"` // JSON path, e.g. request.method
+	Message string `json:"message"`
+}
+
+// Errors auth plugins wrap so their failures map to an error code
+var (
+	ErrAuthMissing   = errors.New("missing credentials")
+	ErrAuthInvalid   = errors.New("invalid credentials")
+	ErrAuthForbidden = errors.New("access denied")
+)
+
+// AuthCode returns the error code for a failed authentication or
+// authorization, from the sentinel error it wraps. Errors that wrap none are
+// not auth failures, e.g. a misconfigured plugin, and map to CodeInternal.
+func AuthCode(err error) string {
+	switch {
+	case errors.Is(err, ErrAuthMissing):
+		return CodeAuthMissing
+	case errors.Is(err, ErrAuthForbidden):
+		return CodeAuthForbidden
+	case errors.Is(err, ErrAuthInvalid):
+		return CodeAuthInvalid
+	default:
+		return CodeInternal
+	}
+}
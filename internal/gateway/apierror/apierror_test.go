@@ -0,0 +1,43 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	assert.Equal(t, http.StatusNotFound, Status(CodeStreamNotFound))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, Status(CodePayloadTooLarge))
+	assert.Equal(t, http.StatusServiceUnavailable, Status(CodeBrokerUnavailable))
+	assert.Equal(t, http.StatusForbidden, Status(CodeAuthForbidden))
+	assert.Equal(t, http.StatusInternalServerError, Status("unknown"))
+}
+
+func TestRetryable(t *testing.T) {
+	assert.True(t, Retryable(CodeBrokerUnavailable))
+	assert.True(t, Retryable(CodeServiceUnavailable))
+	assert.False(t, Retryable(CodeInvalidRequest))
+	assert.False(t, Retryable(CodeAuthInvalid))
+}
+
+func TestAuthCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"wrapped missing", fmt.Errorf("authentication failed: %w", fmt.Errorf("%w: no bearer token", ErrAuthMissing)), CodeAuthMissing},
+		{"wrapped invalid", fmt.Errorf("authentication failed: %w", ErrAuthInvalid), CodeAuthInvalid},
+		{"wrapped forbidden", ErrAuthForbidden, CodeAuthForbidden},
+		{"unclassified", errors.New("access denied: missing Authorization header"), CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, AuthCode(tt.err))
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/frkr-io/frkr-common/plugins"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
 )
 
 // TrustedHeaderAuthPlugin trusts that the request was authenticated by an upstream gateway (Envoy)
@@ -27,11 +28,11 @@ func NewTrustedHeaderAuthPlugin(db *sql.DB) *TrustedHeaderAuthPlugin {
 func (p *TrustedHeaderAuthPlugin) ValidateRequest(ctx context.Context, r *http.Request, secretPlugin plugins.SecretPlugin) (*plugins.AuthResult, error) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader == "" {
-		return nil, fmt.Errorf("missing Authorization header")
+		return nil, fmt.Errorf("%w: missing Authorization header", apierror.ErrAuthMissing)
 	}
 
 	if !strings.HasPrefix(authHeader, "Bearer ") {
-		return nil, fmt.Errorf("TrustedHeaderAuthPlugin requires bearer token")
+		return nil, fmt.Errorf("%w: TrustedHeaderAuthPlugin requires bearer token", apierror.ErrAuthInvalid)
 	}
 
 	token := strings.TrimPrefix(authHeader, "Bearer ")
@@ -42,17 +43,17 @@ func (p *TrustedHeaderAuthPlugin) ValidateRequest(ctx context.Context, r *http.R
 
 	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
-		return nil, fmt.Errorf("invalid JWT format")
+		return nil, fmt.Errorf("%w: invalid JWT format", apierror.ErrAuthInvalid)
 	}
 
 	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode JWT payload: %v", err)
+		return nil, fmt.Errorf("%w: failed to decode JWT payload: %v", apierror.ErrAuthInvalid, err)
 	}
 
 	var claims map[string]interface{}
 	if err := json.Unmarshal(payload, &claims); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JWT claims: %v", err)
+		return nil, fmt.Errorf("%w: failed to unmarshal JWT claims: %v", apierror.ErrAuthInvalid, err)
 	}
 
 	// Extract identity
@@ -75,7 +76,7 @@ func (p *TrustedHeaderAuthPlugin) ValidateRequest(ctx context.Context, r *http.R
 // CanAccessStream checks if the user/client can access a specific stream
 func (p *TrustedHeaderAuthPlugin) CanAccessStream(ctx context.Context, authResult *plugins.AuthResult, streamID string, permission string) (bool, error) {
 	if authResult.AuthSource != "oidc" {
-		return false, fmt.Errorf("TrustedHeaderAuthPlugin cannot authorize user from source: %s", authResult.AuthSource)
+		return false, fmt.Errorf("%w: TrustedHeaderAuthPlugin cannot authorize user from source: %s", apierror.ErrAuthForbidden, authResult.AuthSource)
 	}
 	// For trusted header/OIDC, we currently allow all access if authenticated (demo mode)
 	return true, nil
@@ -84,11 +85,11 @@ func (p *TrustedHeaderAuthPlugin) CanAccessStream(ctx context.Context, authResul
 // ValidateAuthHeader validates an Authorization header value directly (protocol-agnostic)
 func (p *TrustedHeaderAuthPlugin) ValidateAuthHeader(ctx context.Context, authHeader string, secretPlugin plugins.SecretPlugin) (*plugins.AuthResult, error) {
 	if authHeader == "" {
-		return nil, fmt.Errorf("missing Authorization header")
+		return nil, fmt.Errorf("%w: missing Authorization header", apierror.ErrAuthMissing)
 	}
 
 	if !strings.HasPrefix(authHeader, "Bearer ") {
-		return nil, fmt.Errorf("TrustedHeaderAuthPlugin requires bearer token")
+		return nil, fmt.Errorf("%w: TrustedHeaderAuthPlugin requires bearer token", apierror.ErrAuthInvalid)
 	}
 
 	token := strings.TrimPrefix(authHeader, "Bearer ")
@@ -99,17 +100,17 @@ func (p *TrustedHeaderAuthPlugin) ValidateAuthHeader(ctx context.Context, authHe
 
 	parts := strings.Split(token, ".")
 	if len(parts) != 3 {
-		return nil, fmt.Errorf("invalid JWT format")
+		return nil, fmt.Errorf("%w: invalid JWT format", apierror.ErrAuthInvalid)
 	}
 
 	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode JWT payload: %v", err)
+		return nil, fmt.Errorf("%w: failed to decode JWT payload: %v", apierror.ErrAuthInvalid, err)
 	}
 
 	var claims map[string]interface{}
 	if err := json.Unmarshal(payload, &claims); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JWT claims: %v", err)
+		return nil, fmt.Errorf("%w: failed to unmarshal JWT claims: %v", apierror.ErrAuthInvalid, err)
 	}
 
 	// Extract identity
@@ -0,0 +1,85 @@
+// Package blobstore stores large request bodies outside the broker so records
+// can carry a claim-check reference instead of the body itself.
+package blobstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// Store is a blob storage backend. Implementations must be safe for
+// concurrent use. Keys are slash-separated and generated by the gateway.
+type Store interface {
+	// Put stores data under key and returns a URI that Get can resolve
+	Put(ctx context.Context, key string, data []byte) (uri string, err error)
+
+	// Get retrieves the data referenced by a URI returned from Put
+	Get(ctx context.Context, uri string) ([]byte, error)
+
+	// Delete removes the blob referenced by a URI returned from Put. Deleting
+	// a blob that does not exist is not an error.
+	Delete(ctx context.Context, uri string) error
+}
+
+// Reference is a claim check for a blob: where it is and how to verify it
+type Reference struct {
+	URI    string
+	SHA256 string // Hex-encoded SHA-256 of the blob
+	Size   int64
+}
+
+// Open creates a store from a URL. Supported schemes:
+//
+//	file:///var/lib/frkr/blobs   local filesystem (single node or shared volume)
+//
+// S3-compatible object storage (s3://bucket/prefix) is planned; it will
+// implement Store against the S3 API.
+func Open(rawURL string) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob store URL: %w", err)
+	}
+	switch u.Scheme {
+	case "file":
+		return NewLocalStore(u.Path)
+	case "s3":
+		return nil, fmt.Errorf("s3 blob store is not supported yet")
+	default:
+		return nil, fmt.Errorf("unsupported blob store scheme: %q", u.Scheme)
+	}
+}
+
+// Put stores data in a store under a unique key below prefix and returns the
+// claim-check reference. Keys are unique per call rather than content
+// addressed, so a blob belongs to exactly one record and can be deleted when
+// that record is not published.
+func Put(ctx context.Context, store Store, prefix string, data []byte) (*Reference, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate blob key: %w", err)
+	}
+	uri, err := store.Put(ctx, prefix+"/"+id.String(), data)
+	if err != nil {
+		return nil, err
+	}
+	return &Reference{URI: uri, SHA256: hash, Size: int64(len(data))}, nil
+}
+
+// Verify checks fetched data against a reference
+func (r *Reference) Verify(data []byte) error {
+	if int64(len(data)) != r.Size {
+		return fmt.Errorf("blob size mismatch: got %d, want %d", len(data), r.Size)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != r.SHA256 {
+		return fmt.Errorf("blob hash mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package blobstore
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalStore_PutGet(t *testing.T) {
+	store, err := Open("file://" + t.TempDir())
+	require.NoError(t, err)
+
+	data := []byte(strings.Repeat("x", 4096))
+	ref, err := Put(context.Background(), store, "stream-id/2026-01-02", data)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4096), ref.Size)
+	assert.Len(t, ref.SHA256, 64)
+	assert.True(t, strings.HasPrefix(ref.URI, "file://"))
+	assert.Contains(t, ref.URI, "/stream-id/2026-01-02/")
+
+	got, err := store.Get(context.Background(), ref.URI)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.NoError(t, ref.Verify(got))
+	assert.Error(t, ref.Verify([]byte("tampered")))
+
+	// Identical content gets its own blob, so deleting one leaves the other
+	again, err := Put(context.Background(), store, "stream-id/2026-01-02", data)
+	require.NoError(t, err)
+	assert.NotEqual(t, ref.URI, again.URI)
+	assert.Equal(t, ref.SHA256, again.SHA256)
+
+	require.NoError(t, store.Delete(context.Background(), ref.URI))
+	_, err = store.Get(context.Background(), ref.URI)
+	assert.Error(t, err)
+	assert.NoError(t, store.Delete(context.Background(), ref.URI))
+
+	got, err = store.Get(context.Background(), again.URI)
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+}
+
+func TestLocalStore_RejectsEscapingKeys(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = store.Put(context.Background(), "../outside", []byte("x"))
+	assert.ErrorContains(t, err, "invalid blob key")
+
+	_, err = store.Get(context.Background(), "file:///etc/passwd")
+	assert.Error(t, err)
+
+	assert.Error(t, store.Delete(context.Background(), "file:///etc/passwd"))
+}
+
+func TestOpen_UnsupportedSchemes(t *testing.T) {
+	_, err := Open("s3://bucket/prefix")
+	assert.ErrorContains(t, err, "not supported yet")
+
+	_, err = Open("ftp://host/path")
+	assert.ErrorContains(t, err, "unsupported blob store scheme")
+}
@@ -0,0 +1,107 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore stores blobs as files below a root directory
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore creates a local store rooted at dir, creating it if needed
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("blob store directory cannot be empty")
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blob store directory: %w", err)
+	}
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create blob store directory: %w", err)
+	}
+	return &LocalStore{root: root}, nil
+}
+
+// Put writes data to root/key atomically
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte) (string, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	uri := (&url.URL{Scheme: "file", Path: path}).String()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return "", fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".blob-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to store blob: %w", err)
+	}
+	return uri, nil
+}
+
+// Get reads the blob referenced by a file:// URI below the store root
+func (s *LocalStore) Get(ctx context.Context, uri string) ([]byte, error) {
+	path, err := s.uriPath(uri)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	return data, nil
+}
+
+// Delete removes the blob referenced by a file:// URI below the store root
+func (s *LocalStore) Delete(ctx context.Context, uri string) error {
+	path, err := s.uriPath(uri)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	return nil
+}
+
+// uriPath resolves a file:// URI to a path below the root
+func (s *LocalStore) uriPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", fmt.Errorf("invalid local blob URI: %s", uri)
+	}
+	rel, err := filepath.Rel(s.root, u.Path)
+	if err != nil {
+		return "", fmt.Errorf("invalid local blob URI: %s", uri)
+	}
+	return s.path(filepath.ToSlash(rel))
+}
+
+// path resolves a key below the root, rejecting keys that escape it
+func (s *LocalStore) path(key string) (string, error) {
+	path := filepath.Join(s.root, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, s.root+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid blob key: %s", key)
+	}
+	return path, nil
+}
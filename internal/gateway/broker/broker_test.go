@@ -0,0 +1,103 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Class
+	}{
+		{"unknown topic", kafka.UnknownTopicOrPartition, ClassUnknownTopic},
+		{"wrapped leader not available", fmt.Errorf("write: %w", kafka.LeaderNotAvailable), ClassLeaderUnavailable},
+		{"not enough replicas", kafka.NotEnoughReplicasAfterAppend, ClassNotEnoughReplicas},
+		{"request timed out", kafka.RequestTimedOut, ClassTimeout},
+		{"message too large code", kafka.MessageSizeTooLarge, ClassMessageTooLarge},
+		{"message too large client side", kafka.MessageTooLargeError{}, ClassMessageTooLarge},
+		{"authorization", kafka.TopicAuthorizationFailed, ClassAuthorization},
+		{"write errors use first failure", kafka.WriteErrors{nil, kafka.NotEnoughReplicas}, ClassNotEnoughReplicas},
+		{"context deadline", context.DeadlineExceeded, ClassTimeout},
+		{"context canceled", context.Canceled, ClassCanceled},
+		{"connection closed", io.EOF, ClassNetwork},
+		{"other temporary code", kafka.KafkaStorageError, Class{Name: "broker_error", Retriable: true}},
+		{"other permanent code", kafka.PolicyViolation, Class{Name: "broker_error", Retriable: false}},
+		{"unrecognised", errors.New("boom"), ClassUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Classify(tt.err))
+		})
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+
+	t.Run("retries only retriable classes within max attempts", func(t *testing.T) {
+		assert.True(t, p.ShouldRetry(ClassLeaderUnavailable, 0))
+		assert.True(t, p.ShouldRetry(ClassLeaderUnavailable, 1))
+		assert.False(t, p.ShouldRetry(ClassLeaderUnavailable, 2))
+		assert.False(t, p.ShouldRetry(ClassMessageTooLarge, 0))
+	})
+
+	t.Run("backoff grows exponentially with jitter and is capped", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			b0 := p.Backoff(0)
+			assert.GreaterOrEqual(t, b0, 50*time.Millisecond)
+			assert.Less(t, b0, 100*time.Millisecond)
+
+			b1 := p.Backoff(1)
+			assert.GreaterOrEqual(t, b1, 100*time.Millisecond)
+			assert.Less(t, b1, 200*time.Millisecond)
+
+			b5 := p.Backoff(5)
+			assert.GreaterOrEqual(t, b5, 150*time.Millisecond)
+			assert.Less(t, b5, 300*time.Millisecond)
+		}
+	})
+
+	t.Run("wait returns early when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, p.Wait(ctx, 10), context.Canceled)
+	})
+}
+
+func TestTopicSpec_TopicConfig(t *testing.T) {
+	t.Run("stream settings become topic configs", func(t *testing.T) {
+		spec := TopicSpec{
+			Name:              "stream-t-orders",
+			Partitions:        6,
+			ReplicationFactor: 3,
+			RetentionMs:       RetentionMsFromDays(7),
+			CleanupPolicy:     "delete",
+			Compression:       "zstd",
+		}
+		cfg := spec.TopicConfig()
+		assert.Equal(t, "stream-t-orders", cfg.Topic)
+		assert.Equal(t, 6, cfg.NumPartitions)
+		assert.Equal(t, 3, cfg.ReplicationFactor)
+		assert.Equal(t, []kafka.ConfigEntry{
+			{ConfigName: "retention.ms", ConfigValue: "604800000"},
+			{ConfigName: "cleanup.policy", ConfigValue: "delete"},
+			{ConfigName: "compression.type", ConfigValue: "zstd"},
+		}, cfg.ConfigEntries)
+	})
+
+	t.Run("unset values fall back to broker defaults", func(t *testing.T) {
+		cfg := TopicSpec{Name: "t"}.TopicConfig()
+		assert.Equal(t, 1, cfg.NumPartitions)
+		assert.Equal(t, 1, cfg.ReplicationFactor)
+		assert.Empty(t, cfg.ConfigEntries)
+	})
+}
@@ -0,0 +1,112 @@
+// Package broker classifies broker errors, provides the retry policy used when
+// publishing, and creates stream topics on a Kafka-compatible broker.
+package broker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Class groups broker errors by how the gateway should react to them. Name is
+// stable and used as the error_type metric label.
+type Class struct {
+	Name      string
+	Retriable bool
+}
+
+// Error classes
+var (
+	ClassUnknownTopic      = Class{Name: "unknown_topic", Retriable: true}
+	ClassLeaderUnavailable = Class{Name: "leader_unavailable", Retriable: true}
+	ClassNotEnoughReplicas = Class{Name: "not_enough_replicas", Retriable: true}
+	ClassTimeout           = Class{Name: "timeout", Retriable: true}
+	ClassNetwork           = Class{Name: "network", Retriable: true}
+	ClassThrottled         = Class{Name: "throttled", Retriable: true}
+	ClassMessageTooLarge   = Class{Name: "message_too_large", Retriable: false}
+	ClassAuthorization     = Class{Name: "authorization", Retriable: false}
+	ClassInvalidRequest    = Class{Name: "invalid_request", Retriable: false}
+	ClassCanceled          = Class{Name: "canceled", Retriable: false}
+	ClassBroker            = Class{Name: "broker_error", Retriable: false}
+	ClassUnknown           = Class{Name: "unknown", Retriable: false}
+)
+
+// Classify maps a publish error to its class. For kafka.WriteErrors the first
+// non-nil per-message error determines the class.
+func Classify(err error) Class {
+	if err == nil {
+		return Class{}
+	}
+
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) {
+		for _, e := range writeErrs {
+			if e != nil {
+				return Classify(e)
+			}
+		}
+	}
+
+	var tooLarge kafka.MessageTooLargeError
+	if errors.As(err, &tooLarge) {
+		return ClassMessageTooLarge
+	}
+
+	var kerr kafka.Error
+	if errors.As(err, &kerr) {
+		return classifyCode(kerr)
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ClassTimeout
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return ClassNetwork
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return ClassTimeout
+		}
+		return ClassNetwork
+	}
+
+	return ClassUnknown
+}
+
+// classifyCode maps a Kafka protocol error code to its class
+func classifyCode(code kafka.Error) Class {
+	switch code {
+	case kafka.UnknownTopicOrPartition, kafka.UnknownTopicID:
+		return ClassUnknownTopic
+	case kafka.LeaderNotAvailable, kafka.NotLeaderForPartition, kafka.PreferredLeaderNotAvailable,
+		kafka.EligibleLeadersNotAvailable, kafka.FencedLeaderEpoch, kafka.UnknownLeaderEpoch,
+		kafka.BrokerNotAvailable, kafka.ReplicaNotAvailable:
+		return ClassLeaderUnavailable
+	case kafka.NotEnoughReplicas, kafka.NotEnoughReplicasAfterAppend:
+		return ClassNotEnoughReplicas
+	case kafka.RequestTimedOut:
+		return ClassTimeout
+	case kafka.NetworkException:
+		return ClassNetwork
+	case kafka.ThrottlingQuotaExceeded:
+		return ClassThrottled
+	case kafka.MessageSizeTooLarge, kafka.RecordListTooLarge, kafka.InvalidMessageSize:
+		return ClassMessageTooLarge
+	case kafka.TopicAuthorizationFailed, kafka.ClusterAuthorizationFailed, kafka.BrokerAuthorizationFailed,
+		kafka.TransactionalIDAuthorizationFailed, kafka.SASLAuthenticationFailed:
+		return ClassAuthorization
+	case kafka.InvalidTopic, kafka.InvalidRequiredAcks, kafka.InvalidRecord, kafka.InvalidTimestamp,
+		kafka.UnsupportedForMessageFormat, kafka.UnsupportedCompressionType:
+		return ClassInvalidRequest
+	}
+	return Class{Name: ClassBroker.Name, Retriable: code.Temporary()}
+}
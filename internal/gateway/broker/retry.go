@@ -0,0 +1,61 @@
+package broker
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how retriable publish errors are retried
+type RetryPolicy struct {
+	MaxAttempts    int           // Total attempts including the first; values < 1 mean 1
+	InitialBackoff time.Duration // Backoff before the first retry
+	MaxBackoff     time.Duration // Upper bound on a single backoff
+}
+
+// DefaultRetryPolicy returns the retry policy used when none is configured
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+	}
+}
+
+// ShouldRetry reports whether another attempt is allowed after the given
+// (zero-based) attempt failed with an error of class c
+func (p RetryPolicy) ShouldRetry(c Class, attempt int) bool {
+	return c.Retriable && attempt+1 < p.MaxAttempts
+}
+
+// Backoff returns the delay before retrying after the given (zero-based)
+// attempt: exponential growth capped at MaxBackoff, with "equal jitter" so
+// the delay is uniformly distributed in [d/2, d) and concurrent publishers
+// don't retry in lockstep.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 0; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(d-half)
+}
+
+// Wait sleeps for the backoff of the given attempt, returning early with the
+// context's error if it is cancelled
+func (p RetryPolicy) Wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(p.Backoff(attempt))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// TopicSpec describes how a stream topic is created
+type TopicSpec struct {
+	Name              string
+	Partitions        int
+	ReplicationFactor int
+	RetentionMs       int64  // 0 uses the broker default
+	CleanupPolicy     string // "delete", "compact" or "compact,delete"; empty uses the broker default
+	Compression       string // Topic compression.type; empty uses the broker default
+}
+
+// RetentionMsFromDays converts a stream retention in days to retention.ms
+func RetentionMsFromDays(days int) int64 {
+	if days <= 0 {
+		return 0
+	}
+	return int64(days) * (24 * time.Hour).Milliseconds()
+}
+
+// TopicConfig converts the spec to a kafka-go topic config
+func (s TopicSpec) TopicConfig() kafka.TopicConfig {
+	partitions := s.Partitions
+	if partitions <= 0 {
+		partitions = 1
+	}
+	replication := s.ReplicationFactor
+	if replication <= 0 {
+		replication = 1
+	}
+
+	var entries []kafka.ConfigEntry
+	if s.RetentionMs > 0 {
+		entries = append(entries, kafka.ConfigEntry{ConfigName: "retention.ms", ConfigValue: strconv.FormatInt(s.RetentionMs, 10)})
+	}
+	if s.CleanupPolicy != "" {
+		entries = append(entries, kafka.ConfigEntry{ConfigName: "cleanup.policy", ConfigValue: s.CleanupPolicy})
+	}
+	if s.Compression != "" {
+		entries = append(entries, kafka.ConfigEntry{ConfigName: "compression.type", ConfigValue: s.Compression})
+	}
+
+	return kafka.TopicConfig{
+		Topic:             s.Name,
+		NumPartitions:     partitions,
+		ReplicationFactor: replication,
+		ConfigEntries:     entries,
+	}
+}
+
+// CreateTopic creates a topic from a spec if it doesn't already exist
+func CreateTopic(ctx context.Context, brokerURL string, spec TopicSpec) error {
+	conn, err := kafka.DialContext(ctx, "tcp", brokerURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to broker: %w", err)
+	}
+	defer conn.Close()
+
+	controller, err := conn.Controller()
+	if err != nil {
+		return fmt.Errorf("failed to get controller: %w", err)
+	}
+
+	controllerConn, err := kafka.DialContext(ctx, "tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		return fmt.Errorf("failed to connect to controller: %w", err)
+	}
+	defer controllerConn.Close()
+
+	if err := controllerConn.CreateTopics(spec.TopicConfig()); err != nil {
+		if errors.Is(err, kafka.TopicAlreadyExists) {
+			return nil
+		}
+		return fmt.Errorf("failed to create topic %s: %w", spec.Name, err)
+	}
+	return nil
+}
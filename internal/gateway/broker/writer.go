@@ -0,0 +1,34 @@
+package broker
+
+import (
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// NewWriter returns a writer to the same brokers as base that waits for
+// acks. Async writers return as soon as messages are queued and publish them
+// in batches of up to batchSize or after batchTimeout. Every written batch is
+// reported to completion, with partitions and offsets set on success.
+func NewWriter(base *kafka.Writer, acks kafka.RequiredAcks, async bool, batchSize int, batchTimeout time.Duration, completion func([]kafka.Message, error)) *kafka.Writer {
+	w := &kafka.Writer{
+		Addr:         base.Addr,
+		Balancer:     base.Balancer,
+		MaxAttempts:  base.MaxAttempts,
+		BatchBytes:   base.BatchBytes,
+		ReadTimeout:  base.ReadTimeout,
+		WriteTimeout: base.WriteTimeout,
+		Compression:  base.Compression,
+		Transport:    base.Transport,
+		BatchSize:    base.BatchSize,
+		BatchTimeout: base.BatchTimeout,
+		RequiredAcks: acks,
+		Completion:   completion,
+	}
+	if async {
+		w.Async = true
+		w.BatchSize = batchSize
+		w.BatchTimeout = batchTimeout
+	}
+	return w
+}
@@ -0,0 +1,205 @@
+// Package capture defines the captured traffic accepted by the ingest API: the
+// ingest.v1 messages plus fields the proto doesn't carry (yet). Extra fields
+// are additive and omitted when unset, so records stay readable as plain
+// ingest.v1 JSON.
+package capture
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+	"github.com/google/uuid"
+)
+
+// Body encodings
+const (
+	BodyEncodingUTF8   = "utf8"   // Body is the text itself
+	BodyEncodingBase64 = "base64" // Body is standard base64 of opaque bytes
+)
+
+// Fields the gateway fills in when the client omits them
+const (
+	GeneratedRequestID = "request_id"
+	GeneratedTimestamp = "timestamp_ns"
+)
+
+// IngestRequest is the body of POST /ingest
+type IngestRequest struct {
+	StreamID string   `json:"stream_id"`
+	Request  *Request `json:"request"`
+}
+
+// Request is a mirrored HTTP request
+type Request struct {
+	*ingestv1.MirroredRequest
+
+	// BodyEncoding is how Body is encoded: utf8 (default) or base64
+	BodyEncoding string `json:"body_encoding,omitempty"`
+
+	// ContentType is the content type of the decoded body, as a hint for
+	// consumers when the mirrored headers don't carry it
+	ContentType string `json:"content_type,omitempty"`
+
+	// Response is what production answered, when captured in the same call
+	Response *Response `json:"response,omitempty"`
+
+	// CloudEvent holds the event attributes when the request carried a
+	// CloudEvent (POST /ingest/cloudevents); the body is the event data
+	CloudEvent *CloudEvent `json:"cloudevent,omitempty"`
+
+	// Sequence is the client's sequence number of the request among those
+	// with the same partition key, starting at 1. Streams in ordered mode use
+	// it to detect gaps and reordering.
+	Sequence int64 `json:"sequence,omitempty"`
+
+	// ServerGenerated lists the Generated* fields the gateway filled in
+	ServerGenerated []string `json:"-"`
+
+	// ClockSkew is how far the client's timestamp was ahead of the receive
+	// time, set when it exceeded the clock skew threshold.
+	// OriginalTimestampNs is the client's timestamp when the gateway
+	// replaced it with the receive time.
+	ClockSkew           time.Duration `json:"-"`
+	OriginalTimestampNs int64         `json:"-"`
+
+	// Imported is set for requests replayed from a recording (HAR files,
+	// packet captures), whose timestamps are historical: they are exempt
+	// from the maximum timestamp age and from clock skew checks
+	Imported bool `json:"-"`
+}
+
+// CloudEvent holds the context attributes of a CloudEvent
+type CloudEvent struct {
+	SpecVersion     string            `json:"specversion"`
+	ID              string            `json:"id"`
+	Source          string            `json:"source"`
+	Type            string            `json:"type"`
+	Subject         string            `json:"subject,omitempty"`
+	Time            string            `json:"time,omitempty"`
+	DataContentType string            `json:"datacontenttype,omitempty"`
+	DataSchema      string            `json:"dataschema,omitempty"`
+	Extensions      map[string]string `json:"extensions,omitempty"`
+}
+
+// Response is the mirrored response to a request
+type Response struct {
+	RequestID    string            `json:"request_id,omitempty"` // Required when sent on its own
+	Status       int               `json:"status"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	Body         string            `json:"body,omitempty"`
+	BodyEncoding string            `json:"body_encoding,omitempty"`
+	ContentType  string            `json:"content_type,omitempty"`
+	LatencyNs    int64             `json:"latency_ns,omitempty"` // Time production took to answer
+}
+
+// IngestResponse is the body of POST /ingest/response, which captures a
+// response after its request was ingested
+type IngestResponse struct {
+	StreamID string    `json:"stream_id"`
+	Response *Response `json:"response"`
+}
+
+// Decode parses an ingest request body
+func Decode(data []byte) (*IngestRequest, error) {
+	var req IngestRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	// "request": {} leaves the embedded message unset
+	if req.Request != nil && req.Request.MirroredRequest == nil {
+		req.Request.MirroredRequest = &ingestv1.MirroredRequest{}
+	}
+	return &req, nil
+}
+
+// DecodeResponse parses a POST /ingest/response body
+func DecodeResponse(data []byte) (*IngestResponse, error) {
+	var resp IngestResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Encoding returns the body encoding, defaulting to utf8
+func (r *Request) Encoding() string {
+	return bodyEncoding(r.BodyEncoding)
+}
+
+// FillMissing sets a missing request ID to a new UUIDv7, which sorts by
+// creation time, and a missing timestamp to receivedAt, recording them in
+// ServerGenerated
+func (r *Request) FillMissing(receivedAt time.Time) {
+	if r.RequestId == "" {
+		r.RequestId = uuid.Must(uuid.NewV7()).String()
+		r.ServerGenerated = append(r.ServerGenerated, GeneratedRequestID)
+	}
+	if r.TimestampNs == 0 {
+		r.TimestampNs = receivedAt.UnixNano()
+		r.ServerGenerated = append(r.ServerGenerated, GeneratedTimestamp)
+	}
+}
+
+// BodyBytes returns the exact bytes of the mirrored body
+func (r *Request) BodyBytes() ([]byte, error) {
+	return decodeBody(r.Body, r.BodyEncoding)
+}
+
+// Validate checks that a response and the request ID it answers are present
+func (r *IngestResponse) Validate() error {
+	if r.Response == nil {
+		return errors.New("missing response")
+	}
+	if r.Response.RequestID == "" {
+		return errors.New("missing response request_id")
+	}
+	return r.Response.Validate()
+}
+
+// Encoding returns the body encoding, defaulting to utf8
+func (r *Response) Encoding() string {
+	return bodyEncoding(r.BodyEncoding)
+}
+
+// Validate checks the status code and body encoding
+func (r *Response) Validate() error {
+	if r.Status < 100 || r.Status > 599 {
+		return fmt.Errorf("invalid status %d", r.Status)
+	}
+	if r.LatencyNs < 0 {
+		return fmt.Errorf("invalid latency %d", r.LatencyNs)
+	}
+	return validateBody(r.Body, r.BodyEncoding)
+}
+
+func bodyEncoding(encoding string) string {
+	if encoding == "" {
+		return BodyEncodingUTF8
+	}
+	return encoding
+}
+
+func validateBody(body, encoding string) error {
+	switch bodyEncoding(encoding) {
+	case BodyEncodingUTF8:
+		return nil
+	case BodyEncodingBase64:
+		if _, err := base64.StdEncoding.DecodeString(body); err != nil {
+			return fmt.Errorf("invalid base64 body: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown body encoding %q (want %s or %s)", encoding, BodyEncodingUTF8, BodyEncodingBase64)
+	}
+}
+
+func decodeBody(body, encoding string) ([]byte, error) {
+	if bodyEncoding(encoding) == BodyEncodingBase64 {
+		return base64.StdEncoding.DecodeString(body)
+	}
+	return []byte(body), nil
+}
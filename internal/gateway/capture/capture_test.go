@@ -0,0 +1,123 @@
+package capture
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	data := []byte(`{
+		"stream_id": "my-api",
+		"request": {
+			"method": "POST",
+			"path": "/upload",
+			"body": "AAEC/w==",
+			"body_encoding": "base64",
+			"content_type": "application/octet-stream",
+			"request_id": "req-1"
+		}
+	}`)
+
+	req, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, "my-api", req.StreamID)
+	require.NotNil(t, req.Request)
+	assert.Equal(t, "POST", req.Request.Method)
+	assert.Equal(t, "req-1", req.Request.RequestId)
+	assert.Equal(t, BodyEncodingBase64, req.Request.Encoding())
+	assert.Equal(t, "application/octet-stream", req.Request.ContentType)
+
+	body, err := req.Request.BodyBytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x01, 0x02, 0xff}, body)
+}
+
+func TestDecode_MissingRequest(t *testing.T) {
+	req, err := Decode([]byte(`{"stream_id": "my-api"}`))
+	require.NoError(t, err)
+	assert.Nil(t, req.Request)
+}
+
+func TestDecode_EmptyRequest(t *testing.T) {
+	req, err := Decode([]byte(`{"stream_id": "my-api", "request": {}}`))
+	require.NoError(t, err)
+	require.NotNil(t, req.Request.MirroredRequest)
+}
+
+func TestRequest_MarshalKeepsMirroredRequestFields(t *testing.T) {
+	req, err := Decode([]byte(`{"request": {"method": "GET", "path": "/a", "body": "hi"}}`))
+	require.NoError(t, err)
+
+	data, err := json.Marshal(req.Request)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"method": "GET", "path": "/a", "body": "hi"}`, string(data))
+}
+
+func TestRequest_FillMissing(t *testing.T) {
+	receivedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	req, err := Decode([]byte(`{"request": {"method": "GET", "path": "/a"}}`))
+	require.NoError(t, err)
+	req.Request.FillMissing(receivedAt)
+	id, err := uuid.Parse(req.Request.RequestId)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Version(7), id.Version())
+	assert.Equal(t, receivedAt.UnixNano(), req.Request.TimestampNs)
+	assert.Equal(t, []string{GeneratedRequestID, GeneratedTimestamp}, req.Request.ServerGenerated)
+
+	// Generated fields are published, the list of them is not
+	data, err := json.Marshal(req.Request)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "ServerGenerated")
+
+	req, err = Decode([]byte(`{"request": {"method": "GET", "path": "/a", "request_id": "r1", "timestamp_ns": 42}}`))
+	require.NoError(t, err)
+	req.Request.FillMissing(receivedAt)
+	assert.Equal(t, "r1", req.Request.RequestId)
+	assert.Equal(t, int64(42), req.Request.TimestampNs)
+	assert.Empty(t, req.Request.ServerGenerated)
+}
+
+func TestDecode_PairedResponse(t *testing.T) {
+	req, err := Decode([]byte(`{
+		"stream_id": "my-api",
+		"request": {
+			"method": "GET",
+			"path": "/users/1",
+			"request_id": "req-1",
+			"response": {"status": 200, "headers": {"content-type": "application/json"}, "body": "{}", "latency_ns": 1500000}
+		}
+	}`))
+	require.NoError(t, err)
+	require.NotNil(t, req.Request.Response)
+	assert.Equal(t, 200, req.Request.Response.Status)
+	assert.Equal(t, int64(1500000), req.Request.Response.LatencyNs)
+	assert.NoError(t, req.Request.Response.Validate())
+}
+
+func TestResponse_Validate(t *testing.T) {
+	assert.NoError(t, (&Response{Status: 204}).Validate())
+	assert.NoError(t, (&Response{Status: 200, Body: "AAE=", BodyEncoding: "base64"}).Validate())
+	assert.Error(t, (&Response{Status: 0}).Validate())
+	assert.Error(t, (&Response{Status: 200, LatencyNs: -1}).Validate())
+	assert.Error(t, (&Response{Status: 200, Body: "%%", BodyEncoding: "base64"}).Validate())
+}
+
+func TestDecodeResponse(t *testing.T) {
+	resp, err := DecodeResponse([]byte(`{"stream_id": "my-api", "response": {"request_id": "req-1", "status": 503}}`))
+	require.NoError(t, err)
+	assert.Equal(t, "my-api", resp.StreamID)
+	require.NotNil(t, resp.Response)
+	assert.Equal(t, "req-1", resp.Response.RequestID)
+	assert.Equal(t, 503, resp.Response.Status)
+	assert.NoError(t, resp.Validate())
+
+	resp.Response.RequestID = ""
+	assert.Error(t, resp.Validate())
+	assert.Error(t, (&IngestResponse{StreamID: "my-api"}).Validate())
+}
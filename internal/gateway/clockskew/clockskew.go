@@ -0,0 +1,50 @@
+// Package clockskew measures how far a client's capture timestamp is from the
+// gateway's receive time, so hosts with broken clocks can be spotted and their
+// timestamps annotated or corrected before they confuse replay ordering.
+package clockskew
+
+import "time"
+
+// Skew directions
+const (
+	Ahead  = "ahead"  // Client clock is ahead of the gateway's
+	Behind = "behind" // Client clock is behind the gateway's, or the request was delayed
+)
+
+// Skew returns how far a timestamp is ahead of receivedAt; negative when it is
+// behind. Network and SDK buffering delays count as skew behind.
+func Skew(timestampNs int64, receivedAt time.Time) time.Duration {
+	return time.Duration(timestampNs - receivedAt.UnixNano())
+}
+
+// Direction returns Ahead or Behind for a skew
+func Direction(skew time.Duration) string {
+	if skew > 0 {
+		return Ahead
+	}
+	return Behind
+}
+
+// Abs returns the magnitude of a skew
+func Abs(skew time.Duration) time.Duration {
+	if skew < 0 {
+		return -skew
+	}
+	return skew
+}
+
+// Policy is what is done with a skewed timestamp
+type Policy struct {
+	// Threshold is the skew, in either direction, above which records are
+	// annotated. Zero disables annotation and correction.
+	Threshold time.Duration
+
+	// Correct replaces timestamps skewed beyond Threshold with the receive
+	// time, keeping the original in the record metadata
+	Correct bool
+}
+
+// Exceeds reports whether a skew is beyond the threshold
+func (p Policy) Exceeds(skew time.Duration) bool {
+	return p.Threshold > 0 && Abs(skew) > p.Threshold
+}
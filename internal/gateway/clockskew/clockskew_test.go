@@ -0,0 +1,44 @@
+package clockskew
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkew(t *testing.T) {
+	receivedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	ahead := Skew(receivedAt.Add(2*time.Second).UnixNano(), receivedAt)
+	assert.Equal(t, 2*time.Second, ahead)
+	assert.Equal(t, Ahead, Direction(ahead))
+	assert.Equal(t, 2*time.Second, Abs(ahead))
+
+	behind := Skew(receivedAt.Add(-3*time.Second).UnixNano(), receivedAt)
+	assert.Equal(t, -3*time.Second, behind)
+	assert.Equal(t, Behind, Direction(behind))
+	assert.Equal(t, 3*time.Second, Abs(behind))
+
+	assert.Equal(t, Behind, Direction(0))
+}
+
+func TestPolicy_Exceeds(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		skew      time.Duration
+		want      bool
+	}{
+		{"within", 30 * time.Second, 10 * time.Second, false},
+		{"at threshold", 30 * time.Second, 30 * time.Second, false},
+		{"ahead", 30 * time.Second, time.Minute, true},
+		{"behind", 30 * time.Second, -time.Minute, true},
+		{"disabled", 0, time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Policy{Threshold: tt.threshold}.Exceeds(tt.skew))
+		})
+	}
+}
@@ -0,0 +1,318 @@
+// Package cloudevents decodes CloudEvents received over HTTP, in binary,
+// structured and batch mode (CloudEvents 1.0 HTTP protocol binding), and maps
+// them onto mirrored requests.
+package cloudevents
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+)
+
+// Media types of the structured and batch content modes
+const (
+	MediaTypeStructured = "application/cloudevents+json"
+	MediaTypeBatch      = "application/cloudevents-batch+json"
+)
+
+// PathPrefix is the route events are posted to: /ingest/cloudevents/{stream}
+// optionally followed by the path recorded for the event
+const PathPrefix = "/ingest/cloudevents/"
+
+// headerPrefix prefixes context attributes in binary mode
+const headerPrefix = "Ce-"
+
+// Event is a decoded CloudEvent
+type Event struct {
+	capture.CloudEvent
+	Data []byte // Event data, nil if the event has none
+}
+
+// SplitPath splits a request path under PathPrefix into the stream name and
+// the remaining path ("/" when there is none)
+func SplitPath(path string) (stream, rest string, ok bool) {
+	trimmed, found := strings.CutPrefix(path, PathPrefix)
+	if !found {
+		return "", "", false
+	}
+	stream, rest, _ = strings.Cut(trimmed, "/")
+	if stream == "" {
+		return "", "", false
+	}
+	return stream, "/" + rest, true
+}
+
+// IsBatch reports whether an HTTP request carries events in batch mode
+func IsBatch(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == MediaTypeBatch
+}
+
+// Decode decodes the CloudEvents in an HTTP request, detecting the content
+// mode from the Content-Type. Batch mode can carry several events.
+func Decode(r *http.Request, body []byte) ([]*Event, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var events []*Event
+	switch mediaType {
+	case MediaTypeStructured:
+		event, err := decodeStructured(body)
+		if err != nil {
+			return nil, err
+		}
+		events = []*Event{event}
+	case MediaTypeBatch:
+		var raw []json.RawMessage
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, fmt.Errorf("invalid event batch: %w", err)
+		}
+		if len(raw) == 0 {
+			return nil, errors.New("empty event batch")
+		}
+		for i, data := range raw {
+			event, err := decodeStructured(data)
+			if err != nil {
+				return nil, fmt.Errorf("event %d: %w", i, err)
+			}
+			events = append(events, event)
+		}
+	default:
+		events = []*Event{decodeBinary(r, body)}
+	}
+
+	for _, event := range events {
+		if err := event.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// decodeBinary reads an event whose attributes are in ce- headers and whose
+// data is the request body
+func decodeBinary(r *http.Request, body []byte) *Event {
+	event := &Event{}
+	for name, values := range r.Header {
+		if !strings.HasPrefix(name, headerPrefix) || len(values) == 0 {
+			continue
+		}
+		// Binary mode header values are percent-encoded
+		value := percentDecode(values[0])
+		switch attr := strings.ToLower(strings.TrimPrefix(name, headerPrefix)); attr {
+		case "specversion":
+			event.SpecVersion = value
+		case "id":
+			event.ID = value
+		case "source":
+			event.Source = value
+		case "type":
+			event.Type = value
+		case "subject":
+			event.Subject = value
+		case "time":
+			event.Time = value
+		case "dataschema":
+			event.DataSchema = value
+		default:
+			if event.Extensions == nil {
+				event.Extensions = map[string]string{}
+			}
+			event.Extensions[attr] = value
+		}
+	}
+	event.DataContentType = r.Header.Get("Content-Type")
+	if len(body) > 0 {
+		event.Data = body
+	}
+	return event
+}
+
+// decodeStructured reads an event from its JSON format
+func decodeStructured(data []byte) (*Event, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("invalid structured event: %w", err)
+	}
+
+	event := &Event{}
+	for name, raw := range fields {
+		switch name {
+		case "data", "data_base64":
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			// Extension attributes may be booleans or integers
+			value = string(raw)
+			if name == "specversion" || name == "id" || name == "source" || name == "type" {
+				return nil, fmt.Errorf("invalid %s attribute", name)
+			}
+		}
+		switch name {
+		case "specversion":
+			event.SpecVersion = value
+		case "id":
+			event.ID = value
+		case "source":
+			event.Source = value
+		case "type":
+			event.Type = value
+		case "subject":
+			event.Subject = value
+		case "time":
+			event.Time = value
+		case "datacontenttype":
+			event.DataContentType = value
+		case "dataschema":
+			event.DataSchema = value
+		default:
+			if event.Extensions == nil {
+				event.Extensions = map[string]string{}
+			}
+			event.Extensions[name] = value
+		}
+	}
+
+	if raw, ok := fields["data_base64"]; ok {
+		var encoded string
+		if err := json.Unmarshal(raw, &encoded); err != nil {
+			return nil, errors.New("invalid data_base64")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data_base64: %w", err)
+		}
+		event.Data = decoded
+	} else if raw, ok := fields["data"]; ok && !bytes.Equal(raw, []byte("null")) {
+		// JSON data is kept as JSON; a string is unwrapped for other content types
+		var text string
+		if !isJSON(event.DataContentType) && json.Unmarshal(raw, &text) == nil {
+			event.Data = []byte(text)
+		} else {
+			event.Data = raw
+			if event.DataContentType == "" {
+				event.DataContentType = "application/json"
+			}
+		}
+	}
+	return event, nil
+}
+
+// Validate checks the required context attributes
+func (e *Event) Validate() error {
+	switch {
+	case e.SpecVersion == "":
+		return errors.New("missing specversion")
+	case !strings.HasPrefix(e.SpecVersion, "1."):
+		return fmt.Errorf("unsupported specversion %q", e.SpecVersion)
+	case e.ID == "":
+		return errors.New("missing id")
+	case e.Source == "":
+		return errors.New("missing source")
+	case e.Type == "":
+		return errors.New("missing type")
+	}
+	if e.Time != "" {
+		if _, err := time.Parse(time.RFC3339Nano, e.Time); err != nil {
+			return fmt.Errorf("invalid time %q", e.Time)
+		}
+	}
+	return nil
+}
+
+// MirroredRequest maps the event onto a mirrored request: a binary mode POST
+// to path carrying the event, so replaying it re-delivers the event. The event
+// ID is the request ID and the event time, when set, the timestamp.
+func (e *Event) MirroredRequest(path string, receivedAt time.Time) *capture.Request {
+	headers := map[string]string{
+		"ce-specversion": e.SpecVersion,
+		"ce-id":          e.ID,
+		"ce-source":      e.Source,
+		"ce-type":        e.Type,
+	}
+	for name, value := range map[string]string{"ce-subject": e.Subject, "ce-time": e.Time, "ce-dataschema": e.DataSchema, "content-type": e.DataContentType} {
+		if value != "" {
+			headers[name] = value
+		}
+	}
+	for name, value := range e.Extensions {
+		headers["ce-"+name] = value
+	}
+
+	var timestampNs int64
+	if t, err := time.Parse(time.RFC3339Nano, e.Time); err == nil {
+		timestampNs = t.UnixNano()
+	}
+
+	attrs := e.CloudEvent
+	req := &capture.Request{
+		MirroredRequest: &ingestv1.MirroredRequest{
+			Method:      http.MethodPost,
+			Path:        path,
+			Headers:     headers,
+			TimestampNs: timestampNs,
+			RequestId:   e.ID,
+		},
+		ContentType: e.DataContentType,
+		CloudEvent:  &attrs,
+	}
+	req.FillMissing(receivedAt)
+	if utf8.Valid(e.Data) {
+		req.Body = string(e.Data)
+	} else {
+		req.Body = base64.StdEncoding.EncodeToString(e.Data)
+		req.BodyEncoding = capture.BodyEncodingBase64
+	}
+	return req
+}
+
+// isJSON reports whether a content type is JSON (or unset, which means JSON
+// in structured mode)
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// percentDecode decodes a binary mode header value, leaving invalid escapes as-is
+func percentDecode(v string) string {
+	if !strings.Contains(v, "%") {
+		return v
+	}
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		if v[i] == '%' && i+2 < len(v) && isHex(v[i+1]) && isHex(v[i+2]) {
+			b.WriteByte(unhex(v[i+1])<<4 | unhex(v[i+2]))
+			i += 2
+			continue
+		}
+		b.WriteByte(v[i])
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= 'a':
+		return c - 'a' + 10
+	case c >= 'A':
+		return c - 'A' + 10
+	default:
+		return c - '0'
+	}
+}
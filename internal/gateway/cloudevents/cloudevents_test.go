@@ -0,0 +1,188 @@
+package cloudevents
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		stream string
+		rest   string
+		ok     bool
+	}{
+		{"/ingest/cloudevents/orders", "orders", "/", true},
+		{"/ingest/cloudevents/orders/webhooks/created", "orders", "/webhooks/created", true},
+		{"/ingest/cloudevents/", "", "", false},
+		{"/ingest", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			stream, rest, ok := SplitPath(tt.path)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.stream, stream)
+			assert.Equal(t, tt.rest, rest)
+		})
+	}
+}
+
+func TestDecode_Binary(t *testing.T) {
+	r := httptest.NewRequest("POST", "/ingest/cloudevents/orders", nil)
+	r.Header.Set("Ce-Specversion", "1.0")
+	r.Header.Set("Ce-Id", "evt-1")
+	assert.False(t, IsBatch(r))
+	r.Header.Set("Ce-Source", "/orders")
+	r.Header.Set("Ce-Type", "com.example.order.created")
+	r.Header.Set("Ce-Time", "2026-03-04T05:06:07Z")
+	r.Header.Set("Ce-Subject", "order%2042")
+	r.Header.Set("Ce-Traceparent", "00-abc-01")
+	r.Header.Set("Content-Type", "application/json")
+
+	events, err := Decode(r, []byte(`{"total":42}`))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	e := events[0]
+	assert.Equal(t, "evt-1", e.ID)
+	assert.Equal(t, "/orders", e.Source)
+	assert.Equal(t, "com.example.order.created", e.Type)
+	assert.Equal(t, "order 42", e.Subject)
+	assert.Equal(t, "application/json", e.DataContentType)
+	assert.Equal(t, map[string]string{"traceparent": "00-abc-01"}, e.Extensions)
+	assert.Equal(t, `{"total":42}`, string(e.Data))
+}
+
+func TestDecode_Structured(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		data        string
+		contentType string
+	}{
+		{
+			name:        "json data",
+			body:        `{"specversion":"1.0","id":"evt-1","source":"/orders","type":"created","data":{"total":42}}`,
+			data:        `{"total":42}`,
+			contentType: "application/json",
+		},
+		{
+			name:        "text data",
+			body:        `{"specversion":"1.0","id":"evt-1","source":"/orders","type":"created","datacontenttype":"text/plain","data":"hello"}`,
+			data:        "hello",
+			contentType: "text/plain",
+		},
+		{
+			name:        "base64 data",
+			body:        `{"specversion":"1.0","id":"evt-1","source":"/orders","type":"created","datacontenttype":"image/png","data_base64":"iVBORw=="}`,
+			data:        "\x89PNG",
+			contentType: "image/png",
+		},
+		{
+			name: "no data",
+			body: `{"specversion":"1.0","id":"evt-1","source":"/orders","type":"created"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/ingest/cloudevents/orders", nil)
+			r.Header.Set("Content-Type", MediaTypeStructured+"; charset=utf-8")
+			events, err := Decode(r, []byte(tt.body))
+			require.NoError(t, err)
+			require.Len(t, events, 1)
+			assert.Equal(t, "evt-1", events[0].ID)
+			assert.Equal(t, tt.data, string(events[0].Data))
+			assert.Equal(t, tt.contentType, events[0].DataContentType)
+		})
+	}
+}
+
+func TestDecode_Batch(t *testing.T) {
+	r := httptest.NewRequest("POST", "/ingest/cloudevents/orders", nil)
+	r.Header.Set("Content-Type", MediaTypeBatch)
+	body := `[{"specversion":"1.0","id":"a","source":"/s","type":"t","sampled":true},{"specversion":"1.0","id":"b","source":"/s","type":"t"}]`
+
+	assert.True(t, IsBatch(r))
+	events, err := Decode(r, []byte(body))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "a", events[0].ID)
+	assert.Equal(t, map[string]string{"sampled": "true"}, events[0].Extensions)
+	assert.Equal(t, "b", events[1].ID)
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		err         string
+	}{
+		{"missing id", MediaTypeStructured, `{"specversion":"1.0","source":"/s","type":"t"}`, "missing id"},
+		{"unsupported version", MediaTypeStructured, `{"specversion":"0.3","id":"a","source":"/s","type":"t"}`, "unsupported specversion"},
+		{"bad time", MediaTypeStructured, `{"specversion":"1.0","id":"a","source":"/s","type":"t","time":"yesterday"}`, "invalid time"},
+		{"bad base64", MediaTypeStructured, `{"specversion":"1.0","id":"a","source":"/s","type":"t","data_base64":"!!"}`, "invalid data_base64"},
+		{"not json", MediaTypeStructured, `nope`, "invalid structured event"},
+		{"empty batch", MediaTypeBatch, `[]`, "empty event batch"},
+		{"binary without headers", "application/json", `{}`, "missing specversion"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/ingest/cloudevents/orders", nil)
+			r.Header.Set("Content-Type", tt.contentType)
+			_, err := Decode(r, []byte(tt.body))
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestEvent_MirroredRequest(t *testing.T) {
+	receivedAt := time.Date(2026, 3, 4, 5, 0, 0, 0, time.UTC)
+	e := &Event{
+		CloudEvent: capture.CloudEvent{
+			SpecVersion:     "1.0",
+			ID:              "evt-1",
+			Source:          "/orders",
+			Type:            "created",
+			Time:            "2026-03-04T05:06:07Z",
+			DataContentType: "application/json",
+			Extensions:      map[string]string{"traceparent": "00-abc-01"},
+		},
+		Data: []byte(`{"total":42}`),
+	}
+
+	req := e.MirroredRequest("/webhooks", receivedAt)
+	require.NoError(t, validation.Validate(&capture.IngestRequest{StreamID: "orders", Request: req}, receivedAt, validation.Bounds{}))
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "/webhooks", req.Path)
+	assert.Equal(t, "evt-1", req.RequestId)
+	assert.Equal(t, time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC).UnixNano(), req.TimestampNs)
+	assert.Empty(t, req.ServerGenerated)
+	assert.Equal(t, `{"total":42}`, req.Body)
+	assert.Equal(t, map[string]string{
+		"ce-specversion": "1.0",
+		"ce-id":          "evt-1",
+		"ce-source":      "/orders",
+		"ce-type":        "created",
+		"ce-time":        "2026-03-04T05:06:07Z",
+		"ce-traceparent": "00-abc-01",
+		"content-type":   "application/json",
+	}, req.Headers)
+	require.NotNil(t, req.CloudEvent)
+	assert.Equal(t, "/orders", req.CloudEvent.Source)
+
+	t.Run("binary data and no time", func(t *testing.T) {
+		e := &Event{CloudEvent: capture.CloudEvent{SpecVersion: "1.0", ID: "evt-2", Source: "/s", Type: "t"}, Data: []byte{0xff, 0x00}}
+		req := e.MirroredRequest("/", receivedAt)
+		assert.Equal(t, capture.BodyEncodingBase64, req.BodyEncoding)
+		assert.Equal(t, "/wA=", req.Body)
+		assert.Equal(t, receivedAt.UnixNano(), req.TimestampNs)
+		assert.Equal(t, []string{capture.GeneratedTimestamp}, req.ServerGenerated)
+	})
+}
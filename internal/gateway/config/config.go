@@ -0,0 +1,538 @@
+// Package config holds ingest gateway configuration that extends the shared
+// gateway.GatewayBaseConfig from frkr-common.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/broker"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/clockskew"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/durability"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/encryption"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/envelope"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/limits"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/ordering"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/partition"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/redact"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/validation"
+)
+
+// IngestConfig holds ingest gateway specific configuration
+type IngestConfig struct {
+	// DeadLetterTopic receives messages that could not be published or were
+	// rejected. Empty disables dead-lettering.
+	DeadLetterTopic string
+
+	// Retry controls retries of retriable broker errors when publishing
+	Retry broker.RetryPolicy
+
+	// AutoCreateTopics creates missing stream topics on first publish. When
+	// disabled, publishing to a missing topic is rejected with 409 Conflict.
+	AutoCreateTopics bool
+
+	// TopicDefaults are used for auto-created topics unless the stream's
+	// settings override them. Name and RetentionMs are set per stream.
+	TopicDefaults broker.TopicSpec
+
+	// GatewayInstanceID identifies this gateway replica in record metadata.
+	// Defaults to the hostname (the pod name on Kubernetes).
+	GatewayInstanceID string
+
+	// DisabledRecordHeaders lists metadata record headers (e.g.
+	// "frkr-auth-user") that should not be attached to published records
+	DisabledRecordHeaders []string
+
+	// RedactHeaders lists headers of captured requests and responses whose
+	// values are masked before publishing, matched case-insensitively
+	RedactHeaders []string
+
+	// RecordFormat is the default record value format for streams that don't
+	// set one: "envelope" (versioned envelope) or "legacy" (bare JSON)
+	RecordFormat string
+
+	// BlobStoreURL is where claim-checked request bodies are stored, e.g.
+	// file:///var/lib/frkr/blobs. Empty disables claim checks.
+	BlobStoreURL string
+
+	// ClaimCheckThresholdBytes is the default body size above which bodies
+	// are offloaded to the blob store. 0 disables offloading by default.
+	ClaimCheckThresholdBytes int64
+
+	// ClaimCheckMaxBytes is the largest body that is offloaded to the blob
+	// store; larger bodies are rejected with 413. 0 means no maximum.
+	ClaimCheckMaxBytes int64
+
+	// Limits are the default request size limits; requests exceeding them
+	// are rejected with 413. Streams can override them, but a stream's
+	// MaxRequestBytes can only lower the limit since the request size is
+	// enforced while reading, before the stream is known.
+	Limits limits.Limits
+
+	// ResponseCorrelationSize and ResponseCorrelationTTL bound how many
+	// recent requests are remembered, and for how long, so that responses
+	// sent to POST /ingest/response follow their request's routing
+	ResponseCorrelationSize int
+	ResponseCorrelationTTL  time.Duration
+
+	// MirrorPort, when set, serves a mirror sink bound to MirrorStream on a
+	// dedicated port, capturing every request sent to it
+	MirrorPort   int
+	MirrorStream string
+
+	// MirrorMaxInFlight bounds mirrored requests being published in the
+	// background; more are dropped. MirrorTimeout bounds each publish.
+	MirrorMaxInFlight int
+	MirrorTimeout     time.Duration
+
+	// EnvoyGRPCPort, when set, serves Envoy's ext_proc and tap sink gRPC
+	// services. EnvoyStream is the stream for calls without x-frkr-stream
+	// metadata.
+	EnvoyGRPCPort int
+	EnvoyStream   string
+
+	// Durability is the default durability mode and the modes callers may
+	// choose with X-Frkr-Durability, unless a stream overrides them
+	Durability durability.Policy
+
+	// AsyncBatchSize and AsyncBatchTimeout bound the batches async-mode
+	// records are published in
+	AsyncBatchSize    int
+	AsyncBatchTimeout time.Duration
+
+	// PartitionKey is the default partition key strategy and
+	// PartitionBalancer the default balancer, unless a stream overrides them
+	PartitionKey      string
+	PartitionBalancer string
+
+	// Ordering is the default ordering mode and OrderingWindow how long
+	// reorder mode waits for missing sequence numbers, unless a stream
+	// overrides them
+	Ordering       string
+	OrderingWindow time.Duration
+
+	// Encryption is the default record value encryption (none or aes-gcm)
+	// for streams that don't set one
+	Encryption string
+
+	// SigningKeyID is the SecretPlugin signing key published records are
+	// signed with. Empty disables signing. SigningRetiredKeyIDs are earlier
+	// keys whose public keys stay published for verifying older records.
+	SigningKeyID         string
+	SigningRetiredKeyIDs []string
+
+	// TimestampBounds bounds how far a POST /ingest request's timestamp may
+	// be from the time it is received; requests outside them are rejected
+	TimestampBounds validation.Bounds
+
+	// ClockSkew is what is done with request timestamps skewed from the
+	// receive time: records beyond its threshold are annotated and, when
+	// correction is on, get the receive time as their timestamp
+	ClockSkew clockskew.Policy
+}
+
+// DefaultMaxRequestBytes is the default maximum size of an ingest request body
+const DefaultMaxRequestBytes = 10 << 20
+
+// Default returns the default ingest configuration
+func Default() *IngestConfig {
+	return &IngestConfig{
+		Retry:            broker.DefaultRetryPolicy(),
+		AutoCreateTopics: true,
+		TopicDefaults: broker.TopicSpec{
+			Partitions:        1,
+			ReplicationFactor: 1,
+		},
+		GatewayInstanceID: hostname(),
+		RecordFormat:      envelope.FormatLegacy,
+		RedactHeaders:     redact.DefaultHeaders,
+		Limits: limits.Limits{
+			MaxRequestBytes: DefaultMaxRequestBytes,
+		},
+		ResponseCorrelationSize: 100000,
+		ResponseCorrelationTTL:  5 * time.Minute,
+		MirrorMaxInFlight:       1000,
+		MirrorTimeout:           30 * time.Second,
+		Durability:              durability.Policy{Default: durability.Leader},
+		AsyncBatchSize:          100,
+		AsyncBatchTimeout:       10 * time.Millisecond,
+		PartitionKey:            partition.KeyRequestID,
+		PartitionBalancer:       partition.BalancerLeastBytes,
+		Ordering:                ordering.Off,
+		OrderingWindow:          100 * time.Millisecond,
+		Encryption:              encryption.None,
+		TimestampBounds: validation.Bounds{
+			MaxAge:   24 * time.Hour,
+			MaxAhead: 5 * time.Minute,
+		},
+		ClockSkew: clockskew.Policy{Threshold: 30 * time.Second},
+	}
+}
+
+// hostname returns the host name, or "unknown" if it can't be determined
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// splitList splits a comma-separated flag or environment value
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// redactList parses the headers to redact, where "none" disables redaction
+func redactList(v string) []string {
+	if strings.EqualFold(strings.TrimSpace(v), "none") {
+		return []string{}
+	}
+	return splitList(v)
+}
+
+// RegisterFlags defines the ingest flags on fs and returns the config they are
+// bound to. Flags must be registered before the command line is parsed (e.g.
+// before gateway.LoadConfigFromFlags) for their values to take effect.
+func RegisterFlags(fs *flag.FlagSet) *IngestConfig {
+	cfg := Default()
+	fs.StringVar(&cfg.DeadLetterTopic, "dead-letter-topic", cfg.DeadLetterTopic, "Topic for messages that could not be published (can use DEAD_LETTER_TOPIC env var instead)")
+	fs.IntVar(&cfg.Retry.MaxAttempts, "publish-max-attempts", cfg.Retry.MaxAttempts, "Maximum publish attempts for retriable broker errors (can use PUBLISH_MAX_ATTEMPTS env var instead)")
+	fs.DurationVar(&cfg.Retry.InitialBackoff, "publish-initial-backoff", cfg.Retry.InitialBackoff, "Backoff before the first publish retry (can use PUBLISH_INITIAL_BACKOFF env var instead)")
+	fs.DurationVar(&cfg.Retry.MaxBackoff, "publish-max-backoff", cfg.Retry.MaxBackoff, "Maximum backoff between publish retries (can use PUBLISH_MAX_BACKOFF env var instead)")
+	fs.BoolVar(&cfg.AutoCreateTopics, "auto-create-topics", cfg.AutoCreateTopics, "Create missing stream topics on first publish (can use AUTO_CREATE_TOPICS env var instead)")
+	fs.IntVar(&cfg.TopicDefaults.Partitions, "topic-partitions", cfg.TopicDefaults.Partitions, "Default partition count for auto-created topics (can use TOPIC_PARTITIONS env var instead)")
+	fs.IntVar(&cfg.TopicDefaults.ReplicationFactor, "topic-replication-factor", cfg.TopicDefaults.ReplicationFactor, "Default replication factor for auto-created topics (can use TOPIC_REPLICATION_FACTOR env var instead)")
+	fs.StringVar(&cfg.TopicDefaults.CleanupPolicy, "topic-cleanup-policy", cfg.TopicDefaults.CleanupPolicy, "Default cleanup.policy for auto-created topics (can use TOPIC_CLEANUP_POLICY env var instead)")
+	fs.StringVar(&cfg.TopicDefaults.Compression, "topic-compression", cfg.TopicDefaults.Compression, "Default compression.type for auto-created topics (can use TOPIC_COMPRESSION env var instead)")
+	fs.StringVar(&cfg.GatewayInstanceID, "gateway-instance-id", cfg.GatewayInstanceID, "Gateway instance ID attached to published records (can use GATEWAY_INSTANCE_ID env var instead)")
+	fs.Func("disable-record-headers", "Comma-separated record headers not to attach to published records (can use DISABLE_RECORD_HEADERS env var instead)", func(v string) error {
+		cfg.DisabledRecordHeaders = splitList(v)
+		return nil
+	})
+	fs.Func("redact-headers", "Comma-separated headers whose values are masked in captured requests and responses, or none (default "+strings.Join(redact.DefaultHeaders, ",")+"; can use REDACT_HEADERS env var instead)", func(v string) error {
+		cfg.RedactHeaders = redactList(v)
+		return nil
+	})
+	fs.StringVar(&cfg.BlobStoreURL, "blob-store-url", cfg.BlobStoreURL, "Blob store for claim-checked request bodies, e.g. file:///var/lib/frkr/blobs (can use BLOB_STORE_URL env var instead)")
+	fs.Int64Var(&cfg.ClaimCheckThresholdBytes, "claim-check-threshold", cfg.ClaimCheckThresholdBytes, "Default body size in bytes above which bodies are offloaded to the blob store, 0 to disable (can use CLAIM_CHECK_THRESHOLD env var instead)")
+	fs.Int64Var(&cfg.ClaimCheckMaxBytes, "claim-check-max-bytes", cfg.ClaimCheckMaxBytes, "Largest body size in bytes offloaded to the blob store; larger bodies are rejected with 413, 0 for no maximum (can use CLAIM_CHECK_MAX_BYTES env var instead)")
+	fs.Int64Var(&cfg.Limits.MaxRequestBytes, "max-request-bytes", cfg.Limits.MaxRequestBytes, "Maximum ingest request size in bytes, 0 for no limit (can use MAX_REQUEST_BYTES env var instead)")
+	fs.IntVar(&cfg.Limits.MaxHeaderCount, "max-header-count", cfg.Limits.MaxHeaderCount, "Default maximum number of mirrored request headers, 0 for no limit (can use MAX_HEADER_COUNT env var instead)")
+	fs.Int64Var(&cfg.Limits.MaxHeaderBytes, "max-header-bytes", cfg.Limits.MaxHeaderBytes, "Default maximum total size of mirrored request headers in bytes, 0 for no limit (can use MAX_HEADER_BYTES env var instead)")
+	fs.Int64Var(&cfg.Limits.MaxBodyBytes, "max-body-bytes", cfg.Limits.MaxBodyBytes, "Default maximum mirrored request body size in bytes, 0 for no limit (can use MAX_BODY_BYTES env var instead)")
+	fs.IntVar(&cfg.ResponseCorrelationSize, "response-correlation-size", cfg.ResponseCorrelationSize, "Recent requests remembered for routing late responses, 0 to disable (can use RESPONSE_CORRELATION_SIZE env var instead)")
+	fs.DurationVar(&cfg.ResponseCorrelationTTL, "response-correlation-ttl", cfg.ResponseCorrelationTTL, "How long requests are remembered for routing late responses (can use RESPONSE_CORRELATION_TTL env var instead)")
+	fs.IntVar(&cfg.MirrorPort, "mirror-port", cfg.MirrorPort, "Port for a mirror sink bound to --mirror-stream, 0 to disable (can use MIRROR_PORT env var instead)")
+	fs.StringVar(&cfg.MirrorStream, "mirror-stream", cfg.MirrorStream, "Stream that traffic sent to --mirror-port is captured for (can use MIRROR_STREAM env var instead)")
+	fs.IntVar(&cfg.MirrorMaxInFlight, "mirror-max-in-flight", cfg.MirrorMaxInFlight, "Maximum mirrored requests published concurrently; more are dropped (can use MIRROR_MAX_IN_FLIGHT env var instead)")
+	fs.DurationVar(&cfg.MirrorTimeout, "mirror-timeout", cfg.MirrorTimeout, "Timeout for publishing a mirrored request (can use MIRROR_TIMEOUT env var instead)")
+	fs.IntVar(&cfg.EnvoyGRPCPort, "envoy-grpc-port", cfg.EnvoyGRPCPort, "Port for the Envoy ext_proc and tap sink gRPC receiver, 0 to disable (can use ENVOY_GRPC_PORT env var instead)")
+	fs.StringVar(&cfg.EnvoyStream, "envoy-stream", cfg.EnvoyStream, "Stream for Envoy traffic sent without x-frkr-stream metadata (can use ENVOY_STREAM env var instead)")
+	fs.StringVar(&cfg.Durability.Default, "durability", cfg.Durability.Default, "Default durability mode: async, leader or all (can use DURABILITY env var instead)")
+	fs.StringVar(&cfg.Durability.Min, "durability-min", cfg.Durability.Min, "Weakest durability mode callers may request, empty for no bound (can use DURABILITY_MIN env var instead)")
+	fs.StringVar(&cfg.Durability.Max, "durability-max", cfg.Durability.Max, "Strongest durability mode callers may request, empty for no bound (can use DURABILITY_MAX env var instead)")
+	fs.IntVar(&cfg.AsyncBatchSize, "async-batch-size", cfg.AsyncBatchSize, "Maximum records per batch in async durability mode (can use ASYNC_BATCH_SIZE env var instead)")
+	fs.DurationVar(&cfg.AsyncBatchTimeout, "async-batch-timeout", cfg.AsyncBatchTimeout, "Maximum time records wait to fill a batch in async durability mode (can use ASYNC_BATCH_TIMEOUT env var instead)")
+	fs.StringVar(&cfg.PartitionKey, "partition-key", cfg.PartitionKey, "Default partition key: request_id, client_ip, null, header:<name> or path:<template> (can use PARTITION_KEY env var instead)")
+	fs.StringVar(&cfg.PartitionBalancer, "partition-balancer", cfg.PartitionBalancer, "Default partition balancer: hash, murmur2 or least-bytes (can use PARTITION_BALANCER env var instead)")
+	fs.StringVar(&cfg.Ordering, "ordering", cfg.Ordering, "Default ordering mode: off, flag or reorder (can use ORDERING env var instead)")
+	fs.DurationVar(&cfg.OrderingWindow, "ordering-window", cfg.OrderingWindow, "How long reorder mode waits for missing sequence numbers (can use ORDERING_WINDOW env var instead)")
+	fs.StringVar(&cfg.Encryption, "encryption", cfg.Encryption, "Default record value encryption for streams without one: none or aes-gcm (can use ENCRYPTION env var instead)")
+	fs.StringVar(&cfg.SigningKeyID, "signing-key-id", cfg.SigningKeyID, "SecretPlugin Ed25519 key published records are signed with, empty to disable signing (can use SIGNING_KEY_ID env var instead)")
+	fs.Func("signing-retired-key-ids", "Comma-separated earlier signing keys still published for verification (can use SIGNING_RETIRED_KEY_IDS env var instead)", func(v string) error {
+		cfg.SigningRetiredKeyIDs = splitList(v)
+		return nil
+	})
+	fs.DurationVar(&cfg.TimestampBounds.MaxAge, "max-timestamp-age", cfg.TimestampBounds.MaxAge, "How far in the past request timestamps may be, 0 for no bound (can use MAX_TIMESTAMP_AGE env var instead)")
+	fs.DurationVar(&cfg.TimestampBounds.MaxAhead, "max-timestamp-ahead", cfg.TimestampBounds.MaxAhead, "How far in the future request timestamps may be, 0 for no bound (can use MAX_TIMESTAMP_AHEAD env var instead)")
+	fs.DurationVar(&cfg.ClockSkew.Threshold, "clock-skew-threshold", cfg.ClockSkew.Threshold, "Request timestamp skew from the receive time above which records are annotated, 0 to disable (can use CLOCK_SKEW_THRESHOLD env var instead)")
+	fs.BoolVar(&cfg.ClockSkew.Correct, "clock-skew-correction", cfg.ClockSkew.Correct, "Replace request timestamps skewed beyond --clock-skew-threshold with the receive time (can use CLOCK_SKEW_CORRECTION env var instead)")
+	fs.StringVar(&cfg.RecordFormat, "record-format", cfg.RecordFormat, "Default record format for streams without one: envelope or legacy (can use RECORD_FORMAT env var instead)")
+	return cfg
+}
+
+// LoadConfig applies environment variable overrides (12-factor app pattern).
+// It fails on the first value that can't be parsed, like flag parsing does.
+func LoadConfig(cfg *IngestConfig) error {
+	if topic := os.Getenv("DEAD_LETTER_TOPIC"); topic != "" {
+		cfg.DeadLetterTopic = topic
+	}
+	if err := envInt("PUBLISH_MAX_ATTEMPTS", &cfg.Retry.MaxAttempts); err != nil {
+		return err
+	}
+	if err := envDuration("PUBLISH_INITIAL_BACKOFF", &cfg.Retry.InitialBackoff); err != nil {
+		return err
+	}
+	if err := envDuration("PUBLISH_MAX_BACKOFF", &cfg.Retry.MaxBackoff); err != nil {
+		return err
+	}
+	if err := envBool("AUTO_CREATE_TOPICS", &cfg.AutoCreateTopics); err != nil {
+		return err
+	}
+	if err := envInt("TOPIC_PARTITIONS", &cfg.TopicDefaults.Partitions); err != nil {
+		return err
+	}
+	if err := envInt("TOPIC_REPLICATION_FACTOR", &cfg.TopicDefaults.ReplicationFactor); err != nil {
+		return err
+	}
+	if v := os.Getenv("TOPIC_CLEANUP_POLICY"); v != "" {
+		cfg.TopicDefaults.CleanupPolicy = v
+	}
+	if v := os.Getenv("TOPIC_COMPRESSION"); v != "" {
+		cfg.TopicDefaults.Compression = v
+	}
+	if v := os.Getenv("GATEWAY_INSTANCE_ID"); v != "" {
+		cfg.GatewayInstanceID = v
+	}
+	if v := os.Getenv("DISABLE_RECORD_HEADERS"); v != "" {
+		cfg.DisabledRecordHeaders = splitList(v)
+	}
+	if v := os.Getenv("REDACT_HEADERS"); v != "" {
+		cfg.RedactHeaders = redactList(v)
+	}
+	if v := os.Getenv("RECORD_FORMAT"); v != "" {
+		cfg.RecordFormat = v
+	}
+	if v := os.Getenv("BLOB_STORE_URL"); v != "" {
+		cfg.BlobStoreURL = v
+	}
+	if err := envInt64("CLAIM_CHECK_THRESHOLD", &cfg.ClaimCheckThresholdBytes); err != nil {
+		return err
+	}
+	if err := envInt64("CLAIM_CHECK_MAX_BYTES", &cfg.ClaimCheckMaxBytes); err != nil {
+		return err
+	}
+	if err := envInt64("MAX_REQUEST_BYTES", &cfg.Limits.MaxRequestBytes); err != nil {
+		return err
+	}
+	if err := envInt("MAX_HEADER_COUNT", &cfg.Limits.MaxHeaderCount); err != nil {
+		return err
+	}
+	if err := envInt64("MAX_HEADER_BYTES", &cfg.Limits.MaxHeaderBytes); err != nil {
+		return err
+	}
+	if err := envInt64("MAX_BODY_BYTES", &cfg.Limits.MaxBodyBytes); err != nil {
+		return err
+	}
+	if err := envInt("RESPONSE_CORRELATION_SIZE", &cfg.ResponseCorrelationSize); err != nil {
+		return err
+	}
+	if err := envDuration("RESPONSE_CORRELATION_TTL", &cfg.ResponseCorrelationTTL); err != nil {
+		return err
+	}
+	if err := envInt("MIRROR_PORT", &cfg.MirrorPort); err != nil {
+		return err
+	}
+	if v := os.Getenv("MIRROR_STREAM"); v != "" {
+		cfg.MirrorStream = v
+	}
+	if err := envInt("MIRROR_MAX_IN_FLIGHT", &cfg.MirrorMaxInFlight); err != nil {
+		return err
+	}
+	if err := envDuration("MIRROR_TIMEOUT", &cfg.MirrorTimeout); err != nil {
+		return err
+	}
+	if err := envInt("ENVOY_GRPC_PORT", &cfg.EnvoyGRPCPort); err != nil {
+		return err
+	}
+	if v := os.Getenv("ENVOY_STREAM"); v != "" {
+		cfg.EnvoyStream = v
+	}
+	if v := os.Getenv("DURABILITY"); v != "" {
+		cfg.Durability.Default = v
+	}
+	if v := os.Getenv("DURABILITY_MIN"); v != "" {
+		cfg.Durability.Min = v
+	}
+	if v := os.Getenv("DURABILITY_MAX"); v != "" {
+		cfg.Durability.Max = v
+	}
+	if err := envInt("ASYNC_BATCH_SIZE", &cfg.AsyncBatchSize); err != nil {
+		return err
+	}
+	if err := envDuration("ASYNC_BATCH_TIMEOUT", &cfg.AsyncBatchTimeout); err != nil {
+		return err
+	}
+	if v := os.Getenv("PARTITION_KEY"); v != "" {
+		cfg.PartitionKey = v
+	}
+	if v := os.Getenv("PARTITION_BALANCER"); v != "" {
+		cfg.PartitionBalancer = v
+	}
+	if v := os.Getenv("ENCRYPTION"); v != "" {
+		cfg.Encryption = v
+	}
+	if v := os.Getenv("SIGNING_KEY_ID"); v != "" {
+		cfg.SigningKeyID = v
+	}
+	if v := os.Getenv("SIGNING_RETIRED_KEY_IDS"); v != "" {
+		cfg.SigningRetiredKeyIDs = splitList(v)
+	}
+	if v := os.Getenv("ORDERING"); v != "" {
+		cfg.Ordering = v
+	}
+	if err := envDuration("ORDERING_WINDOW", &cfg.OrderingWindow); err != nil {
+		return err
+	}
+	if err := envDuration("MAX_TIMESTAMP_AGE", &cfg.TimestampBounds.MaxAge); err != nil {
+		return err
+	}
+	if err := envDuration("MAX_TIMESTAMP_AHEAD", &cfg.TimestampBounds.MaxAhead); err != nil {
+		return err
+	}
+	if err := envDuration("CLOCK_SKEW_THRESHOLD", &cfg.ClockSkew.Threshold); err != nil {
+		return err
+	}
+	if err := envBool("CLOCK_SKEW_CORRECTION", &cfg.ClockSkew.Correct); err != nil {
+		return err
+	}
+	return nil
+}
+
+// envInt sets dst from an integer environment variable, if set
+func envInt(name string, dst *int) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return envError(name, v, err)
+	}
+	*dst = n
+	return nil
+}
+
+// envInt64 sets dst from an integer environment variable, if set
+func envInt64(name string, dst *int64) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return envError(name, v, err)
+	}
+	*dst = n
+	return nil
+}
+
+// envDuration sets dst from a duration environment variable, if set
+func envDuration(name string, dst *time.Duration) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return envError(name, v, err)
+	}
+	*dst = d
+	return nil
+}
+
+// envBool sets dst from a boolean environment variable, if set
+func envBool(name string, dst *bool) error {
+	v := os.Getenv(name)
+	if v == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return envError(name, v, err)
+	}
+	*dst = b
+	return nil
+}
+
+// envError reports an unparsable environment variable in the style of the
+// flag package's errors
+func envError(name, value string, err error) error {
+	var numErr *strconv.NumError
+	if errors.As(err, &numErr) {
+		err = numErr.Err
+	}
+	return fmt.Errorf("invalid value %q for env var %s: %w", value, name, err)
+}
+
+// Validate checks the configuration for invalid values
+func (cfg *IngestConfig) Validate() error {
+	if _, err := envelope.ParseFormat(cfg.RecordFormat); err != nil {
+		return err
+	}
+	if cfg.ClaimCheckThresholdBytes > 0 && cfg.BlobStoreURL == "" {
+		return fmt.Errorf("--claim-check-threshold requires --blob-store-url")
+	}
+	if cfg.ClaimCheckMaxBytes < 0 {
+		return fmt.Errorf("--claim-check-max-bytes cannot be negative")
+	}
+	if cfg.ClaimCheckMaxBytes > 0 && cfg.ClaimCheckMaxBytes < cfg.ClaimCheckThresholdBytes {
+		return fmt.Errorf("--claim-check-max-bytes must be at least --claim-check-threshold")
+	}
+	if cfg.MirrorPort > 0 && cfg.MirrorStream == "" {
+		return fmt.Errorf("--mirror-port requires --mirror-stream")
+	}
+	if cfg.MirrorMaxInFlight <= 0 {
+		return fmt.Errorf("--mirror-max-in-flight must be positive")
+	}
+	for _, mode := range []*string{&cfg.Durability.Default, &cfg.Durability.Min, &cfg.Durability.Max} {
+		if *mode == "" {
+			continue
+		}
+		parsed, err := durability.Parse(*mode)
+		if err != nil {
+			return err
+		}
+		*mode = parsed
+	}
+	if err := cfg.Durability.Validate(); err != nil {
+		return err
+	}
+	if cfg.AsyncBatchSize <= 0 {
+		return fmt.Errorf("--async-batch-size must be positive")
+	}
+	if _, err := partition.ParseStrategy(cfg.PartitionKey); err != nil {
+		return err
+	}
+	balancer, err := partition.ParseBalancer(cfg.PartitionBalancer)
+	if err != nil {
+		return err
+	}
+	cfg.PartitionBalancer = balancer
+	mode, err := ordering.Parse(cfg.Ordering)
+	if err != nil {
+		return err
+	}
+	cfg.Ordering = mode
+	if cfg.OrderingWindow < 0 {
+		return fmt.Errorf("--ordering-window must not be negative")
+	}
+	if cfg.Encryption, err = encryption.Parse(cfg.Encryption); err != nil {
+		return err
+	}
+	if len(cfg.SigningRetiredKeyIDs) > 0 && cfg.SigningKeyID == "" {
+		return fmt.Errorf("--signing-retired-key-ids requires --signing-key-id")
+	}
+	if cfg.TimestampBounds.MaxAge < 0 || cfg.TimestampBounds.MaxAhead < 0 {
+		return fmt.Errorf("--max-timestamp-age and --max-timestamp-ahead must not be negative")
+	}
+	if cfg.ClockSkew.Threshold < 0 {
+		return fmt.Errorf("--clock-skew-threshold must not be negative")
+	}
+	if cfg.ClockSkew.Correct && cfg.ClockSkew.Threshold == 0 {
+		return fmt.Errorf("--clock-skew-correction requires --clock-skew-threshold")
+	}
+	return nil
+}
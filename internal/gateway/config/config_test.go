@@ -0,0 +1,59 @@
+package config
+
+import (
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/redact"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("PUBLISH_MAX_ATTEMPTS", "7")
+	t.Setenv("MAX_BODY_BYTES", "2048")
+	t.Setenv("MIRROR_TIMEOUT", "3s")
+	t.Setenv("AUTO_CREATE_TOPICS", "false")
+	t.Setenv("MIRROR_STREAM", "mirror")
+	t.Setenv("REDACT_HEADERS", "Authorization, X-Session")
+
+	cfg := RegisterFlags(flag.NewFlagSet("test", flag.ContinueOnError))
+	require.NoError(t, LoadConfig(cfg))
+	assert.Equal(t, 7, cfg.Retry.MaxAttempts)
+	assert.Equal(t, int64(2048), cfg.Limits.MaxBodyBytes)
+	assert.Equal(t, 3*time.Second, cfg.MirrorTimeout)
+	assert.False(t, cfg.AutoCreateTopics)
+	assert.Equal(t, "mirror", cfg.MirrorStream)
+	assert.Equal(t, []string{"Authorization", "X-Session"}, cfg.RedactHeaders)
+}
+
+func TestRedactHeaders(t *testing.T) {
+	cfg := RegisterFlags(flag.NewFlagSet("test", flag.ContinueOnError))
+	assert.Equal(t, redact.DefaultHeaders, cfg.RedactHeaders)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg = RegisterFlags(fs)
+	require.NoError(t, fs.Parse([]string{"--redact-headers", "none"}))
+	assert.Empty(t, cfg.RedactHeaders)
+}
+
+func TestLoadConfig_InvalidValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"PUBLISH_MAX_ATTEMPTS", "three", `invalid value "three" for env var PUBLISH_MAX_ATTEMPTS: invalid syntax`},
+		{"MAX_BODY_BYTES", "1MB", `invalid value "1MB" for env var MAX_BODY_BYTES: invalid syntax`},
+		{"MIRROR_TIMEOUT", "5", `invalid value "5" for env var MIRROR_TIMEOUT: time: missing unit in duration "5"`},
+		{"CLOCK_SKEW_CORRECTION", "maybe", `invalid value "maybe" for env var CLOCK_SKEW_CORRECTION: invalid syntax`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+			cfg := RegisterFlags(flag.NewFlagSet("test", flag.ContinueOnError))
+			assert.EqualError(t, LoadConfig(cfg), tt.want)
+		})
+	}
+}
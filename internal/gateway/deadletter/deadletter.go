@@ -0,0 +1,122 @@
+// Package deadletter writes messages that could not be published (or were
+// rejected) to a dead-letter topic, and re-drives them to their original topic.
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Reasons a message is dead-lettered
+const (
+	ReasonPublishFailed    = "publish_failed"
+	ReasonMessageTooLarge  = "message_too_large"
+	ReasonValidationFailed = "validation_failed"
+)
+
+// Record header keys, duplicated from the record body so operators can filter
+// dead letters without decoding them
+const (
+	HeaderReason = "frkr-dlq-reason"
+	HeaderStream = "frkr-dlq-stream"
+)
+
+// Record is the value written to the dead-letter topic
+type Record struct {
+	Reason   string    `json:"reason"`
+	Error    string    `json:"error"`
+	StreamID string    `json:"stream_id,omitempty"`
+	TenantID string    `json:"tenant_id,omitempty"`
+	Topic    string    `json:"topic,omitempty"` // Intended destination; empty if the message never resolved to one
+	Key      []byte    `json:"key,omitempty"`
+	Value    []byte    `json:"value"` // Message that failed, or the raw request body for rejected requests
+	FailedAt time.Time `json:"failed_at"`
+
+	// Headers are the record headers of the failed message, in order and
+	// including repeated keys, so re-driven records are byte-identical
+	Headers []kafka.Header `json:"headers,omitempty"`
+}
+
+// HeadersFromMessage copies record headers for storage in a Record
+func HeadersFromMessage(headers []kafka.Header) []kafka.Header {
+	if len(headers) == 0 {
+		return nil
+	}
+	return append([]kafka.Header(nil), headers...)
+}
+
+// MessageHeaders returns the stored record headers
+func (r *Record) MessageHeaders() []kafka.Header {
+	return r.Headers
+}
+
+// Redrivable reports whether the record can be re-published to its original topic
+func (r *Record) Redrivable() bool {
+	return r.Topic != ""
+}
+
+// Message converts the record to a broker message for the dead-letter topic
+func (r *Record) Message(deadLetterTopic string) (kafka.Message, error) {
+	value, err := json.Marshal(r)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("failed to serialize dead letter: %w", err)
+	}
+	return kafka.Message{
+		Topic: deadLetterTopic,
+		Key:   r.Key,
+		Value: value,
+		Headers: []kafka.Header{
+			{Key: HeaderReason, Value: []byte(r.Reason)},
+			{Key: HeaderStream, Value: []byte(r.StreamID)},
+		},
+	}, nil
+}
+
+// Decode parses a record read from the dead-letter topic
+func Decode(msg kafka.Message) (*Record, error) {
+	var rec Record
+	if err := json.Unmarshal(msg.Value, &rec); err != nil {
+		return nil, fmt.Errorf("invalid dead letter at offset %d: %w", msg.Offset, err)
+	}
+	return &rec, nil
+}
+
+// Publisher writes records to the dead-letter topic
+type Publisher struct {
+	writer *kafka.Writer
+	topic  string
+}
+
+// NewPublisher creates a dead-letter publisher. The writer must not have a
+// fixed Topic since messages carry their own.
+func NewPublisher(writer *kafka.Writer, topic string) *Publisher {
+	return &Publisher{writer: writer, topic: topic}
+}
+
+// Topic returns the dead-letter topic name
+func (p *Publisher) Topic() string {
+	return p.topic
+}
+
+// Publish writes records to the dead-letter topic, stamping FailedAt if unset
+func (p *Publisher) Publish(ctx context.Context, records ...*Record) error {
+	messages := make([]kafka.Message, 0, len(records))
+	for _, rec := range records {
+		if rec.FailedAt.IsZero() {
+			rec.FailedAt = time.Now().UTC()
+		}
+		msg, err := rec.Message(p.topic)
+		if err != nil {
+			return err
+		}
+		messages = append(messages, msg)
+	}
+	if err := p.writer.WriteMessages(ctx, messages...); err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package deadletter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord_MessageRoundTrip(t *testing.T) {
+	failedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec := &Record{
+		Reason:   ReasonPublishFailed,
+		Error:    "broker unavailable",
+		StreamID: "orders",
+		TenantID: "tenant-1",
+		Topic:    "stream-tenant1-orders",
+		Key:      []byte("req-1"),
+		Value:    []byte(`{"method":"GET"}`),
+		FailedAt: failedAt,
+		Headers: []kafka.Header{
+			kafkaHeader("frkr-stream-id", "orders"),
+			kafkaHeader("x-repeated", "1"),
+			kafkaHeader("x-repeated", "2"),
+		},
+	}
+
+	msg, err := rec.Message("frkr-dlq")
+	require.NoError(t, err)
+	assert.Equal(t, "frkr-dlq", msg.Topic)
+	assert.Equal(t, []byte("req-1"), msg.Key)
+	assert.Contains(t, msg.Headers, kafkaHeader(HeaderReason, ReasonPublishFailed))
+	assert.Contains(t, msg.Headers, kafkaHeader(HeaderStream, "orders"))
+
+	decoded, err := Decode(msg)
+	require.NoError(t, err)
+	assert.Equal(t, rec, decoded)
+	assert.True(t, decoded.Redrivable())
+}
+
+func TestRecord_ValidationFailureNotRedrivable(t *testing.T) {
+	rec := &Record{Reason: ReasonValidationFailed, Value: []byte("not json")}
+	assert.False(t, rec.Redrivable())
+}
+
+func TestFilter_Matches(t *testing.T) {
+	now := time.Now()
+	rec := &Record{StreamID: "orders", Reason: ReasonMessageTooLarge, FailedAt: now}
+
+	assert.True(t, (&Filter{}).Matches(rec))
+	assert.True(t, (&Filter{StreamID: "orders", Reason: ReasonMessageTooLarge}).Matches(rec))
+	assert.False(t, (&Filter{StreamID: "billing"}).Matches(rec))
+	assert.False(t, (&Filter{Reason: ReasonPublishFailed}).Matches(rec))
+	assert.True(t, (&Filter{Since: now.Add(-time.Minute)}).Matches(rec))
+	assert.False(t, (&Filter{Since: now.Add(time.Minute)}).Matches(rec))
+}
+
+func kafkaHeader(key, value string) kafka.Header {
+	return kafka.Header{Key: key, Value: []byte(value)}
+}
@@ -0,0 +1,173 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Filter selects which dead letters to inspect or re-drive
+type Filter struct {
+	StreamID string    // Empty matches all streams
+	Reason   string    // Empty matches all reasons
+	Since    time.Time // Zero matches from the start of the topic
+}
+
+// Matches reports whether a record passes the filter
+func (f *Filter) Matches(rec *Record) bool {
+	if f.StreamID != "" && rec.StreamID != f.StreamID {
+		return false
+	}
+	if f.Reason != "" && rec.Reason != f.Reason {
+		return false
+	}
+	if !f.Since.IsZero() && rec.FailedAt.Before(f.Since) {
+		return false
+	}
+	return true
+}
+
+// scanIdleTimeout ends a partition scan when no record arrives for this long
+// before the end offset is reached. Offsets just before the end can be
+// compaction gaps or transaction control records, which are never returned.
+var scanIdleTimeout = 10 * time.Second
+
+// ScanFunc is called for every dead letter that passes the filter
+type ScanFunc func(msg kafka.Message, rec *Record) error
+
+// Scan reads the dead-letter topic up to its current end and calls fn for
+// each matching record. Records appended while scanning are not visited, so
+// re-driving into a topic that fails again can't loop forever.
+func Scan(ctx context.Context, brokerURL, topic string, filter Filter, fn ScanFunc) error {
+	conn, err := kafka.DialContext(ctx, "tcp", brokerURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to broker: %w", err)
+	}
+	partitions, err := conn.ReadPartitions(topic)
+	conn.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read partitions for %s: %w", topic, err)
+	}
+
+	for _, p := range partitions {
+		if err := scanPartition(ctx, brokerURL, topic, p.ID, filter, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanPartition(ctx context.Context, brokerURL, topic string, partition int, filter Filter, fn ScanFunc) error {
+	leader, err := kafka.DialLeader(ctx, "tcp", brokerURL, topic, partition)
+	if err != nil {
+		return fmt.Errorf("failed to connect to partition %d leader: %w", partition, err)
+	}
+	var first int64
+	if filter.Since.IsZero() {
+		first, err = leader.ReadFirstOffset()
+	} else {
+		first, err = leader.ReadOffset(filter.Since)
+	}
+	if err != nil {
+		leader.Close()
+		return fmt.Errorf("failed to read start offset for partition %d: %w", partition, err)
+	}
+	last, err := leader.ReadLastOffset()
+	leader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read end offset for partition %d: %w", partition, err)
+	}
+	if first >= last {
+		return nil
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   []string{brokerURL},
+		Topic:     topic,
+		Partition: partition,
+		MaxWait:   time.Second,
+	})
+	defer reader.Close()
+	if err := reader.SetOffset(first); err != nil {
+		return fmt.Errorf("failed to seek partition %d: %w", partition, err)
+	}
+
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, scanIdleTimeout)
+		msg, err := reader.ReadMessage(readCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				return nil
+			}
+			return fmt.Errorf("failed to read partition %d: %w", partition, err)
+		}
+		if msg.Offset >= last {
+			return nil
+		}
+		rec, err := Decode(msg)
+		if err != nil {
+			return err
+		}
+		if filter.Matches(rec) {
+			if err := fn(msg, rec); err != nil {
+				return err
+			}
+		}
+		if msg.Offset+1 >= last {
+			return nil
+		}
+	}
+}
+
+// errLimitReached stops a scan once the re-drive limit is hit
+var errLimitReached = errors.New("limit reached")
+
+// RedriveSummary counts the outcome of a re-drive
+type RedriveSummary struct {
+	Matched      int
+	Redriven     int
+	Unredrivable int // Records without a destination topic (e.g. validation failures)
+}
+
+// Redrive re-publishes matching dead letters to their original topic. With
+// dryRun set it only counts what would be re-driven. A limit of 0 means no limit.
+func Redrive(ctx context.Context, writer *kafka.Writer, brokerURL, topic string, filter Filter, limit int, dryRun bool, visit ScanFunc) (*RedriveSummary, error) {
+	summary := &RedriveSummary{}
+
+	err := Scan(ctx, brokerURL, topic, filter, func(msg kafka.Message, rec *Record) error {
+		if limit > 0 && summary.Matched >= limit {
+			return errLimitReached
+		}
+		summary.Matched++
+		if visit != nil {
+			if err := visit(msg, rec); err != nil {
+				return err
+			}
+		}
+		if !rec.Redrivable() {
+			summary.Unredrivable++
+			return nil
+		}
+		if dryRun {
+			return nil
+		}
+		if err := writer.WriteMessages(ctx, kafka.Message{
+			Topic:   rec.Topic,
+			Key:     rec.Key,
+			Value:   rec.Value,
+			Headers: rec.MessageHeaders(),
+		}); err != nil {
+			return fmt.Errorf("failed to re-drive offset %d to %s: %w", msg.Offset, rec.Topic, err)
+		}
+		summary.Redriven++
+		return nil
+	})
+	if err != nil && err != errLimitReached {
+		return summary, err
+	}
+	return summary, nil
+}
@@ -0,0 +1,134 @@
+// Package durability defines how long the gateway waits for the broker before
+// accepting a request, and which durability a stream lets callers ask for.
+package durability
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Durability modes, weakest first
+const (
+	// Async responds as soon as the record is queued and publishes it in
+	// the background, batched with other records
+	Async = "async"
+	// Leader waits for the partition leader to acknowledge the record
+	Leader = "leader"
+	// All waits for every in-sync replica to acknowledge the record
+	All = "all"
+)
+
+// Header lets callers choose a durability mode per request
+const Header = "X-Frkr-Durability"
+
+// Modes lists the durability modes, weakest first
+var Modes = []string{Async, Leader, All}
+
+// Parse validates a durability mode, accepting any case
+func Parse(s string) (string, error) {
+	mode := strings.ToLower(strings.TrimSpace(s))
+	if rank(mode) < 0 {
+		return "", fmt.Errorf("invalid durability: %s", s)
+	}
+	return mode, nil
+}
+
+// RequiredAcks returns the broker acknowledgement a mode waits for. Async
+// records are acknowledged by the leader, after the caller has been answered.
+func RequiredAcks(mode string) kafka.RequiredAcks {
+	if mode == All {
+		return kafka.RequireAll
+	}
+	return kafka.RequireOne
+}
+
+// rank orders modes from weakest to strongest; -1 for unknown modes
+func rank(mode string) int {
+	for i, m := range Modes {
+		if m == mode {
+			return i
+		}
+	}
+	return -1
+}
+
+// Policy is the default durability of a stream and the range callers may
+// choose from. Empty fields mean "unset": no bound, or the default of the
+// policy being overridden.
+type Policy struct {
+	Default string
+	Min     string
+	Max     string
+}
+
+// Override returns the policy with o's non-empty fields applied
+func (p Policy) Override(o Policy) Policy {
+	if o.Default != "" {
+		p.Default = o.Default
+	}
+	if o.Min != "" {
+		p.Min = o.Min
+	}
+	if o.Max != "" {
+		p.Max = o.Max
+	}
+	return p
+}
+
+// Validate checks that the modes are valid and the default is within bounds
+func (p Policy) Validate() error {
+	for _, mode := range []string{p.Default, p.Min, p.Max} {
+		if mode != "" && rank(mode) < 0 {
+			return fmt.Errorf("invalid durability: %s", mode)
+		}
+	}
+	if p.Default == "" {
+		return fmt.Errorf("missing default durability")
+	}
+	if !p.allows(p.Default) {
+		return fmt.Errorf("default durability %s is outside %s", p.Default, p.bounds())
+	}
+	return nil
+}
+
+// Resolve returns the mode for a request that asked for requested (empty for
+// the default). Modes outside the policy's bounds are rejected.
+func (p Policy) Resolve(requested string) (string, error) {
+	if strings.TrimSpace(requested) == "" {
+		return p.Default, nil
+	}
+	mode, err := Parse(requested)
+	if err != nil {
+		return "", err
+	}
+	if !p.allows(mode) {
+		return "", fmt.Errorf("durability %s is not allowed for this stream (allowed: %s)", mode, p.bounds())
+	}
+	return mode, nil
+}
+
+// allows reports whether a mode is within the policy's bounds
+func (p Policy) allows(mode string) bool {
+	r := rank(mode)
+	if p.Min != "" && r < rank(p.Min) {
+		return false
+	}
+	if p.Max != "" && r > rank(p.Max) {
+		return false
+	}
+	return true
+}
+
+// bounds describes the policy's range, e.g. "leader..all"
+func (p Policy) bounds() string {
+	lo, hi := p.Min, p.Max
+	if lo == "" {
+		lo = Modes[0]
+	}
+	if hi == "" {
+		hi = Modes[len(Modes)-1]
+	}
+	return lo + ".." + hi
+}
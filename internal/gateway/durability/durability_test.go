@@ -0,0 +1,65 @@
+package durability
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"async", "Leader", " ALL "} {
+		_, err := Parse(s)
+		assert.NoError(t, err, s)
+	}
+	_, err := Parse("quorum")
+	assert.Error(t, err)
+}
+
+func TestRequiredAcks(t *testing.T) {
+	assert.Equal(t, kafka.RequireOne, RequiredAcks(Async))
+	assert.Equal(t, kafka.RequireOne, RequiredAcks(Leader))
+	assert.Equal(t, kafka.RequireAll, RequiredAcks(All))
+}
+
+func TestPolicy_Resolve(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    Policy
+		requested string
+		want      string
+		wantErr   bool
+	}{
+		{"default", Policy{Default: Leader}, "", Leader, false},
+		{"requested", Policy{Default: Leader}, "async", Async, false},
+		{"case insensitive", Policy{Default: Leader}, "ALL", All, false},
+		{"invalid", Policy{Default: Leader}, "quorum", "", true},
+		{"below min", Policy{Default: All, Min: Leader}, "async", "", true},
+		{"above max", Policy{Default: Async, Max: Leader}, "all", "", true},
+		{"at bounds", Policy{Default: Leader, Min: Leader, Max: All}, "all", All, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.Resolve(tt.requested)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPolicy_OverrideAndValidate(t *testing.T) {
+	gateway := Policy{Default: Leader}
+	p := gateway.Override(Policy{Min: Leader, Max: All})
+	assert.Equal(t, Policy{Default: Leader, Min: Leader, Max: All}, p)
+	assert.NoError(t, p.Validate())
+
+	assert.Error(t, Policy{}.Validate())
+	assert.Error(t, Policy{Default: "quorum"}.Validate())
+	assert.Error(t, Policy{Default: Async, Min: Leader}.Validate())
+	assert.Error(t, Policy{Default: Leader, Max: "quorum"}.Validate())
+}
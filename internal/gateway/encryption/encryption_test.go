@@ -0,0 +1,58 @@
+package encryption
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"none", "AES-GCM"} {
+		_, err := Parse(s)
+		assert.NoError(t, err, s)
+	}
+	_, err := Parse("rot13")
+	assert.Error(t, err)
+}
+
+func TestParseKey(t *testing.T) {
+	raw := bytes.Repeat([]byte{7}, 32)
+	key, err := ParseKey(raw)
+	require.NoError(t, err)
+	assert.Equal(t, raw, key)
+
+	key, err = ParseKey([]byte(base64.StdEncoding.EncodeToString(raw[:16]) + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, raw[:16], key)
+
+	_, err = ParseKey([]byte("too short"))
+	assert.Error(t, err)
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	plaintext := []byte(`{"method":"GET","path":"/users/42"}`)
+	aad := []byte("8d3f6a52-0c5e-4a4e-9a53-1d2b3c4d5e6f")
+
+	value, err := Encrypt(key, plaintext, aad)
+	require.NoError(t, err)
+	assert.NotContains(t, string(value), "/users/42")
+
+	again, err := Encrypt(key, plaintext, aad)
+	require.NoError(t, err)
+	assert.NotEqual(t, value, again, "nonces must differ")
+
+	decrypted, err := Decrypt(key, value, aad)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, decrypted)
+
+	_, err = Decrypt(key, value, []byte("other-stream"))
+	assert.Error(t, err, "aad must match")
+	_, err = Decrypt(bytes.Repeat([]byte{2}, 32), value, aad)
+	assert.Error(t, err, "key must match")
+	_, err = Decrypt(key, value[:10], aad)
+	assert.Error(t, err)
+}
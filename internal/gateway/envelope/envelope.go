@@ -0,0 +1,113 @@
+// Package envelope defines the versioned envelope the gateway wraps around
+// published records.
+//
+// Version 1 (schema_version "frkr.envelope.v1") is a JSON object:
+//
+//	{
+//	  "schema_version":   "frkr.envelope.v1",
+//	  "stream":           "<stream name>",
+//	  "tenant_id":        "<tenant id>",
+//	  "received_at":      "<RFC 3339 gateway receive time>",
+//	  "gateway_instance": "<gateway instance id>",
+//	  "payload_type":     "ingest.v1.MirroredRequest",
+//	  "payload_encoding": "json",
+//	  "payload":          <JSON value>,
+//	  "claim_check":      {"field": "body", "uri": "...", "sha256": "...", "size": 0}
+//	}
+//
+// payload_encoding is always "json" in version 1; consumers should reject
+// other values, which are reserved for future payload types.
+//
+// claim_check is only present when a large request body was offloaded to the
+// blob store; the named payload field is then empty and the consumer fetches
+// the content from uri, verifying it against sha256 and size.
+//
+// Consumers must check schema_version before reading any other field and
+// should ignore fields they don't recognise; new fields may be added without
+// a version bump, while removing or changing the meaning of a field will not
+// happen without one.
+package envelope
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SchemaVersion is the current envelope schema version
+const SchemaVersion = "frkr.envelope.v1"
+
+// EncodingJSON is the payload encoding of a payload embedded as a JSON value
+const EncodingJSON = "json"
+
+// Record value formats selectable per stream
+const (
+	FormatEnvelope = "envelope" // Records are wrapped in an Envelope
+	FormatLegacy   = "legacy"   // Records are the bare JSON payload
+)
+
+// ParseFormat validates a record format
+func ParseFormat(s string) (string, error) {
+	switch s {
+	case FormatEnvelope, FormatLegacy:
+		return s, nil
+	default:
+		return "", fmt.Errorf("invalid record format: %s", s)
+	}
+}
+
+// Envelope is the versioned wrapper around a published payload
+type Envelope struct {
+	SchemaVersion   string          `json:"schema_version"`
+	Stream          string          `json:"stream"`
+	TenantID        string          `json:"tenant_id,omitempty"`
+	ReceivedAt      time.Time       `json:"received_at"`
+	GatewayInstance string          `json:"gateway_instance,omitempty"`
+	PayloadType     string          `json:"payload_type"`
+	PayloadEncoding string          `json:"payload_encoding"`
+	Payload         json.RawMessage `json:"payload"`
+	ClaimCheck      *ClaimCheck     `json:"claim_check,omitempty"`
+}
+
+// ClaimCheck references a payload field stored outside the record
+type ClaimCheck struct {
+	Field  string `json:"field"` // Payload field that was offloaded, e.g. "body"
+	URI    string `json:"uri"`
+	SHA256 string `json:"sha256"` // Hex-encoded SHA-256 of the offloaded content
+	Size   int64  `json:"size"`
+}
+
+// New creates a v1 envelope around a JSON payload
+func New(stream, tenantID, gatewayInstance, payloadType string, receivedAt time.Time, payload json.RawMessage) *Envelope {
+	return &Envelope{
+		SchemaVersion:   SchemaVersion,
+		Stream:          stream,
+		TenantID:        tenantID,
+		ReceivedAt:      receivedAt.UTC(),
+		GatewayInstance: gatewayInstance,
+		PayloadType:     payloadType,
+		PayloadEncoding: EncodingJSON,
+		Payload:         payload,
+	}
+}
+
+// Marshal serializes the envelope
+func (e *Envelope) Marshal() ([]byte, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize envelope: %w", err)
+	}
+	return data, nil
+}
+
+// Unmarshal parses an envelope, rejecting unknown schema versions
+func Unmarshal(data []byte) (*Envelope, error) {
+	var e Envelope
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("invalid envelope: %w", err)
+	}
+	if e.SchemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("unsupported envelope schema version: %q", e.SchemaVersion)
+	}
+	return &e, nil
+}
@@ -0,0 +1,39 @@
+package envelope
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvelope_RoundTrip(t *testing.T) {
+	receivedAt := time.Date(2026, 5, 6, 7, 8, 9, 0, time.FixedZone("CET", 3600))
+	payload := json.RawMessage(`{"method":"GET","path":"/api/test"}`)
+
+	env := New("orders", "tenant-1", "gw-0", "ingest.v1.MirroredRequest", receivedAt, payload)
+	data, err := env.Marshal()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"schema_version": "frkr.envelope.v1",
+		"stream": "orders",
+		"tenant_id": "tenant-1",
+		"received_at": "2026-05-06T06:08:09Z",
+		"gateway_instance": "gw-0",
+		"payload_type": "ingest.v1.MirroredRequest",
+		"payload_encoding": "json",
+		"payload": {"method":"GET","path":"/api/test"}
+	}`, string(data))
+
+	decoded, err := Unmarshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, EncodingJSON, decoded.PayloadEncoding)
+	assert.JSONEq(t, string(payload), string(decoded.Payload))
+}
+
+func TestUnmarshal_RejectsUnknownVersion(t *testing.T) {
+	_, err := Unmarshal([]byte(`{"schema_version":"frkr.envelope.v9"}`))
+	assert.ErrorContains(t, err, "unsupported envelope schema version")
+}
@@ -0,0 +1,251 @@
+// Package envoy converts HTTP traffic observed by Envoy, through its external
+// processing (ext_proc) filter or a tap filter's gRPC sink, into mirrored
+// requests.
+package envoy
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tapdatav3 "github.com/envoyproxy/go-control-plane/envoy/data/tap/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+)
+
+// gRPC metadata keys Envoy can be configured to send (initial_metadata of
+// the gRPC service)
+const (
+	MetadataStream        = "x-frkr-stream"
+	MetadataAuthorization = "authorization"
+)
+
+// ErrBodyTooLarge is returned when an exchange's bodies exceed the size limit
+var ErrBodyTooLarge = errors.New("body too large")
+
+// message is one side of an observed HTTP exchange
+type message struct {
+	headers []*corev3.HeaderValue
+	body    []byte
+	at      time.Time // When the headers were observed
+}
+
+// Exchange accumulates the messages Envoy sends over one ext_proc stream,
+// which covers a single HTTP request and its response
+type Exchange struct {
+	maxBodyBytes int64 // 0 means unlimited
+	request      *message
+	response     *message
+	size         int64
+	tooLarge     bool
+	done         bool
+}
+
+// NewExchange creates an exchange that stops buffering bodies once they
+// exceed maxBodyBytes (0 for no limit)
+func NewExchange(maxBodyBytes int64) *Exchange {
+	return &Exchange{maxBodyBytes: maxBodyBytes}
+}
+
+// Add records a processing request observed at now
+func (e *Exchange) Add(req *extprocv3.ProcessingRequest, now time.Time) {
+	switch r := req.Request.(type) {
+	case *extprocv3.ProcessingRequest_RequestHeaders:
+		e.request = &message{headers: r.RequestHeaders.GetHeaders().GetHeaders(), at: now}
+	case *extprocv3.ProcessingRequest_RequestBody:
+		if e.request != nil {
+			e.request.body = e.appendBody(e.request.body, r.RequestBody.GetBody())
+		}
+	case *extprocv3.ProcessingRequest_ResponseHeaders:
+		e.response = &message{headers: r.ResponseHeaders.GetHeaders().GetHeaders(), at: now}
+		e.done = r.ResponseHeaders.GetEndOfStream()
+	case *extprocv3.ProcessingRequest_ResponseBody:
+		if e.response != nil {
+			e.response.body = e.appendBody(e.response.body, r.ResponseBody.GetBody())
+		}
+		e.done = r.ResponseBody.GetEndOfStream()
+	case *extprocv3.ProcessingRequest_ResponseTrailers:
+		e.done = true
+	}
+}
+
+// appendBody appends a body chunk unless the exchange is over its size limit
+func (e *Exchange) appendBody(body, chunk []byte) []byte {
+	e.size += int64(len(chunk))
+	if e.maxBodyBytes > 0 && e.size > e.maxBodyBytes {
+		e.tooLarge = true
+		return nil
+	}
+	return append(body, chunk...)
+}
+
+// Done reports whether the end of the response has been observed
+func (e *Exchange) Done() bool {
+	return e.done
+}
+
+// Request converts the exchange into a mirrored request, including the
+// response when it was observed. Processing modes that skip response
+// messages produce requests without a response.
+func (e *Exchange) Request() (*capture.Request, error) {
+	if e.request == nil {
+		return nil, errors.New("no request headers observed")
+	}
+	if e.tooLarge {
+		return nil, ErrBodyTooLarge
+	}
+	var latency time.Duration
+	if e.response != nil {
+		latency = e.response.at.Sub(e.request.at)
+	}
+	return convert(e.request, e.response, latency)
+}
+
+// Continue returns the reply that lets Envoy carry on unchanged after a
+// processing request, for ext_proc filters not in observability mode
+func Continue(req *extprocv3.ProcessingRequest) *extprocv3.ProcessingResponse {
+	resp := &extprocv3.ProcessingResponse{}
+	switch req.Request.(type) {
+	case *extprocv3.ProcessingRequest_RequestHeaders:
+		resp.Response = &extprocv3.ProcessingResponse_RequestHeaders{RequestHeaders: &extprocv3.HeadersResponse{}}
+	case *extprocv3.ProcessingRequest_RequestBody:
+		resp.Response = &extprocv3.ProcessingResponse_RequestBody{RequestBody: &extprocv3.BodyResponse{}}
+	case *extprocv3.ProcessingRequest_RequestTrailers:
+		resp.Response = &extprocv3.ProcessingResponse_RequestTrailers{RequestTrailers: &extprocv3.TrailersResponse{}}
+	case *extprocv3.ProcessingRequest_ResponseHeaders:
+		resp.Response = &extprocv3.ProcessingResponse_ResponseHeaders{ResponseHeaders: &extprocv3.HeadersResponse{}}
+	case *extprocv3.ProcessingRequest_ResponseBody:
+		resp.Response = &extprocv3.ProcessingResponse_ResponseBody{ResponseBody: &extprocv3.BodyResponse{}}
+	case *extprocv3.ProcessingRequest_ResponseTrailers:
+		resp.Response = &extprocv3.ProcessingResponse_ResponseTrailers{ResponseTrailers: &extprocv3.TrailersResponse{}}
+	}
+	return resp
+}
+
+// FromBufferedTrace converts a tap filter's buffered HTTP trace into a
+// mirrored request with its response. receivedAt is used when the trace has
+// no header timestamps.
+func FromBufferedTrace(trace *tapdatav3.HttpBufferedTrace, receivedAt time.Time) (*capture.Request, error) {
+	if trace.GetRequest() == nil {
+		return nil, errors.New("trace has no request")
+	}
+	req := traceMessage(trace.GetRequest(), receivedAt)
+	var resp *message
+	var latency time.Duration
+	if trace.GetResponse() != nil {
+		resp = traceMessage(trace.GetResponse(), req.at)
+		latency = resp.at.Sub(req.at)
+	}
+	return convert(req, resp, latency)
+}
+
+// traceMessage converts one side of a buffered trace
+func traceMessage(m *tapdatav3.HttpBufferedTrace_Message, fallback time.Time) *message {
+	msg := &message{headers: m.GetHeaders(), at: fallback}
+	if ts := m.GetHeadersReceivedTime(); ts != nil {
+		msg.at = ts.AsTime()
+	}
+	if body := m.GetBody(); body != nil {
+		if b := body.GetAsBytes(); b != nil {
+			msg.body = b
+		} else {
+			msg.body = []byte(body.GetAsString())
+		}
+	}
+	return msg
+}
+
+// convert builds a mirrored request from observed request and response
+// messages. Envoy's x-request-id, when present, is the request ID.
+func convert(reqMsg, respMsg *message, latency time.Duration) (*capture.Request, error) {
+	pseudo, headers := splitHeaders(reqMsg.headers)
+	if pseudo[":method"] == "" || pseudo[":path"] == "" {
+		return nil, errors.New("request is missing :method or :path")
+	}
+	if _, ok := headers["host"]; !ok && pseudo[":authority"] != "" {
+		headers["host"] = pseudo[":authority"]
+	}
+
+	path, rawQuery, _ := strings.Cut(pseudo[":path"], "?")
+	var query map[string]string
+	if rawQuery != "" {
+		values, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return nil, fmt.Errorf("invalid query: %w", err)
+		}
+		query = make(map[string]string, len(values))
+		for name, v := range values {
+			query[name] = strings.Join(v, ",")
+		}
+	}
+
+	req := &capture.Request{
+		MirroredRequest: &ingestv1.MirroredRequest{
+			Method:      pseudo[":method"],
+			Path:        path,
+			Headers:     headers,
+			Query:       query,
+			TimestampNs: reqMsg.at.UnixNano(),
+			RequestId:   headers["x-request-id"],
+		},
+		ContentType: headers["content-type"],
+	}
+	req.FillMissing(reqMsg.at)
+	req.Body, req.BodyEncoding = encodeBody(reqMsg.body)
+
+	if respMsg != nil {
+		pseudo, headers := splitHeaders(respMsg.headers)
+		status, err := strconv.Atoi(pseudo[":status"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid response :status %q", pseudo[":status"])
+		}
+		resp := &capture.Response{
+			Status:      status,
+			Headers:     headers,
+			ContentType: headers["content-type"],
+			LatencyNs:   max(latency, 0).Nanoseconds(),
+		}
+		resp.Body, resp.BodyEncoding = encodeBody(respMsg.body)
+		req.Response = resp
+	}
+
+	return req, nil
+}
+
+// splitHeaders separates HTTP/2 pseudo-headers from regular headers. Names
+// are lowercased and repeated headers joined with ", ".
+func splitHeaders(values []*corev3.HeaderValue) (pseudo, headers map[string]string) {
+	pseudo = map[string]string{}
+	headers = map[string]string{}
+	for _, h := range values {
+		name := strings.ToLower(h.GetKey())
+		value := h.GetValue()
+		if len(h.GetRawValue()) > 0 {
+			value = string(h.GetRawValue())
+		}
+		target := headers
+		if strings.HasPrefix(name, ":") {
+			target = pseudo
+		}
+		if prev, ok := target[name]; ok {
+			value = prev + ", " + value
+		}
+		target[name] = value
+	}
+	return pseudo, headers
+}
+
+// encodeBody returns a body and its encoding, base64-encoding non-UTF-8 bodies
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+	return base64.StdEncoding.EncodeToString(body), capture.BodyEncodingBase64
+}
@@ -0,0 +1,204 @@
+package envoy
+
+import (
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tapdatav3 "github.com/envoyproxy/go-control-plane/envoy/data/tap/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// headerMap builds an ext_proc header map the way Envoy sends it, with
+// values in raw_value
+func headerMap(kv ...string) *corev3.HeaderMap {
+	m := &corev3.HeaderMap{}
+	for i := 0; i < len(kv); i += 2 {
+		m.Headers = append(m.Headers, &corev3.HeaderValue{Key: kv[i], RawValue: []byte(kv[i+1])})
+	}
+	return m
+}
+
+func requestHeaders(m *corev3.HeaderMap, eos bool) *extprocv3.ProcessingRequest {
+	return &extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_RequestHeaders{
+		RequestHeaders: &extprocv3.HttpHeaders{Headers: m, EndOfStream: eos},
+	}}
+}
+
+func responseHeaders(m *corev3.HeaderMap, eos bool) *extprocv3.ProcessingRequest {
+	return &extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_ResponseHeaders{
+		ResponseHeaders: &extprocv3.HttpHeaders{Headers: m, EndOfStream: eos},
+	}}
+}
+
+func requestBody(body string, eos bool) *extprocv3.ProcessingRequest {
+	return &extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_RequestBody{
+		RequestBody: &extprocv3.HttpBody{Body: []byte(body), EndOfStream: eos},
+	}}
+}
+
+func responseBody(body []byte, eos bool) *extprocv3.ProcessingRequest {
+	return &extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_ResponseBody{
+		ResponseBody: &extprocv3.HttpBody{Body: body, EndOfStream: eos},
+	}}
+}
+
+func TestExchange(t *testing.T) {
+	start := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	e := NewExchange(0)
+
+	e.Add(requestHeaders(headerMap(
+		":method", "POST",
+		":path", "/orders?id=1&tag=a&tag=b",
+		":authority", "api.example.com",
+		":scheme", "https",
+		"content-type", "application/json",
+		"x-request-id", "req-1",
+		"accept", "text/html",
+		"accept", "application/json",
+	), false), start)
+	e.Add(requestBody(`{"total":`, false), start)
+	e.Add(requestBody(`42}`, true), start)
+	assert.False(t, e.Done())
+
+	e.Add(responseHeaders(headerMap(":status", "201", "content-type", "image/png"), false), start.Add(25*time.Millisecond))
+	e.Add(responseBody([]byte{0x89, 'P', 'N', 'G'}, true), start.Add(30*time.Millisecond))
+	assert.True(t, e.Done())
+
+	req, err := e.Request()
+	require.NoError(t, err)
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "/orders", req.Path)
+	assert.Equal(t, map[string]string{"id": "1", "tag": "a,b"}, req.Query)
+	assert.Equal(t, "req-1", req.RequestId)
+	assert.Equal(t, start.UnixNano(), req.TimestampNs)
+	assert.Equal(t, `{"total":42}`, req.Body)
+	assert.Empty(t, req.BodyEncoding)
+	assert.Equal(t, "application/json", req.ContentType)
+	assert.Equal(t, map[string]string{
+		"host":         "api.example.com",
+		"content-type": "application/json",
+		"x-request-id": "req-1",
+		"accept":       "text/html, application/json",
+	}, req.Headers)
+
+	require.NotNil(t, req.Response)
+	assert.Equal(t, 201, req.Response.Status)
+	assert.Equal(t, capture.BodyEncodingBase64, req.Response.BodyEncoding)
+	assert.Equal(t, "iVBORw==", req.Response.Body)
+	assert.Equal(t, "image/png", req.Response.ContentType)
+	assert.Equal(t, (25 * time.Millisecond).Nanoseconds(), req.Response.LatencyNs)
+}
+
+func TestExchange_RequestOnly(t *testing.T) {
+	e := NewExchange(0)
+	e.Add(requestHeaders(headerMap(":method", "GET", ":path", "/health"), true), time.Now())
+	assert.False(t, e.Done())
+
+	req, err := e.Request()
+	require.NoError(t, err)
+	assert.Equal(t, "GET", req.Method)
+	assert.NotEmpty(t, req.RequestId)
+	assert.Equal(t, []string{capture.GeneratedRequestID}, req.ServerGenerated)
+	assert.Nil(t, req.Response)
+}
+
+func TestExchange_Errors(t *testing.T) {
+	t.Run("no request headers", func(t *testing.T) {
+		_, err := NewExchange(0).Request()
+		assert.Error(t, err)
+	})
+
+	t.Run("body over limit", func(t *testing.T) {
+		e := NewExchange(4)
+		e.Add(requestHeaders(headerMap(":method", "POST", ":path", "/"), false), time.Now())
+		e.Add(requestBody("12345", true), time.Now())
+		_, err := e.Request()
+		assert.ErrorIs(t, err, ErrBodyTooLarge)
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		e := NewExchange(0)
+		e.Add(requestHeaders(headerMap(":method", "GET"), true), time.Now())
+		_, err := e.Request()
+		assert.Error(t, err)
+	})
+}
+
+func TestContinue(t *testing.T) {
+	tests := []struct {
+		req  *extprocv3.ProcessingRequest
+		want any
+	}{
+		{requestHeaders(nil, false), &extprocv3.ProcessingResponse_RequestHeaders{}},
+		{requestBody("", false), &extprocv3.ProcessingResponse_RequestBody{}},
+		{responseHeaders(nil, false), &extprocv3.ProcessingResponse_ResponseHeaders{}},
+		{responseBody(nil, false), &extprocv3.ProcessingResponse_ResponseBody{}},
+	}
+	for _, tt := range tests {
+		resp := Continue(tt.req)
+		assert.IsType(t, tt.want, resp.Response)
+	}
+}
+
+func TestFromBufferedTrace(t *testing.T) {
+	start := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	trace := &tapdatav3.HttpBufferedTrace{
+		Request: &tapdatav3.HttpBufferedTrace_Message{
+			Headers: []*corev3.HeaderValue{
+				{Key: ":method", Value: "PUT"},
+				{Key: ":path", Value: "/users/1"},
+				{Key: ":authority", Value: "api.example.com"},
+				{Key: "host", Value: "users.internal"},
+			},
+			Body:                &tapdatav3.Body{BodyType: &tapdatav3.Body_AsString{AsString: `{"name":"a"}`}},
+			HeadersReceivedTime: timestamppb.New(start),
+		},
+		Response: &tapdatav3.HttpBufferedTrace_Message{
+			Headers:             []*corev3.HeaderValue{{Key: ":status", Value: "204"}},
+			HeadersReceivedTime: timestamppb.New(start.Add(10 * time.Millisecond)),
+		},
+	}
+
+	req, err := FromBufferedTrace(trace, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "PUT", req.Method)
+	assert.Equal(t, "/users/1", req.Path)
+	assert.Equal(t, "users.internal", req.Headers["host"])
+	assert.Equal(t, `{"name":"a"}`, req.Body)
+	assert.Equal(t, start.UnixNano(), req.TimestampNs)
+	require.NotNil(t, req.Response)
+	assert.Equal(t, 204, req.Response.Status)
+	assert.Equal(t, (10 * time.Millisecond).Nanoseconds(), req.Response.LatencyNs)
+
+	t.Run("without timestamps", func(t *testing.T) {
+		receivedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		trace := &tapdatav3.HttpBufferedTrace{
+			Request: &tapdatav3.HttpBufferedTrace_Message{Headers: []*corev3.HeaderValue{
+				{Key: ":method", Value: "GET"},
+				{Key: ":path", Value: "/"},
+			}},
+			Response: &tapdatav3.HttpBufferedTrace_Message{Headers: []*corev3.HeaderValue{{Key: ":status", Value: "200"}}},
+		}
+		req, err := FromBufferedTrace(trace, receivedAt)
+		require.NoError(t, err)
+		assert.Equal(t, receivedAt.UnixNano(), req.TimestampNs)
+		assert.Zero(t, req.Response.LatencyNs)
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		trace := &tapdatav3.HttpBufferedTrace{
+			Request: &tapdatav3.HttpBufferedTrace_Message{Headers: []*corev3.HeaderValue{
+				{Key: ":method", Value: "GET"},
+				{Key: ":path", Value: "/"},
+			}},
+			Response: &tapdatav3.HttpBufferedTrace_Message{},
+		}
+		_, err := FromBufferedTrace(trace, time.Now())
+		assert.Error(t, err)
+	})
+}
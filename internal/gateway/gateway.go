@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,8 +14,15 @@ import (
 
 	"github.com/frkr-io/frkr-common/gateway"
 	"github.com/frkr-io/frkr-common/plugins"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/blobstore"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/broker"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/config"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/deadletter"
 	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/server"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/signing"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/store"
 	"github.com/segmentio/kafka-go"
+	"google.golang.org/grpc"
 )
 
 const (
@@ -26,20 +34,38 @@ const (
 type IngestGateway struct {
 	authPlugin   plugins.AuthPlugin
 	secretPlugin plugins.SecretPlugin
+	ingestConfig *config.IngestConfig
+}
+
+// Option configures optional IngestGateway settings
+type Option func(*IngestGateway)
+
+// WithIngestConfig sets the ingest specific configuration (defaults otherwise)
+func WithIngestConfig(cfg *config.IngestConfig) Option {
+	return func(g *IngestGateway) {
+		if cfg != nil {
+			g.ingestConfig = cfg
+		}
+	}
 }
 
 // NewIngestGateway creates a new ingest gateway with injected plugins
-func NewIngestGateway(authPlugin plugins.AuthPlugin, secretPlugin plugins.SecretPlugin) (*IngestGateway, error) {
+func NewIngestGateway(authPlugin plugins.AuthPlugin, secretPlugin plugins.SecretPlugin, opts ...Option) (*IngestGateway, error) {
 	if authPlugin == nil {
 		return nil, fmt.Errorf("authPlugin cannot be nil")
 	}
 	if secretPlugin == nil {
 		return nil, fmt.Errorf("secretPlugin cannot be nil")
 	}
-	return &IngestGateway{
+	g := &IngestGateway{
 		authPlugin:   authPlugin,
 		secretPlugin: secretPlugin,
-	}, nil
+		ingestConfig: config.Default(),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g, nil
 }
 
 // Start starts the gateway server
@@ -56,7 +82,7 @@ func (g *IngestGateway) Start(cfg *gateway.GatewayBaseConfig, db *sql.DB, writer
 		brokerURL = fmt.Sprintf("%s:%s", cfg.BrokerHost, port)
 	}
 
-	// Build DB URL for health checks
+	// Build DB URL for logging
 	var dbURL string
 	if cfg.DBURL != "" {
 		dbURL = cfg.DBURL
@@ -76,12 +102,49 @@ func (g *IngestGateway) Start(cfg *gateway.GatewayBaseConfig, db *sql.DB, writer
 		}
 	}
 
+	// Migrate the ingest gateway tables (routing rules etc.)
+	if err := store.Migrate(context.Background(), db); err != nil {
+		return err
+	}
+
 	// Create health checker and start background health checks
 	healthChecker := gateway.NewGatewayHealthChecker(ServiceName, Version)
 	healthChecker.StartHealthCheckLoop(db, brokerURL)
 
 	// Create and configure server with injected plugins
 	srv := server.NewIngestGatewayServer(db, writer, brokerURL, healthChecker, g.authPlugin, g.secretPlugin)
+	srv.Configure(g.ingestConfig)
+
+	// Blob store for claim-checked request bodies
+	if g.ingestConfig.BlobStoreURL != "" {
+		blobs, err := blobstore.Open(g.ingestConfig.BlobStoreURL)
+		if err != nil {
+			return err
+		}
+		srv.BlobStore = blobs
+	}
+
+	// Signing key for published records
+	if keyID := g.ingestConfig.SigningKeyID; keyID != "" {
+		signer, keys, err := signing.Load(context.Background(), g.secretPlugin, keyID, g.ingestConfig.SigningRetiredKeyIDs)
+		if err != nil {
+			return err
+		}
+		srv.Signer = signer
+		srv.SigningKeys = keys
+	}
+
+	// Dead-letter topic for messages that could not be published
+	if topic := g.ingestConfig.DeadLetterTopic; topic != "" {
+		if g.ingestConfig.AutoCreateTopics {
+			spec := g.ingestConfig.TopicDefaults
+			spec.Name = topic
+			if err := broker.CreateTopic(context.Background(), brokerURL, spec); err != nil {
+				log.Printf("Warning: failed to create dead-letter topic %s: %v", topic, err)
+			}
+		}
+		srv.DeadLetters = deadletter.NewPublisher(writer, topic)
+	}
 
 	// Set up HTTP handlers
 	mux := http.NewServeMux()
@@ -93,10 +156,44 @@ func (g *IngestGateway) Start(cfg *gateway.GatewayBaseConfig, db *sql.DB, writer
 		Handler: mux,
 	}
 
+	// Mirror sink bound to a single stream on its own port
+	var mirrorServer *http.Server
+	if g.ingestConfig.MirrorPort > 0 {
+		mirrorServer = &http.Server{
+			Addr:    fmt.Sprintf(":%d", g.ingestConfig.MirrorPort),
+			Handler: srv.MirrorHandler(g.ingestConfig.MirrorStream),
+		}
+		go func() {
+			log.Printf("Mirror sink for stream %s on port %d", g.ingestConfig.MirrorStream, g.ingestConfig.MirrorPort)
+			if err := mirrorServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Mirror server failed: %v", err)
+			}
+		}()
+	}
+
+	// Envoy ext_proc and tap sink receiver
+	var envoyServer *grpc.Server
+	if g.ingestConfig.EnvoyGRPCPort > 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", g.ingestConfig.EnvoyGRPCPort))
+		if err != nil {
+			return fmt.Errorf("failed to listen for Envoy gRPC: %w", err)
+		}
+		envoyServer = srv.EnvoyGRPCServer(g.ingestConfig.EnvoyStream)
+		go func() {
+			log.Printf("Envoy gRPC receiver on port %d", g.ingestConfig.EnvoyGRPCPort)
+			if err := envoyServer.Serve(lis); err != nil {
+				log.Fatalf("Envoy gRPC server failed: %v", err)
+			}
+		}()
+	}
+
 	go func() {
 		log.Printf("Starting %s v%s on port %d", ServiceName, Version, cfg.HTTPPort)
 		log.Printf("  Database: %s", gateway.SanitizeURL(dbURL))
 		log.Printf("  Broker:   %s", brokerURL)
+		if g.ingestConfig.DeadLetterTopic != "" {
+			log.Printf("  DLQ:      %s", g.ingestConfig.DeadLetterTopic)
+		}
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("HTTP server failed: %v", err)
 		}
@@ -112,10 +209,36 @@ func (g *IngestGateway) Start(cfg *gateway.GatewayBaseConfig, db *sql.DB, writer
 	// Graceful shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	
+
+	if envoyServer != nil {
+		// Tap sink streams never end on their own
+		stopped := make(chan struct{})
+		go func() {
+			envoyServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			envoyServer.Stop()
+		}
+	}
+	if mirrorServer != nil {
+		if err := mirrorServer.Shutdown(ctx); err != nil {
+			return fmt.Errorf("failed to shutdown mirror server: %w", err)
+		}
+	}
 	if err := httpServer.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown server: %w", err)
 	}
+	// Let mirrored requests already accepted finish publishing
+	if err := srv.WaitMirrored(ctx); err != nil {
+		log.Printf("Mirrored requests still publishing at shutdown: %v", err)
+	}
+	// Flush records still queued in async durability mode
+	if err := srv.Close(); err != nil {
+		return fmt.Errorf("failed to flush async records: %w", err)
+	}
 
 	return nil
 }
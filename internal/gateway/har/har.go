@@ -0,0 +1,189 @@
+// Package har parses HTTP Archive (HAR 1.2) files, such as those recorded by
+// browser dev tools, into mirrored requests.
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+)
+
+// File is a HAR document
+type File struct {
+	Log Log `json:"log"`
+}
+
+// Log is the root HAR object
+type Log struct {
+	Version string  `json:"version"`
+	Entries []Entry `json:"entries"`
+}
+
+// Entry is one recorded request/response exchange
+type Entry struct {
+	StartedDateTime time.Time `json:"startedDateTime"`
+	Time            float64   `json:"time"` // Total elapsed time in milliseconds
+	Request         Request   `json:"request"`
+	Response        Response  `json:"response"`
+}
+
+// Request is a recorded request
+type Request struct {
+	Method      string      `json:"method"`
+	URL         string      `json:"url"`
+	Headers     []NameValue `json:"headers"`
+	QueryString []NameValue `json:"queryString"`
+	PostData    *PostData   `json:"postData,omitempty"`
+}
+
+// PostData is a recorded request body
+type PostData struct {
+	MimeType string      `json:"mimeType"`
+	Text     string      `json:"text"`
+	Params   []NameValue `json:"params"`
+	Encoding string      `json:"encoding,omitempty"` // Non-standard, "base64" for binary bodies
+}
+
+// Response is a recorded response
+type Response struct {
+	Status  int         `json:"status"` // 0 when the request was aborted
+	Headers []NameValue `json:"headers"`
+	Content Content     `json:"content"`
+}
+
+// Content is a recorded response body
+type Content struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"` // "base64" for binary bodies
+}
+
+// NameValue is a header, query parameter or form parameter
+type NameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Parse reads a HAR document. Only HAR 1.x is supported.
+func Parse(r io.Reader) (*Log, error) {
+	var f File
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("invalid HAR: %w", err)
+	}
+	if f.Log.Version != "" && !strings.HasPrefix(f.Log.Version, "1.") {
+		return nil, fmt.Errorf("unsupported HAR version %q", f.Log.Version)
+	}
+	return &f.Log, nil
+}
+
+// MirroredRequest converts the entry to a mirrored request with a generated
+// request ID, paired with the recorded response when there is one
+func (e *Entry) MirroredRequest() (*capture.Request, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request URL: %w", err)
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	headers := headerMap(e.Request.Headers)
+	if _, ok := headers["host"]; !ok && u.Host != "" {
+		headers["host"] = u.Host
+	}
+
+	req := &capture.Request{
+		MirroredRequest: &ingestv1.MirroredRequest{
+			Method:      e.Request.Method,
+			Path:        path,
+			Headers:     headers,
+			Query:       queryMap(e.Request.QueryString, u),
+			TimestampNs: e.StartedDateTime.UnixNano(),
+		},
+		Imported: true,
+	}
+	req.FillMissing(e.StartedDateTime)
+	if pd := e.Request.PostData; pd != nil {
+		req.ContentType = pd.MimeType
+		req.Body = pd.Text
+		if pd.Text == "" && len(pd.Params) > 0 {
+			form := url.Values{}
+			for _, p := range pd.Params {
+				form.Add(p.Name, p.Value)
+			}
+			req.Body = form.Encode()
+		}
+		if pd.Encoding == capture.BodyEncodingBase64 {
+			req.BodyEncoding = capture.BodyEncodingBase64
+		}
+	}
+
+	if e.Response.Status > 0 {
+		req.Response = &capture.Response{
+			RequestID:   req.RequestId,
+			Status:      e.Response.Status,
+			Headers:     headerMap(e.Response.Headers),
+			Body:        e.Response.Content.Text,
+			ContentType: e.Response.Content.MimeType,
+			LatencyNs:   int64(e.Time * float64(time.Millisecond)),
+		}
+		if e.Response.Content.Encoding == capture.BodyEncodingBase64 {
+			req.Response.BodyEncoding = capture.BodyEncodingBase64
+		}
+	}
+
+	return req, nil
+}
+
+// headerMap returns headers with lowercased names, joining repeated headers
+// with ", ". HTTP/2 pseudo-headers are dropped, except :authority which
+// becomes host.
+func headerMap(headers []NameValue) map[string]string {
+	m := make(map[string]string, len(headers))
+	for _, h := range headers {
+		name := strings.ToLower(h.Name)
+		if strings.HasPrefix(name, ":") {
+			if name != ":authority" {
+				continue
+			}
+			name = "host"
+		}
+		if v, ok := m[name]; ok {
+			m[name] = v + ", " + h.Value
+		} else {
+			m[name] = h.Value
+		}
+	}
+	return m
+}
+
+// queryMap returns the query parameters, joining repeated parameters with ",".
+// The URL's query is used when the entry has no queryString.
+func queryMap(params []NameValue, u *url.URL) map[string]string {
+	if len(params) == 0 {
+		for name, values := range u.Query() {
+			for _, v := range values {
+				params = append(params, NameValue{Name: name, Value: v})
+			}
+		}
+	}
+	if len(params) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(params))
+	for _, p := range params {
+		if v, ok := m[p.Name]; ok {
+			m[p.Name] = v + "," + p.Value
+		} else {
+			m[p.Name] = p.Value
+		}
+	}
+	return m
+}
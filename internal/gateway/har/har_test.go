@@ -0,0 +1,105 @@
+package har
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sample = `{
+  "log": {
+    "version": "1.2",
+    "creator": {"name": "WebInspector", "version": "537.36"},
+    "entries": [
+      {
+        "startedDateTime": "2026-01-02T03:04:05.678Z",
+        "time": 12.5,
+        "request": {
+          "method": "POST",
+          "url": "https://shop.example.com/api/cart?item=1&item=2",
+          "httpVersion": "HTTP/2",
+          "headers": [
+            {"name": ":authority", "value": "shop.example.com"},
+            {"name": ":method", "value": "POST"},
+            {"name": "Content-Type", "value": "application/json"},
+            {"name": "Cookie", "value": "a=1"},
+            {"name": "Cookie", "value": "b=2"}
+          ],
+          "queryString": [{"name": "item", "value": "1"}, {"name": "item", "value": "2"}],
+          "postData": {"mimeType": "application/json", "text": "{\"qty\":1}"}
+        },
+        "response": {
+          "status": 201,
+          "headers": [{"name": "Content-Type", "value": "application/json"}],
+          "content": {"size": 2, "mimeType": "application/json", "text": "e30=", "encoding": "base64"}
+        }
+      },
+      {
+        "startedDateTime": "2026-01-02T03:04:06Z",
+        "time": 0,
+        "request": {
+          "method": "POST",
+          "url": "https://shop.example.com/login",
+          "headers": [],
+          "queryString": [],
+          "postData": {"mimeType": "application/x-www-form-urlencoded", "params": [{"name": "user", "value": "qa"}]}
+        },
+        "response": {"status": 0, "headers": [], "content": {"size": 0, "mimeType": ""}}
+      }
+    ]
+  }
+}`
+
+func TestParse(t *testing.T) {
+	log, err := Parse(strings.NewReader(sample))
+	require.NoError(t, err)
+	require.Len(t, log.Entries, 2)
+
+	req, err := log.Entries[0].MirroredRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "/api/cart", req.Path)
+	assert.Equal(t, time.Date(2026, 1, 2, 3, 4, 5, 678000000, time.UTC).UnixNano(), req.TimestampNs)
+	assert.True(t, req.Imported)
+	assert.Equal(t, map[string]string{"item": "1,2"}, req.Query)
+	assert.Equal(t, map[string]string{
+		"host":         "shop.example.com",
+		"content-type": "application/json",
+		"cookie":       "a=1, b=2",
+	}, req.Headers)
+	assert.Equal(t, `{"qty":1}`, req.Body)
+	assert.Equal(t, "application/json", req.ContentType)
+	assert.NotEmpty(t, req.RequestId)
+	assert.Equal(t, []string{capture.GeneratedRequestID}, req.ServerGenerated)
+
+	require.NotNil(t, req.Response)
+	assert.Equal(t, req.RequestId, req.Response.RequestID)
+	assert.Equal(t, 201, req.Response.Status)
+	assert.Equal(t, capture.BodyEncodingBase64, req.Response.BodyEncoding)
+	assert.Equal(t, int64(12500000), req.Response.LatencyNs)
+}
+
+func TestEntry_FormParamsAndAbortedResponse(t *testing.T) {
+	log, err := Parse(strings.NewReader(sample))
+	require.NoError(t, err)
+
+	req, err := log.Entries[1].MirroredRequest()
+	require.NoError(t, err)
+	assert.Equal(t, "/login", req.Path)
+	assert.Equal(t, "user=qa", req.Body)
+	assert.Equal(t, "shop.example.com", req.Headers["host"])
+	assert.Nil(t, req.Query)
+	assert.Nil(t, req.Response)
+}
+
+func TestParse_Errors(t *testing.T) {
+	_, err := Parse(strings.NewReader(`not json`))
+	assert.Error(t, err)
+
+	_, err = Parse(strings.NewReader(`{"log": {"version": "2.0", "entries": []}}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+// Package limits enforces size limits on ingested requests so a single client
+// can't push arbitrarily large payloads into gateway memory or the broker.
+package limits
+
+import (
+	"fmt"
+
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+)
+
+// Names of the individual limits, used in errors and metrics
+const (
+	LimitRequestBytes = "request_bytes"
+	LimitHeaderCount  = "header_count"
+	LimitHeaderBytes  = "header_bytes"
+	LimitBodyBytes    = "body_bytes"
+
+	// LimitClaimCheckBytes is the largest body offloaded to the blob store
+	LimitClaimCheckBytes = "claim_check_bytes"
+)
+
+// Limits bounds the size of an ingest request. Zero values mean no limit.
+type Limits struct {
+	MaxRequestBytes int64 // Size of the raw HTTP request body
+	MaxHeaderCount  int   // Number of mirrored request headers
+	MaxHeaderBytes  int64 // Total size of mirrored header names and values
+	MaxBodyBytes    int64 // Size of the mirrored request body field
+}
+
+// Override returns l with the non-zero limits of o applied on top
+func (l Limits) Override(o Limits) Limits {
+	if o.MaxRequestBytes > 0 {
+		l.MaxRequestBytes = o.MaxRequestBytes
+	}
+	if o.MaxHeaderCount > 0 {
+		l.MaxHeaderCount = o.MaxHeaderCount
+	}
+	if o.MaxHeaderBytes > 0 {
+		l.MaxHeaderBytes = o.MaxHeaderBytes
+	}
+	if o.MaxBodyBytes > 0 {
+		l.MaxBodyBytes = o.MaxBodyBytes
+	}
+	return l
+}
+
+// Violation describes an exceeded limit
+type Violation struct {
+	Limit  string // One of the Limit* names
+	Max    int64
+	Actual int64
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("%s limit exceeded: %d > %d", v.Limit, v.Actual, v.Max)
+}
+
+// Check returns the first limit exceeded by a request whose raw body was
+// requestBytes long, or nil if it is within all limits
+func (l Limits) Check(requestBytes int64, req *ingestv1.MirroredRequest) *Violation {
+	if l.MaxRequestBytes > 0 && requestBytes > l.MaxRequestBytes {
+		return &Violation{Limit: LimitRequestBytes, Max: l.MaxRequestBytes, Actual: requestBytes}
+	}
+	if req == nil {
+		return nil
+	}
+	return l.CheckMessage(req.Headers, req.Body)
+}
+
+// CheckMessage returns the first header or body limit exceeded by a mirrored
+// request or response, or nil if it is within all limits
+func (l Limits) CheckMessage(headers map[string]string, body string) *Violation {
+	if count := len(headers); l.MaxHeaderCount > 0 && count > l.MaxHeaderCount {
+		return &Violation{Limit: LimitHeaderCount, Max: int64(l.MaxHeaderCount), Actual: int64(count)}
+	}
+	if l.MaxHeaderBytes > 0 {
+		var size int64
+		for name, value := range headers {
+			size += int64(len(name) + len(value))
+		}
+		if size > l.MaxHeaderBytes {
+			return &Violation{Limit: LimitHeaderBytes, Max: l.MaxHeaderBytes, Actual: size}
+		}
+	}
+	if size := int64(len(body)); l.MaxBodyBytes > 0 && size > l.MaxBodyBytes {
+		return &Violation{Limit: LimitBodyBytes, Max: l.MaxBodyBytes, Actual: size}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package limits
+
+import (
+	"testing"
+
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits_Check(t *testing.T) {
+	req := &ingestv1.MirroredRequest{
+		Headers: map[string]string{"host": "example.com", "accept": "*/*"},
+		Body:    "0123456789",
+	}
+
+	tests := []struct {
+		name   string
+		limits Limits
+		size   int64
+		want   *Violation
+	}{
+		{"no limits", Limits{}, 1 << 30, nil},
+		{"within limits", Limits{MaxRequestBytes: 100, MaxHeaderCount: 2, MaxHeaderBytes: 24, MaxBodyBytes: 10}, 100, nil},
+		{"request too large", Limits{MaxRequestBytes: 100}, 101, &Violation{Limit: LimitRequestBytes, Max: 100, Actual: 101}},
+		{"too many headers", Limits{MaxHeaderCount: 1}, 0, &Violation{Limit: LimitHeaderCount, Max: 1, Actual: 2}},
+		{"headers too large", Limits{MaxHeaderBytes: 20}, 0, &Violation{Limit: LimitHeaderBytes, Max: 20, Actual: 24}},
+		{"body too large", Limits{MaxBodyBytes: 9}, 0, &Violation{Limit: LimitBodyBytes, Max: 9, Actual: 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.limits.Check(tt.size, req))
+		})
+	}
+}
+
+func TestLimits_Override(t *testing.T) {
+	global := Limits{MaxRequestBytes: 1000, MaxHeaderCount: 50, MaxBodyBytes: 500}
+	got := global.Override(Limits{MaxHeaderCount: 10, MaxHeaderBytes: 2048})
+	assert.Equal(t, Limits{MaxRequestBytes: 1000, MaxHeaderCount: 10, MaxHeaderBytes: 2048, MaxBodyBytes: 500}, got)
+}
@@ -0,0 +1,208 @@
+// Package metadata describes the ingest metadata attached to published
+// records as Kafka record headers.
+package metadata
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Record header keys
+const (
+	HeaderTenantID         = "frkr-tenant-id"
+	HeaderStreamID         = "frkr-stream-id"
+	HeaderAuthSource       = "frkr-auth-source"
+	HeaderAuthUser         = "frkr-auth-user"
+	HeaderReceivedAt       = "frkr-received-at"
+	HeaderGatewayInstance  = "frkr-gateway-instance"
+	HeaderContentType      = "frkr-content-type"
+	HeaderContentEncoding  = "frkr-content-encoding"
+	HeaderSchemaVersion    = "frkr-schema-version"
+	HeaderBodyEncoding     = "frkr-body-encoding"
+	HeaderBodyContentType  = "frkr-body-content-type"
+	HeaderClaimCheckURI    = "frkr-claim-check-uri"
+	HeaderClaimCheckHash   = "frkr-claim-check-sha256"
+	HeaderClaimCheckSize   = "frkr-claim-check-size"
+	HeaderCloudEventID     = "frkr-ce-id"
+	HeaderCloudEventSource = "frkr-ce-source"
+	HeaderCloudEventType   = "frkr-ce-type"
+	HeaderCloudEventTime   = "frkr-ce-time"
+	HeaderTrackingID       = "frkr-tracking-id"
+	HeaderSequence         = "frkr-sequence"
+	HeaderOrder            = "frkr-order"
+	HeaderEncryptionKeyID  = "frkr-encryption-key-id"
+	HeaderServerGenerated  = "frkr-server-generated"
+	HeaderClockSkew        = "frkr-clock-skew-ms"
+	HeaderOriginalTime     = "frkr-original-timestamp-ns"
+)
+
+// AllHeaders lists every header the gateway can attach, in the order they are written
+var AllHeaders = []string{
+	HeaderTenantID,
+	HeaderStreamID,
+	HeaderAuthSource,
+	HeaderAuthUser,
+	HeaderReceivedAt,
+	HeaderGatewayInstance,
+	HeaderContentType,
+	HeaderContentEncoding,
+	HeaderSchemaVersion,
+	HeaderBodyEncoding,
+	HeaderBodyContentType,
+	HeaderClaimCheckURI,
+	HeaderClaimCheckHash,
+	HeaderClaimCheckSize,
+	HeaderCloudEventID,
+	HeaderCloudEventSource,
+	HeaderCloudEventType,
+	HeaderCloudEventTime,
+	HeaderTrackingID,
+	HeaderSequence,
+	HeaderOrder,
+	HeaderEncryptionKeyID,
+	HeaderServerGenerated,
+	HeaderClockSkew,
+	HeaderOriginalTime,
+}
+
+// Record value formats
+const (
+	ContentTypeJSON         = "application/json"
+	ContentEncodingIdentity = "identity"
+
+	// SchemaVersionMirroredRequest is the schema of a bare JSON MirroredRequest value
+	SchemaVersionMirroredRequest = "ingest.v1.MirroredRequest"
+
+	// SchemaVersionMirroredResponse is the schema of a response captured
+	// after its request (POST /ingest/response)
+	SchemaVersionMirroredResponse = "ingest.v1.MirroredResponse"
+)
+
+// Metadata is the ingest metadata for one published record
+type Metadata struct {
+	TenantID        string
+	StreamID        string // Name of the stream the record is published to
+	AuthSource      string
+	AuthUser        string // User ID, or client ID for client credentials
+	ReceivedAt      time.Time
+	GatewayInstance string
+	ContentType     string
+	ContentEncoding string
+	SchemaVersion   string
+
+	// Encoding (utf8 or base64) and content type hint of the mirrored body
+	BodyEncoding    string
+	BodyContentType string
+
+	// Claim check for an offloaded request body; empty if the body is inline
+	ClaimCheckURI  string
+	ClaimCheckHash string
+	ClaimCheckSize int64
+
+	// Attributes of the CloudEvent the request carried, if any
+	CloudEventID     string
+	CloudEventSource string
+	CloudEventType   string
+	CloudEventTime   string
+
+	// Tracking ID returned to the caller of an async request
+	TrackingID string
+
+	// Client sequence number and order status (in_order, gap or
+	// out_of_order) of a record published in ordered mode
+	Sequence int64
+	Order    string
+
+	// ID of the data key an encrypted record value was encrypted with
+	EncryptionKeyID string
+
+	// Request fields the gateway filled in because the client omitted them
+	// (request_id, timestamp_ns)
+	ServerGenerated []string
+
+	// Skew of the client's timestamp from the receive time, positive when
+	// ahead, when it exceeded the clock skew threshold, and the client's
+	// timestamp when the gateway corrected it
+	ClockSkew           time.Duration
+	OriginalTimestampNs int64
+}
+
+// values returns the metadata keyed by header, skipping empty values
+func (m *Metadata) values() map[string]string {
+	values := map[string]string{
+		HeaderTenantID:         m.TenantID,
+		HeaderStreamID:         m.StreamID,
+		HeaderAuthSource:       m.AuthSource,
+		HeaderAuthUser:         m.AuthUser,
+		HeaderGatewayInstance:  m.GatewayInstance,
+		HeaderContentType:      m.ContentType,
+		HeaderContentEncoding:  m.ContentEncoding,
+		HeaderSchemaVersion:    m.SchemaVersion,
+		HeaderBodyEncoding:     m.BodyEncoding,
+		HeaderBodyContentType:  m.BodyContentType,
+		HeaderClaimCheckURI:    m.ClaimCheckURI,
+		HeaderClaimCheckHash:   m.ClaimCheckHash,
+		HeaderCloudEventID:     m.CloudEventID,
+		HeaderCloudEventSource: m.CloudEventSource,
+		HeaderCloudEventType:   m.CloudEventType,
+		HeaderCloudEventTime:   m.CloudEventTime,
+		HeaderTrackingID:       m.TrackingID,
+		HeaderOrder:            m.Order,
+		HeaderEncryptionKeyID:  m.EncryptionKeyID,
+		HeaderServerGenerated:  strings.Join(m.ServerGenerated, ","),
+	}
+	if !m.ReceivedAt.IsZero() {
+		values[HeaderReceivedAt] = m.ReceivedAt.UTC().Format(time.RFC3339Nano)
+	}
+	if m.ClaimCheckURI != "" {
+		values[HeaderClaimCheckSize] = strconv.FormatInt(m.ClaimCheckSize, 10)
+	}
+	if m.Sequence > 0 {
+		values[HeaderSequence] = strconv.FormatInt(m.Sequence, 10)
+	}
+	if m.ClockSkew != 0 {
+		values[HeaderClockSkew] = strconv.FormatInt(m.ClockSkew.Milliseconds(), 10)
+	}
+	if m.OriginalTimestampNs != 0 {
+		values[HeaderOriginalTime] = strconv.FormatInt(m.OriginalTimestampNs, 10)
+	}
+	return values
+}
+
+// HeaderSet builds record headers from metadata, leaving out headers operators
+// have turned off
+type HeaderSet struct {
+	disabled map[string]bool
+}
+
+// NewHeaderSet creates a header set with the given header keys disabled
+// (matched case-insensitively)
+func NewHeaderSet(disabled []string) *HeaderSet {
+	h := &HeaderSet{disabled: make(map[string]bool, len(disabled))}
+	for _, key := range disabled {
+		h.disabled[strings.ToLower(strings.TrimSpace(key))] = true
+	}
+	return h
+}
+
+// Enabled reports whether a header is written
+func (h *HeaderSet) Enabled(key string) bool {
+	return !h.disabled[key]
+}
+
+// Headers returns the enabled, non-empty headers for the metadata
+func (h *HeaderSet) Headers(m *Metadata) []kafka.Header {
+	values := m.values()
+	headers := make([]kafka.Header, 0, len(values))
+	for _, key := range AllHeaders {
+		v := values[key]
+		if v == "" || !h.Enabled(key) {
+			continue
+		}
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(v)})
+	}
+	return headers
+}
@@ -0,0 +1,70 @@
+package metadata
+
+import (
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderSet_Headers(t *testing.T) {
+	meta := &Metadata{
+		TenantID:            "tenant-1",
+		StreamID:            "orders",
+		AuthSource:          "basic",
+		AuthUser:            "ingestuser",
+		ReceivedAt:          time.Date(2026, 3, 4, 5, 6, 7, 8, time.UTC),
+		GatewayInstance:     "gw-0",
+		ContentType:         ContentTypeJSON,
+		ContentEncoding:     ContentEncodingIdentity,
+		SchemaVersion:       SchemaVersionMirroredRequest,
+		BodyEncoding:        "base64",
+		BodyContentType:     "image/png",
+		CloudEventID:        "evt-1",
+		CloudEventSource:    "/orders",
+		CloudEventType:      "com.example.order.created",
+		CloudEventTime:      "2026-03-04T05:06:07Z",
+		TrackingID:          "trk-1",
+		Sequence:            7,
+		Order:               "gap",
+		EncryptionKeyID:     "orders-2026-10",
+		ServerGenerated:     []string{"request_id", "timestamp_ns"},
+		ClockSkew:           -90 * time.Second,
+		OriginalTimestampNs: 1767323045000000000,
+	}
+
+	t.Run("all headers enabled by default", func(t *testing.T) {
+		headers := NewHeaderSet(nil).Headers(meta)
+		assert.Equal(t, []kafka.Header{
+			{Key: HeaderTenantID, Value: []byte("tenant-1")},
+			{Key: HeaderStreamID, Value: []byte("orders")},
+			{Key: HeaderAuthSource, Value: []byte("basic")},
+			{Key: HeaderAuthUser, Value: []byte("ingestuser")},
+			{Key: HeaderReceivedAt, Value: []byte("2026-03-04T05:06:07.000000008Z")},
+			{Key: HeaderGatewayInstance, Value: []byte("gw-0")},
+			{Key: HeaderContentType, Value: []byte(ContentTypeJSON)},
+			{Key: HeaderContentEncoding, Value: []byte(ContentEncodingIdentity)},
+			{Key: HeaderSchemaVersion, Value: []byte(SchemaVersionMirroredRequest)},
+			{Key: HeaderBodyEncoding, Value: []byte("base64")},
+			{Key: HeaderBodyContentType, Value: []byte("image/png")},
+			{Key: HeaderCloudEventID, Value: []byte("evt-1")},
+			{Key: HeaderCloudEventSource, Value: []byte("/orders")},
+			{Key: HeaderCloudEventType, Value: []byte("com.example.order.created")},
+			{Key: HeaderCloudEventTime, Value: []byte("2026-03-04T05:06:07Z")},
+			{Key: HeaderTrackingID, Value: []byte("trk-1")},
+			{Key: HeaderSequence, Value: []byte("7")},
+			{Key: HeaderOrder, Value: []byte("gap")},
+			{Key: HeaderEncryptionKeyID, Value: []byte("orders-2026-10")},
+			{Key: HeaderServerGenerated, Value: []byte("request_id,timestamp_ns")},
+			{Key: HeaderClockSkew, Value: []byte("-90000")},
+			{Key: HeaderOriginalTime, Value: []byte("1767323045000000000")},
+		}, headers)
+	})
+
+	t.Run("disabled and empty headers are omitted", func(t *testing.T) {
+		set := NewHeaderSet([]string{"FRKR-AUTH-USER", " frkr-gateway-instance "})
+		headers := set.Headers(&Metadata{StreamID: "orders", AuthUser: "ingestuser", GatewayInstance: "gw-0"})
+		assert.Equal(t, []kafka.Header{{Key: HeaderStreamID, Value: []byte("orders")}}, headers)
+	})
+}
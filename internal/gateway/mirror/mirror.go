@@ -0,0 +1,107 @@
+// Package mirror converts raw HTTP traffic, as mirrored by a proxy such as
+// Envoy (request_mirror_policies) or nginx (mirror), into mirrored requests.
+package mirror
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+)
+
+// PathPrefix is the path prefix of the mirror sink: /mirror/{stream}/...
+const PathPrefix = "/mirror/"
+
+// HeaderAuthorization carries the gateway credentials on mirrored traffic,
+// since the Authorization header belongs to the mirrored request itself. It
+// is never captured.
+const HeaderAuthorization = "X-Frkr-Authorization"
+
+// ErrMissingCredentials is returned for mirrored traffic without gateway credentials
+var ErrMissingCredentials = errors.New("missing " + HeaderAuthorization + " header")
+
+// SplitPath splits a mirror sink path into the stream name and the mirrored
+// path, e.g. /mirror/my-api/users/1 into "my-api" and "/users/1"
+func SplitPath(path string) (stream, rest string, ok bool) {
+	if !strings.HasPrefix(path, PathPrefix) {
+		return "", "", false
+	}
+	stream, rest, _ = strings.Cut(strings.TrimPrefix(path, PathPrefix), "/")
+	if stream == "" {
+		return "", "", false
+	}
+	return stream, "/" + rest, true
+}
+
+// Capture converts a mirrored HTTP request into a mirrored request. Its
+// request ID (a UUIDv7) and timestamp (receivedAt) are filled in by the
+// gateway and listed in ServerGenerated. path is the original request path and
+// body the already read request body. Bodies that aren't valid UTF-8 are
+// base64 encoded.
+func Capture(r *http.Request, path string, body []byte, receivedAt time.Time) *capture.Request {
+	req := &capture.Request{
+		MirroredRequest: &ingestv1.MirroredRequest{
+			Method:  r.Method,
+			Path:    path,
+			Headers: captureHeaders(r),
+			Query:   captureQuery(r),
+		},
+		ContentType: r.Header.Get("Content-Type"),
+	}
+	req.FillMissing(receivedAt)
+	if utf8.Valid(body) {
+		req.Body = string(body)
+	} else {
+		req.Body = base64.StdEncoding.EncodeToString(body)
+		req.BodyEncoding = capture.BodyEncodingBase64
+	}
+	return req
+}
+
+// AuthRequest returns a copy of r that authenticates with the gateway
+// credentials from HeaderAuthorization
+func AuthRequest(r *http.Request) (*http.Request, error) {
+	credentials := r.Header.Get(HeaderAuthorization)
+	if credentials == "" {
+		return nil, ErrMissingCredentials
+	}
+	auth := r.Clone(r.Context())
+	auth.Header.Set("Authorization", credentials)
+	auth.Header.Del(HeaderAuthorization)
+	return auth, nil
+}
+
+// captureHeaders returns the request headers with lowercased names, joining
+// repeated headers with ", ". The Host header is included since routing can
+// match on it.
+func captureHeaders(r *http.Request) map[string]string {
+	headers := make(map[string]string, len(r.Header)+1)
+	for name, values := range r.Header {
+		if http.CanonicalHeaderKey(name) == HeaderAuthorization {
+			continue
+		}
+		headers[strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+	if r.Host != "" {
+		headers["host"] = r.Host
+	}
+	return headers
+}
+
+// captureQuery returns the query parameters, joining repeated parameters with ","
+func captureQuery(r *http.Request) map[string]string {
+	values := r.URL.Query()
+	if len(values) == 0 {
+		return nil
+	}
+	query := make(map[string]string, len(values))
+	for name, v := range values {
+		query[name] = strings.Join(v, ",")
+	}
+	return query
+}
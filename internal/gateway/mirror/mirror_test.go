@@ -0,0 +1,86 @@
+package mirror
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		stream string
+		rest   string
+		ok     bool
+	}{
+		{"/mirror/my-api/users/1", "my-api", "/users/1", true},
+		{"/mirror/my-api", "my-api", "/", true},
+		{"/mirror/my-api/", "my-api", "/", true},
+		{"/mirror/", "", "", false},
+		{"/ingest", "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			stream, rest, ok := SplitPath(tt.path)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.stream, stream)
+			assert.Equal(t, tt.rest, rest)
+		})
+	}
+}
+
+func TestCapture(t *testing.T) {
+	r := httptest.NewRequest("POST", "http://orders.example.com/mirror/my-api/orders?id=1&tag=a&tag=b", strings.NewReader("ignored"))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Authorization", "Bearer app-token")
+	r.Header.Set(HeaderAuthorization, "Basic Z2F0ZXdheQ==")
+	r.Header.Add("Accept", "text/html")
+	r.Header.Add("Accept", "application/json")
+	now := time.Unix(1700000000, 42)
+
+	req := Capture(r, "/orders", []byte(`{"id": 1}`), now)
+
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "/orders", req.Path)
+	assert.Equal(t, `{"id": 1}`, req.Body)
+	assert.Equal(t, capture.BodyEncodingUTF8, req.Encoding())
+	assert.Equal(t, "application/json", req.ContentType)
+	assert.Equal(t, now.UnixNano(), req.TimestampNs)
+	assert.Equal(t, uuid.Version(7), uuid.MustParse(req.RequestId).Version())
+	assert.Equal(t, []string{capture.GeneratedRequestID, capture.GeneratedTimestamp}, req.ServerGenerated)
+	assert.Equal(t, map[string]string{"id": "1", "tag": "a,b"}, req.Query)
+	assert.Equal(t, "Bearer app-token", req.Headers["authorization"])
+	assert.Equal(t, "text/html, application/json", req.Headers["accept"])
+	assert.Equal(t, "orders.example.com", req.Headers["host"])
+	assert.NotContains(t, req.Headers, "x-frkr-authorization")
+}
+
+func TestCapture_BinaryBody(t *testing.T) {
+	r := httptest.NewRequest("PUT", "/mirror/my-api/image", nil)
+	req := Capture(r, "/image", []byte{0xff, 0xd8, 0xff}, time.Now())
+
+	assert.Equal(t, capture.BodyEncodingBase64, req.BodyEncoding)
+	body, err := req.BodyBytes()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xd8, 0xff}, body)
+}
+
+func TestAuthRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/mirror/my-api/", nil)
+	r.Header.Set("Authorization", "Bearer app-token")
+
+	_, err := AuthRequest(r)
+	assert.ErrorIs(t, err, ErrMissingCredentials)
+
+	r.Header.Set(HeaderAuthorization, "Basic Z2F0ZXdheQ==")
+	auth, err := AuthRequest(r)
+	require.NoError(t, err)
+	assert.Equal(t, "Basic Z2F0ZXdheQ==", auth.Header.Get("Authorization"))
+	assert.Equal(t, "Bearer app-token", r.Header.Get("Authorization"))
+}
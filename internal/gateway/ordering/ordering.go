@@ -0,0 +1,190 @@
+// Package ordering serialises publishes per partition key for streams in
+// ordered mode, using the client's sequence numbers to detect gaps and
+// reordering.
+package ordering
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ordering modes
+const (
+	// Off publishes requests independently, as they arrive
+	Off = "off"
+	// Flag publishes requests with the same key one at a time, in arrival
+	// order, flagging records whose sequence number is not the next one
+	Flag = "flag"
+	// Reorder is Flag, but holds back a request that arrives ahead of its
+	// sequence for up to the reorder window, waiting for the missing ones
+	Reorder = "reorder"
+)
+
+// Parse validates an ordering mode, accepting any case
+func Parse(s string) (string, error) {
+	mode := strings.ToLower(strings.TrimSpace(s))
+	switch mode {
+	case Off, Flag, Reorder:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid ordering mode: %s", s)
+	}
+}
+
+// Record order statuses
+const (
+	// InOrder is a record with the next sequence number of its key, or
+	// without a sequence number
+	InOrder = "in_order"
+	// Gap is a record published while earlier sequence numbers are missing
+	Gap = "gap"
+	// OutOfOrder is a record whose sequence number is not above one already
+	// published (reordered or duplicate)
+	OutOfOrder = "out_of_order"
+)
+
+// Sequencer hands out turns to publish, one key at a time
+type Sequencer struct {
+	idleTTL time.Duration
+
+	mu        sync.Mutex
+	keys      map[string]*keyState
+	lastSweep time.Time
+}
+
+// keyState is the sequencing state of one key
+type keyState struct {
+	next     int64         // Next expected sequence number, 0 before the first
+	busy     bool          // A turn is in progress
+	changed  chan struct{} // Closed when next or busy changes
+	lastUsed time.Time
+}
+
+// NewSequencer returns a Sequencer that forgets keys idle for idleTTL
+func NewSequencer(idleTTL time.Duration) *Sequencer {
+	return &Sequencer{idleTTL: idleTTL, keys: make(map[string]*keyState)}
+}
+
+// Turn is the right to publish a record for a key. It must be released.
+type Turn struct {
+	// Status is the record's order status (InOrder, Gap or OutOfOrder)
+	Status string
+
+	s   *Sequencer
+	st  *keyState
+	seq int64
+}
+
+// Acquire waits until no other record of key is being published and
+// returns the record's turn. seq is the client's sequence number, 0 if it
+// sent none. A record ahead of its sequence waits up to window for the
+// records before it; with a zero window it is published as a gap right away.
+func (s *Sequencer) Acquire(ctx context.Context, key string, seq int64, window time.Duration) (*Turn, error) {
+	deadline := time.Now().Add(window)
+
+	s.mu.Lock()
+	st := s.state(key)
+	for {
+		var wait time.Duration // Zero waits for a change only
+		if !st.busy {
+			status, ahead := st.status(seq)
+			if !ahead {
+				return s.take(st, seq, status), nil
+			}
+			wait = time.Until(deadline)
+			if wait <= 0 {
+				return s.take(st, seq, Gap), nil
+			}
+		}
+
+		changed := st.changed
+		s.mu.Unlock()
+		if err := waitChange(ctx, changed, wait); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+	}
+}
+
+// Release ends the turn, making the record's sequence number the last one
+// published for its key
+func (t *Turn) Release() {
+	s := t.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.seq >= t.st.next {
+		t.st.next = t.seq + 1
+	}
+	t.st.busy = false
+	t.st.lastUsed = time.Now()
+	t.st.notify()
+}
+
+// status returns the order status of seq, and whether it is ahead of the
+// next expected sequence number
+func (st *keyState) status(seq int64) (string, bool) {
+	switch {
+	case seq <= 0 || st.next == 0 || seq == st.next:
+		return InOrder, false
+	case seq < st.next:
+		return OutOfOrder, false
+	default:
+		return Gap, true
+	}
+}
+
+// notify wakes up the records waiting for the key
+func (st *keyState) notify() {
+	close(st.changed)
+	st.changed = make(chan struct{})
+}
+
+// take starts a turn; s.mu must be held and is released
+func (s *Sequencer) take(st *keyState, seq int64, status string) *Turn {
+	st.busy = true
+	st.lastUsed = time.Now()
+	s.mu.Unlock()
+	return &Turn{Status: status, s: s, st: st, seq: seq}
+}
+
+// state returns the state of key, creating it if needed, and occasionally
+// forgets idle keys. s.mu must be held.
+func (s *Sequencer) state(key string) *keyState {
+	now := time.Now()
+	if s.idleTTL > 0 && now.Sub(s.lastSweep) >= s.idleTTL {
+		for k, st := range s.keys {
+			if !st.busy && now.Sub(st.lastUsed) >= s.idleTTL {
+				delete(s.keys, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	st, ok := s.keys[key]
+	if !ok {
+		st = &keyState{changed: make(chan struct{})}
+		s.keys[key] = st
+	}
+	st.lastUsed = now
+	return st
+}
+
+// waitChange waits until changed is closed, the context is done or, for a
+// positive timeout, the timeout expires
+func waitChange(ctx context.Context, changed <-chan struct{}, timeout time.Duration) error {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	select {
+	case <-changed:
+	case <-expired:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
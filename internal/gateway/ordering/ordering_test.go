@@ -0,0 +1,117 @@
+package ordering
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for _, s := range []string{"off", "Flag", " REORDER "} {
+		_, err := Parse(s)
+		assert.NoError(t, err, s)
+	}
+	_, err := Parse("strict")
+	assert.Error(t, err)
+}
+
+func acquire(t *testing.T, s *Sequencer, key string, seq int64, window time.Duration) string {
+	t.Helper()
+	turn, err := s.Acquire(context.Background(), key, seq, window)
+	require.NoError(t, err)
+	turn.Release()
+	return turn.Status
+}
+
+func TestSequencer_Flag(t *testing.T) {
+	s := NewSequencer(time.Minute)
+	assert.Equal(t, InOrder, acquire(t, s, "k", 1, 0))
+	assert.Equal(t, InOrder, acquire(t, s, "k", 2, 0))
+	assert.Equal(t, Gap, acquire(t, s, "k", 4, 0))
+	assert.Equal(t, OutOfOrder, acquire(t, s, "k", 3, 0))
+	assert.Equal(t, OutOfOrder, acquire(t, s, "k", 4, 0))
+	assert.Equal(t, InOrder, acquire(t, s, "k", 5, 0))
+	assert.Equal(t, InOrder, acquire(t, s, "k", 0, 0), "no sequence number")
+
+	// Keys are independent; the first record of a key sets its sequence
+	assert.Equal(t, InOrder, acquire(t, s, "other", 7, 0))
+}
+
+func TestSequencer_Serialises(t *testing.T) {
+	s := NewSequencer(time.Minute)
+	first, err := s.Acquire(context.Background(), "k", 0, 0)
+	require.NoError(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		turn, err := s.Acquire(context.Background(), "k", 0, 0)
+		if err == nil {
+			turn.Release()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second turn acquired while the first was held")
+	case <-time.After(20 * time.Millisecond):
+	}
+	first.Release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("second turn not acquired after release")
+	}
+}
+
+func TestSequencer_Reorder(t *testing.T) {
+	s := NewSequencer(time.Minute)
+	assert.Equal(t, InOrder, acquire(t, s, "k", 1, time.Second))
+
+	// 3 arrives before 2 and waits for it
+	var mu sync.Mutex
+	var order []int64
+	var wg sync.WaitGroup
+	publish := func(seq int64) {
+		defer wg.Done()
+		turn, err := s.Acquire(context.Background(), "k", seq, time.Second)
+		if !assert.NoError(t, err) {
+			return
+		}
+		mu.Lock()
+		order = append(order, seq)
+		mu.Unlock()
+		assert.Equal(t, InOrder, turn.Status)
+		turn.Release()
+	}
+	wg.Add(2)
+	go publish(3)
+	time.Sleep(20 * time.Millisecond)
+	go publish(2)
+	wg.Wait()
+	assert.Equal(t, []int64{2, 3}, order)
+}
+
+func TestSequencer_ReorderWindowExpires(t *testing.T) {
+	s := NewSequencer(time.Minute)
+	assert.Equal(t, InOrder, acquire(t, s, "k", 1, 0))
+
+	start := time.Now()
+	assert.Equal(t, Gap, acquire(t, s, "k", 3, 30*time.Millisecond))
+	assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	assert.Equal(t, OutOfOrder, acquire(t, s, "k", 2, 30*time.Millisecond))
+}
+
+func TestSequencer_Canceled(t *testing.T) {
+	s := NewSequencer(time.Minute)
+	assert.Equal(t, InOrder, acquire(t, s, "k", 1, 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.Acquire(ctx, "k", 5, time.Second)
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,213 @@
+// Package partition chooses the key records are published with and the
+// balancer that maps keys to partitions, per stream.
+package partition
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/routing"
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+	"github.com/segmentio/kafka-go"
+)
+
+// Partition key strategies
+const (
+	// KeyRequestID keys records by the mirrored request's ID
+	KeyRequestID = "request_id"
+	// KeyHeader keys records by a request header, e.g. header:X-Session-Id
+	KeyHeader = "header"
+	// KeyClientIP keys records by the original client's IP, taken from
+	// X-Forwarded-For or X-Real-IP
+	KeyClientIP = "client_ip"
+	// KeyPath keys records by the values a path template captures, e.g.
+	// path:/users/{user}/orders
+	KeyPath = "path"
+	// KeyNull publishes records without a key, spreading them over partitions
+	KeyNull = "null"
+)
+
+// Balancers
+const (
+	// BalancerHash hashes keys with FNV-1a, like kafka-go
+	BalancerHash = "hash"
+	// BalancerMurmur2 hashes keys with murmur2, like the Java client
+	BalancerMurmur2 = "murmur2"
+	// BalancerLeastBytes sends records to the partition that received the
+	// fewest bytes, ignoring keys
+	BalancerLeastBytes = "least-bytes"
+)
+
+// Strategy is a parsed partition key strategy
+type Strategy struct {
+	Kind     string
+	Header   string   // Header name, for KeyHeader
+	Template []string // Path template segments, for KeyPath
+}
+
+// ParseStrategy parses a partition key strategy: request_id, client_ip, null,
+// header:<name> or path:<template>. Template segments in braces are captured;
+// * matches any segment.
+func ParseStrategy(s string) (Strategy, error) {
+	kind, arg, _ := strings.Cut(strings.TrimSpace(s), ":")
+	kind = strings.ToLower(kind)
+	switch kind {
+	case KeyRequestID, KeyClientIP, KeyNull:
+		if arg != "" {
+			return Strategy{}, fmt.Errorf("invalid partition key %q: %s takes no argument", s, kind)
+		}
+		return Strategy{Kind: kind}, nil
+	case KeyHeader:
+		if arg == "" {
+			return Strategy{}, fmt.Errorf("invalid partition key %q: missing header name", s)
+		}
+		return Strategy{Kind: kind, Header: arg}, nil
+	case KeyPath:
+		segments := splitPath(arg)
+		captures := 0
+		for _, seg := range segments {
+			if isCapture(seg) {
+				captures++
+			}
+		}
+		if captures == 0 {
+			return Strategy{}, fmt.Errorf("invalid partition key %q: path template captures no segment", s)
+		}
+		return Strategy{Kind: kind, Template: segments}, nil
+	default:
+		return Strategy{}, fmt.Errorf("invalid partition key: %s", s)
+	}
+}
+
+// String returns the strategy in the form ParseStrategy accepts
+func (s Strategy) String() string {
+	switch s.Kind {
+	case KeyHeader:
+		return KeyHeader + ":" + s.Header
+	case KeyPath:
+		return KeyPath + ":/" + strings.Join(s.Template, "/")
+	default:
+		return s.Kind
+	}
+}
+
+// Key returns the partition key of a request, nil for KeyNull. Requests the
+// strategy finds no value for (missing header, no client IP, path not
+// matching the template) are keyed by their request ID.
+func (s Strategy) Key(req *ingestv1.MirroredRequest) []byte {
+	var key string
+	switch s.Kind {
+	case KeyNull:
+		return nil
+	case KeyHeader:
+		key = routing.HeaderValue(req.GetHeaders(), s.Header)
+	case KeyClientIP:
+		key = clientIP(req.GetHeaders())
+	case KeyPath:
+		key = s.pathKey(req.GetPath())
+	}
+	if key == "" {
+		key = req.GetRequestId()
+	}
+	return []byte(key)
+}
+
+// pathKey returns the segments of path captured by the template, joined by
+// "/", or "" if the path doesn't match
+func (s Strategy) pathKey(path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	segments := splitPath(path)
+	if len(segments) != len(s.Template) {
+		return ""
+	}
+	var captured []string
+	for i, tmpl := range s.Template {
+		switch {
+		case isCapture(tmpl):
+			captured = append(captured, segments[i])
+		case tmpl != "*" && tmpl != segments[i]:
+			return ""
+		}
+	}
+	return strings.Join(captured, "/")
+}
+
+// clientIP returns the original client's address from the first
+// X-Forwarded-For entry or X-Real-IP
+func clientIP(headers map[string]string) string {
+	if fwd := routing.HeaderValue(headers, "X-Forwarded-For"); fwd != "" {
+		first, _, _ := strings.Cut(fwd, ",")
+		if ip := net.ParseIP(strings.TrimSpace(first)); ip != nil {
+			return ip.String()
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(routing.HeaderValue(headers, "X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+func isCapture(segment string) bool {
+	return len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// ParseBalancer validates a balancer name, accepting any case
+func ParseBalancer(s string) (string, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	switch name {
+	case BalancerHash, BalancerMurmur2, BalancerLeastBytes:
+		return name, nil
+	default:
+		return "", fmt.Errorf("invalid partition balancer: %s", s)
+	}
+}
+
+// TopicBalancer is a kafka.Balancer that balances each message with the
+// balancer chosen for its topic, so streams sharing a writer can use
+// different balancers
+type TopicBalancer struct {
+	def       string
+	balancers map[string]kafka.Balancer
+	topics    sync.Map // Topic -> balancer name
+}
+
+// NewTopicBalancer returns a TopicBalancer using def for topics without a
+// balancer of their own
+func NewTopicBalancer(def string) *TopicBalancer {
+	return &TopicBalancer{
+		def: def,
+		balancers: map[string]kafka.Balancer{
+			BalancerHash:       &kafka.Hash{},
+			BalancerMurmur2:    &kafka.Murmur2Balancer{},
+			BalancerLeastBytes: &kafka.LeastBytes{},
+		},
+	}
+}
+
+// Set chooses the balancer for a topic
+func (b *TopicBalancer) Set(topic, balancer string) {
+	b.topics.Store(topic, balancer)
+}
+
+// Balance implements kafka.Balancer
+func (b *TopicBalancer) Balance(msg kafka.Message, partitions ...int) int {
+	name := b.def
+	if v, ok := b.topics.Load(msg.Topic); ok {
+		name = v.(string)
+	}
+	balancer, ok := b.balancers[name]
+	if !ok {
+		balancer = b.balancers[BalancerLeastBytes]
+	}
+	return balancer.Balance(msg, partitions...)
+}
@@ -0,0 +1,88 @@
+package partition
+
+import (
+	"testing"
+
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStrategy(t *testing.T) {
+	for _, s := range []string{"request_id", "CLIENT_IP", "null", "header:X-Session-Id", "path:/users/{user}/*"} {
+		strategy, err := ParseStrategy(s)
+		require.NoError(t, err, s)
+		again, err := ParseStrategy(strategy.String())
+		require.NoError(t, err, s)
+		assert.Equal(t, strategy, again, s)
+	}
+	for _, s := range []string{"", "session", "header:", "path:/users/*", "null:x"} {
+		_, err := ParseStrategy(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestStrategy_Key(t *testing.T) {
+	req := &ingestv1.MirroredRequest{
+		RequestId: "req-1",
+		Path:      "/tenants/acme/users/42?expand=true",
+		Headers: map[string]string{
+			"x-session-id":    "sess-9",
+			"X-Forwarded-For": "203.0.113.7, 10.0.0.1",
+		},
+	}
+	tests := []struct {
+		strategy string
+		want     []byte
+	}{
+		{"request_id", []byte("req-1")},
+		{"header:X-Session-Id", []byte("sess-9")},
+		{"header:X-Missing", []byte("req-1")},
+		{"client_ip", []byte("203.0.113.7")},
+		{"path:/tenants/{tenant}/users/{user}", []byte("acme/42")},
+		{"path:/tenants/{tenant}/*/*", []byte("acme")},
+		{"path:/orders/{order}", []byte("req-1")},
+		{"null", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			strategy, err := ParseStrategy(tt.strategy)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, strategy.Key(req))
+		})
+	}
+}
+
+func TestClientIP_RealIP(t *testing.T) {
+	assert.Equal(t, "198.51.100.2", clientIP(map[string]string{"X-Real-IP": "198.51.100.2"}))
+	assert.Equal(t, "", clientIP(map[string]string{"X-Forwarded-For": "unknown"}))
+}
+
+func TestParseBalancer(t *testing.T) {
+	name, err := ParseBalancer(" Murmur2 ")
+	require.NoError(t, err)
+	assert.Equal(t, BalancerMurmur2, name)
+	_, err = ParseBalancer("sticky")
+	assert.Error(t, err)
+}
+
+func TestTopicBalancer(t *testing.T) {
+	b := NewTopicBalancer(BalancerLeastBytes)
+	b.Set("sessions", BalancerMurmur2)
+	partitions := []int{0, 1, 2, 3, 4, 5, 6, 7}
+
+	msg := kafka.Message{Topic: "sessions", Key: []byte("sess-9")}
+	want := (&kafka.Murmur2Balancer{}).Balance(msg, partitions...)
+	for range 5 {
+		assert.Equal(t, want, b.Balance(msg, partitions...))
+	}
+
+	// Keys are ignored by the default least-bytes balancer
+	other := kafka.Message{Topic: "other", Key: []byte("sess-9")}
+	seen := map[int]bool{}
+	for range len(partitions) {
+		seen[b.Balance(other, partitions...)] = true
+	}
+	assert.Greater(t, len(seen), 1)
+}
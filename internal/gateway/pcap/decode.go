@@ -0,0 +1,166 @@
+package pcap
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"time"
+)
+
+// TCP flags
+const (
+	flagFIN = 0x01
+	flagSYN = 0x02
+	flagRST = 0x04
+)
+
+// Segment is a decoded TCP segment
+type Segment struct {
+	Timestamp time.Time
+	Src, Dst  netip.AddrPort
+	Seq       uint32
+	Flags     uint8
+	Payload   []byte
+}
+
+// SYN reports whether the segment opens a connection
+func (s *Segment) SYN() bool { return s.Flags&flagSYN != 0 }
+
+// DecodeTCP decodes the TCP segment in a packet. It returns nil for anything
+// that isn't an unfragmented TCP packet over IPv4 or IPv6.
+func DecodeTCP(p *Packet) *Segment {
+	ip, ok := linkPayload(p.LinkType, p.Data)
+	if !ok || len(ip) < 1 {
+		return nil
+	}
+
+	var src, dst netip.Addr
+	var tcp []byte
+	switch ip[0] >> 4 {
+	case 4:
+		if src, dst, tcp, ok = decodeIPv4(ip); !ok {
+			return nil
+		}
+	case 6:
+		if src, dst, tcp, ok = decodeIPv6(ip); !ok {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	if len(tcp) < 20 {
+		return nil
+	}
+	dataOffset := int(tcp[12]>>4) * 4
+	if dataOffset < 20 || dataOffset > len(tcp) {
+		return nil
+	}
+	return &Segment{
+		Timestamp: p.Timestamp,
+		Src:       netip.AddrPortFrom(src, binary.BigEndian.Uint16(tcp[0:2])),
+		Dst:       netip.AddrPortFrom(dst, binary.BigEndian.Uint16(tcp[2:4])),
+		Seq:       binary.BigEndian.Uint32(tcp[4:8]),
+		Flags:     tcp[13],
+		Payload:   tcp[dataOffset:],
+	}
+}
+
+// linkPayload strips the link layer header, returning the IP packet
+func linkPayload(linkType int, data []byte) ([]byte, bool) {
+	switch linkType {
+	case LinkTypeEthernet:
+		if len(data) < 14 {
+			return nil, false
+		}
+		etherType := binary.BigEndian.Uint16(data[12:14])
+		data = data[14:]
+		// 802.1Q / 802.1ad VLAN tags
+		for etherType == 0x8100 || etherType == 0x88a8 {
+			if len(data) < 4 {
+				return nil, false
+			}
+			etherType = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+		return data, etherType == 0x0800 || etherType == 0x86dd
+	case LinkTypeLinuxSLL:
+		if len(data) < 16 {
+			return nil, false
+		}
+		proto := binary.BigEndian.Uint16(data[14:16])
+		return data[16:], proto == 0x0800 || proto == 0x86dd
+	case LinkTypeLinuxSLL2:
+		if len(data) < 20 {
+			return nil, false
+		}
+		proto := binary.BigEndian.Uint16(data[0:2])
+		return data[20:], proto == 0x0800 || proto == 0x86dd
+	case LinkTypeNull:
+		// 4-byte address family in the capturing host's byte order
+		if len(data) < 4 {
+			return nil, false
+		}
+		return data[4:], true
+	case LinkTypeRaw, linkTypeRawOpenBSD, linkTypeRawBSD, LinkTypeIPv4, LinkTypeIPv6:
+		return data, true
+	default:
+		return nil, false
+	}
+}
+
+func decodeIPv4(ip []byte) (src, dst netip.Addr, payload []byte, ok bool) {
+	if len(ip) < 20 {
+		return
+	}
+	headerLen := int(ip[0]&0x0f) * 4
+	totalLen := int(binary.BigEndian.Uint16(ip[2:4]))
+	if headerLen < 20 || totalLen < headerLen || len(ip) < headerLen {
+		return
+	}
+	// Fragments can't be decoded without IP reassembly
+	if flagsFrag := binary.BigEndian.Uint16(ip[6:8]); flagsFrag&0x3fff != 0 {
+		return
+	}
+	if ip[9] != 6 { // TCP
+		return
+	}
+	if totalLen < len(ip) {
+		ip = ip[:totalLen] // Drop link layer padding
+	}
+	src = netip.AddrFrom4([4]byte(ip[12:16]))
+	dst = netip.AddrFrom4([4]byte(ip[16:20]))
+	return src, dst, ip[headerLen:], true
+}
+
+func decodeIPv6(ip []byte) (src, dst netip.Addr, payload []byte, ok bool) {
+	if len(ip) < 40 {
+		return
+	}
+	payloadLen := int(binary.BigEndian.Uint16(ip[4:6]))
+	next := ip[6]
+	src = netip.AddrFrom16([16]byte(ip[8:24]))
+	dst = netip.AddrFrom16([16]byte(ip[24:40]))
+	payload = ip[40:]
+	if payloadLen < len(payload) {
+		payload = payload[:payloadLen]
+	}
+	// Skip extension headers
+	for {
+		switch next {
+		case 6: // TCP
+			return src, dst, payload, true
+		case 0, 43, 60: // Hop-by-hop, routing, destination options
+			if len(payload) < 8 {
+				return src, dst, nil, false
+			}
+			extLen := (int(payload[1]) + 1) * 8
+			if extLen > len(payload) {
+				return src, dst, nil, false
+			}
+			next = payload[0]
+			payload = payload[extLen:]
+		default: // Fragments and other protocols
+			return src, dst, nil, false
+		}
+	}
+}
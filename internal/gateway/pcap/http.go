@@ -0,0 +1,163 @@
+package pcap
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/mirror"
+)
+
+// Request is an HTTP request reassembled from a capture
+type Request struct {
+	Timestamp time.Time // Capture time of the request's first byte
+	Client    netip.AddrPort
+	Server    netip.AddrPort
+	HTTP      *http.Request
+	Body      []byte
+}
+
+// Host returns the request's host name, without a port
+func (r *Request) Host() string {
+	host := r.HTTP.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// MirroredRequest converts the request to a mirrored request with a
+// generated request ID and the original capture timestamp
+func (r *Request) MirroredRequest() *capture.Request {
+	return mirror.Capture(r.HTTP, r.HTTP.URL.EscapedPath(), r.Body, r.Timestamp)
+}
+
+// Summary counts what was found in a capture
+type Summary struct {
+	Packets   int // Packets read
+	Segments  int // TCP segments decoded
+	Streams   int // Connection directions that carried data
+	Truncated int // Streams cut short by a gap in the capture
+	Requests  int // HTTP requests reassembled
+}
+
+// ReadRequests reads a capture and returns the HTTP/1.x requests in it,
+// ordered by capture time. Streams that don't start with an HTTP request
+// (responses, TLS, other protocols) are skipped.
+func ReadRequests(r io.Reader) ([]*Request, *Summary, error) {
+	reader, err := NewReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	summary := &Summary{}
+	assembler := NewAssembler()
+	for {
+		p, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		summary.Packets++
+		if seg := DecodeTCP(p); seg != nil {
+			summary.Segments++
+			assembler.Add(seg)
+		}
+	}
+
+	var requests []*Request
+	for _, s := range assembler.Streams() {
+		summary.Streams++
+		if s.Truncated {
+			summary.Truncated++
+		}
+		requests = append(requests, parseRequests(s)...)
+	}
+	sort.SliceStable(requests, func(i, j int) bool { return requests[i].Timestamp.Before(requests[j].Timestamp) })
+	summary.Requests = len(requests)
+	return requests, summary, nil
+}
+
+// parseRequests parses the HTTP requests in a client-to-server stream,
+// stopping at the first request that is malformed or incomplete
+func parseRequests(s *Stream) []*Request {
+	if !looksLikeRequest(s.Data) {
+		return nil
+	}
+	data := bytes.NewReader(s.Data)
+	br := bufio.NewReader(data)
+	var requests []*Request
+	for {
+		offset := len(s.Data) - data.Len() - br.Buffered()
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return requests
+		}
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return requests
+		}
+		requests = append(requests, &Request{
+			Timestamp: s.TimeAt(offset),
+			Client:    s.Src,
+			Server:    s.Dst,
+			HTTP:      req,
+			Body:      body,
+		})
+	}
+}
+
+// looksLikeRequest reports whether data starts with an HTTP method token
+func looksLikeRequest(data []byte) bool {
+	method, _, ok := bytes.Cut(data[:min(len(data), 16)], []byte(" "))
+	if !ok || len(method) == 0 {
+		return false
+	}
+	for _, c := range method {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter selects requests by server. Empty fields match everything.
+type Filter struct {
+	Hosts []string // Host header names or server IP addresses
+	Ports []uint16 // Server ports
+}
+
+// Matches reports whether a request passes the filter
+func (f *Filter) Matches(r *Request) bool {
+	if len(f.Ports) > 0 {
+		found := false
+		for _, port := range f.Ports {
+			if r.Server.Port() == port {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.Hosts) > 0 {
+		host, ip := r.Host(), r.Server.Addr().Unmap().String()
+		for _, h := range f.Hosts {
+			if strings.EqualFold(h, host) || h == ip {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
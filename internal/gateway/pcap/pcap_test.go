@@ -0,0 +1,221 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	client = netip.MustParseAddrPort("10.0.0.1:50000")
+	server = netip.MustParseAddrPort("10.0.0.2:8080")
+	t0     = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+// ethernetTCP builds an Ethernet/IPv4/TCP frame
+func ethernetTCP(src, dst netip.AddrPort, seq uint32, flags uint8, payload string) []byte {
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:2], src.Port())
+	binary.BigEndian.PutUint16(tcp[2:4], dst.Port())
+	binary.BigEndian.PutUint32(tcp[4:8], seq)
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	tcp = append(tcp, payload...)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+	ip[8] = 64
+	ip[9] = 6
+	s, d := src.Addr().As4(), dst.Addr().As4()
+	copy(ip[12:16], s[:])
+	copy(ip[16:20], d[:])
+
+	eth := make([]byte, 14)
+	binary.BigEndian.PutUint16(eth[12:14], 0x0800)
+	return append(append(eth, ip...), tcp...)
+}
+
+type frame struct {
+	ts   time.Time
+	data []byte
+}
+
+// conversation is a client connection whose first request arrives in two
+// segments out of order and with a retransmission, followed by a pipelined
+// POST, plus the server's response
+func conversation() []frame {
+	const isn = 0xfffffff0 // Wraps around during the connection
+	req1a := "GET /users?id=7 HTTP/1.1\r\nHost: api.example.com\r\n"
+	req1b := "Accept: application/json\r\n\r\n"
+	req2 := "POST /orders HTTP/1.1\r\nHost: api.example.com\r\nContent-Length: 5\r\n\r\nhello"
+	off := func(n int) uint32 { return uint32(isn + 1 + n) }
+	return []frame{
+		{t0, ethernetTCP(client, server, isn, flagSYN, "")},
+		{t0.Add(1 * time.Millisecond), ethernetTCP(server, client, 1000, flagSYN|0x10, "")},
+		{t0.Add(2 * time.Millisecond), ethernetTCP(client, server, off(len(req1a)), 0x18, req1b)},
+		{t0.Add(3 * time.Millisecond), ethernetTCP(client, server, off(0), 0x18, req1a)},
+		{t0.Add(4 * time.Millisecond), ethernetTCP(client, server, off(0), 0x18, req1a)},
+		{t0.Add(5 * time.Millisecond), ethernetTCP(server, client, 1001, 0x18, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")},
+		{t0.Add(6 * time.Millisecond), ethernetTCP(client, server, off(len(req1a)+len(req1b)), 0x18, req2)},
+	}
+}
+
+func classicPcap(frames []frame) []byte {
+	var b bytes.Buffer
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:4], magicNanos)
+	binary.LittleEndian.PutUint16(header[4:6], 2)
+	binary.LittleEndian.PutUint16(header[6:8], 4)
+	binary.LittleEndian.PutUint32(header[16:20], 65535)
+	binary.LittleEndian.PutUint32(header[20:24], LinkTypeEthernet)
+	b.Write(header)
+	for _, f := range frames {
+		rec := make([]byte, 16)
+		binary.LittleEndian.PutUint32(rec[0:4], uint32(f.ts.Unix()))
+		binary.LittleEndian.PutUint32(rec[4:8], uint32(f.ts.Nanosecond()))
+		binary.LittleEndian.PutUint32(rec[8:12], uint32(len(f.data)))
+		binary.LittleEndian.PutUint32(rec[12:16], uint32(len(f.data)))
+		b.Write(rec)
+		b.Write(f.data)
+	}
+	return b.Bytes()
+}
+
+func pcapngBlock(blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	length := uint32(12 + len(body))
+	b := binary.BigEndian.AppendUint32(nil, blockType)
+	b = binary.BigEndian.AppendUint32(b, length)
+	b = append(b, body...)
+	return binary.BigEndian.AppendUint32(b, length)
+}
+
+// pcapngFile writes a big-endian pcapng with millisecond timestamps
+func pcapngFile(frames []frame) []byte {
+	var b bytes.Buffer
+	shb := binary.BigEndian.AppendUint32(nil, 0x1a2b3c4d)
+	shb = binary.BigEndian.AppendUint16(shb, 1)
+	shb = binary.BigEndian.AppendUint16(shb, 0)
+	shb = binary.BigEndian.AppendUint64(shb, 0xffffffffffffffff)
+	b.Write(pcapngBlock(magicSectionBlock, shb))
+
+	idb := binary.BigEndian.AppendUint16(nil, LinkTypeEthernet)
+	idb = binary.BigEndian.AppendUint16(idb, 0)
+	idb = binary.BigEndian.AppendUint32(idb, 65535)
+	idb = append(idb, 0, 9, 0, 1, 3, 0, 0, 0) // if_tsresol = 10^-3
+	idb = append(idb, 0, 0, 0, 0)             // opt_endofopt
+	b.Write(pcapngBlock(blockInterface, idb))
+
+	for _, f := range frames {
+		ts := uint64(f.ts.UnixMilli())
+		epb := binary.BigEndian.AppendUint32(nil, 0)
+		epb = binary.BigEndian.AppendUint32(epb, uint32(ts>>32))
+		epb = binary.BigEndian.AppendUint32(epb, uint32(ts))
+		epb = binary.BigEndian.AppendUint32(epb, uint32(len(f.data)))
+		epb = binary.BigEndian.AppendUint32(epb, uint32(len(f.data)))
+		epb = append(epb, f.data...)
+		b.Write(pcapngBlock(blockEnhancedPacket, epb))
+	}
+	return b.Bytes()
+}
+
+func TestReadRequests(t *testing.T) {
+	formats := map[string][]byte{
+		"pcap":   classicPcap(conversation()),
+		"pcapng": pcapngFile(conversation()),
+	}
+	for name, data := range formats {
+		t.Run(name, func(t *testing.T) {
+			requests, summary, err := ReadRequests(bytes.NewReader(data))
+			require.NoError(t, err)
+			assert.Equal(t, &Summary{Packets: 7, Segments: 7, Streams: 2, Requests: 2}, summary)
+			require.Len(t, requests, 2)
+
+			get := requests[0]
+			assert.Equal(t, "GET", get.HTTP.Method)
+			assert.Equal(t, "api.example.com", get.Host())
+			assert.Equal(t, client, get.Client)
+			assert.Equal(t, server, get.Server)
+			// The first byte arrived in the second (retransmitted order) segment
+			assert.Equal(t, t0.Add(3*time.Millisecond), get.Timestamp)
+
+			mirrored := get.MirroredRequest()
+			assert.Equal(t, "/users", mirrored.Path)
+			assert.Equal(t, map[string]string{"id": "7"}, mirrored.Query)
+			assert.Equal(t, "application/json", mirrored.Headers["accept"])
+			assert.Equal(t, t0.Add(3*time.Millisecond).UnixNano(), mirrored.TimestampNs)
+
+			post := requests[1]
+			assert.Equal(t, "POST", post.HTTP.Method)
+			assert.Equal(t, []byte("hello"), post.Body)
+			assert.Equal(t, t0.Add(6*time.Millisecond), post.Timestamp)
+		})
+	}
+}
+
+func TestReadRequests_Gap(t *testing.T) {
+	frames := conversation()
+	// Drop the first half of the first request
+	frames = append(frames[:3], frames[5:]...)
+	requests, summary, err := ReadRequests(bytes.NewReader(classicPcap(frames)))
+	require.NoError(t, err)
+	assert.Equal(t, 1, summary.Truncated)
+	assert.Empty(t, requests)
+}
+
+func TestReadRequests_NotACapture(t *testing.T) {
+	_, _, err := ReadRequests(bytes.NewReader([]byte("GET / HTTP/1.1\r\n\r\n")))
+	assert.Error(t, err)
+}
+
+func TestFilter_Matches(t *testing.T) {
+	requests, _, err := ReadRequests(bytes.NewReader(classicPcap(conversation())))
+	require.NoError(t, err)
+	req := requests[0]
+
+	assert.True(t, (&Filter{}).Matches(req))
+	assert.True(t, (&Filter{Ports: []uint16{80, 8080}}).Matches(req))
+	assert.False(t, (&Filter{Ports: []uint16{443}}).Matches(req))
+	assert.True(t, (&Filter{Hosts: []string{"API.example.com"}}).Matches(req))
+	assert.True(t, (&Filter{Hosts: []string{"10.0.0.2"}}).Matches(req))
+	assert.False(t, (&Filter{Hosts: []string{"other.example.com"}}).Matches(req))
+}
+
+func TestReadRequests_TruncatedBlocks(t *testing.T) {
+	tests := []struct {
+		name      string
+		blockType uint32
+		body      []byte
+	}{
+		{name: "empty enhanced packet", blockType: blockEnhancedPacket},
+		{name: "short enhanced packet", blockType: blockEnhancedPacket, body: make([]byte, 16)},
+		{name: "empty obsolete packet", blockType: blockPacketObsolete},
+		{name: "short obsolete packet", blockType: blockPacketObsolete, body: make([]byte, 12)},
+		{name: "empty simple packet", blockType: blockSimplePacket},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A section and interface without packets, then the bad block
+			data := append(pcapngFile(nil), pcapngBlock(tt.blockType, tt.body)...)
+			_, _, err := ReadRequests(bytes.NewReader(data))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func FuzzReadRequests(f *testing.F) {
+	f.Add(classicPcap(conversation()))
+	f.Add(pcapngFile(conversation()))
+	f.Add(append(pcapngFile(nil), pcapngBlock(blockEnhancedPacket, nil)...))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _, _ = ReadRequests(bytes.NewReader(data))
+	})
+}
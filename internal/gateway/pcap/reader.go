@@ -0,0 +1,288 @@
+// Package pcap reads packet captures (pcap and pcapng, as written by tcpdump
+// or Wireshark) and reassembles the HTTP/1.x requests in them, in pure Go.
+package pcap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Link types (https://www.tcpdump.org/linktypes.html)
+const (
+	LinkTypeNull      = 0
+	LinkTypeEthernet  = 1
+	LinkTypeRaw       = 101
+	LinkTypeLinuxSLL  = 113
+	LinkTypeIPv4      = 228
+	LinkTypeIPv6      = 229
+	LinkTypeLinuxSLL2 = 276
+
+	// Link types some platforms use for raw IP instead of 101
+	linkTypeRawOpenBSD = 12
+	linkTypeRawBSD     = 14
+)
+
+// Packet is a captured packet
+type Packet struct {
+	Timestamp time.Time
+	LinkType  int
+	Data      []byte
+}
+
+// Reader reads packets from a pcap or pcapng capture
+type Reader interface {
+	// Next returns the next packet, or io.EOF at the end of the capture
+	Next() (*Packet, error)
+}
+
+// File magic numbers
+const (
+	magicMicros       = 0xa1b2c3d4
+	magicNanos        = 0xa1b23c4d
+	magicSectionBlock = 0x0a0d0d0a
+)
+
+// NewReader detects the capture format and returns a reader for it
+func NewReader(r io.Reader) (Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read capture header: %w", err)
+	}
+	switch {
+	case binary.BigEndian.Uint32(magic) == magicSectionBlock:
+		return newNGReader(br)
+	case binary.LittleEndian.Uint32(magic) == magicMicros, binary.BigEndian.Uint32(magic) == magicMicros,
+		binary.LittleEndian.Uint32(magic) == magicNanos, binary.BigEndian.Uint32(magic) == magicNanos:
+		return newClassicReader(br)
+	default:
+		return nil, errors.New("not a pcap or pcapng file")
+	}
+}
+
+// classicReader reads the original libpcap format
+type classicReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nanos    bool
+	linkType int
+	header   [16]byte
+}
+
+func newClassicReader(r io.Reader) (*classicReader, error) {
+	var header [24]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("failed to read pcap header: %w", err)
+	}
+	c := &classicReader{r: r, order: binary.LittleEndian}
+	magic := binary.LittleEndian.Uint32(header[0:4])
+	if magic != magicMicros && magic != magicNanos {
+		c.order = binary.BigEndian
+		magic = binary.BigEndian.Uint32(header[0:4])
+	}
+	c.nanos = magic == magicNanos
+	// The upper bits of the link type field carry FCS information
+	c.linkType = int(c.order.Uint32(header[20:24]) & 0x0fffffff)
+	return c, nil
+}
+
+func (c *classicReader) Next() (*Packet, error) {
+	if _, err := io.ReadFull(c.r, c.header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("truncated packet header: %w", err)
+		}
+		return nil, err
+	}
+	sec := int64(c.order.Uint32(c.header[0:4]))
+	frac := int64(c.order.Uint32(c.header[4:8]))
+	capLen := c.order.Uint32(c.header[8:12])
+	if capLen > maxPacketSize {
+		return nil, fmt.Errorf("packet length %d exceeds maximum", capLen)
+	}
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return nil, fmt.Errorf("truncated packet: %w", err)
+	}
+	if !c.nanos {
+		frac *= 1000
+	}
+	return &Packet{Timestamp: time.Unix(sec, frac).UTC(), LinkType: c.linkType, Data: data}, nil
+}
+
+// maxPacketSize guards against corrupt length fields
+const maxPacketSize = 1 << 24
+
+// pcapng block types
+const (
+	blockInterface      = 0x00000001
+	blockPacketObsolete = 0x00000002
+	blockSimplePacket   = 0x00000003
+	blockEnhancedPacket = 0x00000006
+)
+
+// packetBlockHeader is the size of the fields before the packet data of an
+// enhanced or obsolete packet block
+const packetBlockHeader = 20
+
+// pcapngInterface is an interface described in a pcapng section
+type pcapngInterface struct {
+	linkType int
+	// Timestamp units per second
+	resolution uint64
+}
+
+// ngReader reads the pcapng format
+type ngReader struct {
+	r          io.Reader
+	order      binary.ByteOrder
+	interfaces []pcapngInterface
+}
+
+func newNGReader(r io.Reader) (*ngReader, error) {
+	ng := &ngReader{r: r}
+	if err := ng.readSectionHeader(); err != nil {
+		return nil, err
+	}
+	return ng, nil
+}
+
+// readSectionHeader reads a section header block, which resets the byte
+// order and interface list
+func (ng *ngReader) readSectionHeader() error {
+	var header [12]byte
+	if _, err := io.ReadFull(ng.r, header[:]); err != nil {
+		return fmt.Errorf("failed to read pcapng section header: %w", err)
+	}
+	switch binary.LittleEndian.Uint32(header[8:12]) {
+	case 0x1a2b3c4d:
+		ng.order = binary.LittleEndian
+	case 0x4d3c2b1a:
+		ng.order = binary.BigEndian
+	default:
+		return errors.New("invalid pcapng byte-order magic")
+	}
+	length := ng.order.Uint32(header[4:8])
+	if length < 28 || length > maxPacketSize {
+		return fmt.Errorf("invalid pcapng section header length %d", length)
+	}
+	// Skip the rest of the block (version, section length, options, trailer)
+	if _, err := io.CopyN(io.Discard, ng.r, int64(length)-12); err != nil {
+		return fmt.Errorf("truncated pcapng section header: %w", err)
+	}
+	ng.interfaces = nil
+	return nil
+}
+
+func (ng *ngReader) Next() (*Packet, error) {
+	for {
+		var header [8]byte
+		if _, err := io.ReadFull(ng.r, header[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return nil, fmt.Errorf("truncated block header: %w", err)
+			}
+			return nil, err
+		}
+		if binary.BigEndian.Uint32(header[0:4]) == magicSectionBlock {
+			// Re-read the section header with its first 8 bytes put back
+			ng.r = io.MultiReader(bytes.NewReader(header[:]), ng.r)
+			if err := ng.readSectionHeader(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		blockType := ng.order.Uint32(header[0:4])
+		length := ng.order.Uint32(header[4:8])
+		if length < 12 || length%4 != 0 || length > maxPacketSize {
+			return nil, fmt.Errorf("invalid pcapng block length %d", length)
+		}
+		body := make([]byte, length-12)
+		if _, err := io.ReadFull(ng.r, body); err != nil {
+			return nil, fmt.Errorf("truncated pcapng block: %w", err)
+		}
+		if _, err := io.CopyN(io.Discard, ng.r, 4); err != nil {
+			return nil, fmt.Errorf("truncated pcapng block: %w", err)
+		}
+
+		switch blockType {
+		case blockInterface:
+			if err := ng.addInterface(body); err != nil {
+				return nil, err
+			}
+		case blockEnhancedPacket:
+			if len(body) < packetBlockHeader {
+				return nil, errors.New("truncated pcapng enhanced packet block")
+			}
+			return ng.packet(body, packetBlockHeader, ng.order.Uint32(body[0:4]), ng.order.Uint32(body[4:8]), ng.order.Uint32(body[8:12]), ng.order.Uint32(body[12:16]))
+		case blockPacketObsolete:
+			if len(body) < packetBlockHeader {
+				return nil, errors.New("truncated pcapng packet block")
+			}
+			return ng.packet(body, packetBlockHeader, uint32(ng.order.Uint16(body[0:2])), ng.order.Uint32(body[4:8]), ng.order.Uint32(body[8:12]), ng.order.Uint32(body[12:16]))
+		case blockSimplePacket:
+			if len(body) < 4 || len(ng.interfaces) == 0 {
+				return nil, errors.New("invalid pcapng simple packet block")
+			}
+			data := body[4:]
+			if origLen := ng.order.Uint32(body[0:4]); int(origLen) < len(data) {
+				data = data[:origLen]
+			}
+			return &Packet{LinkType: ng.interfaces[0].linkType, Data: data}, nil
+		}
+		// Other blocks (statistics, name resolution, ...) are skipped
+	}
+}
+
+// packet builds a packet from a packet block whose data starts at offset
+func (ng *ngReader) packet(body []byte, offset int, ifaceID, tsHigh, tsLow, capLen uint32) (*Packet, error) {
+	if len(body) < offset || int(ifaceID) >= len(ng.interfaces) || int(capLen) > len(body)-offset {
+		return nil, errors.New("invalid pcapng packet block")
+	}
+	iface := ng.interfaces[ifaceID]
+	ts := uint64(tsHigh)<<32 | uint64(tsLow)
+	sec := ts / iface.resolution
+	nanos := (ts % iface.resolution) * uint64(time.Second) / iface.resolution
+	return &Packet{
+		Timestamp: time.Unix(int64(sec), int64(nanos)).UTC(),
+		LinkType:  iface.linkType,
+		Data:      body[offset : offset+int(capLen)],
+	}, nil
+}
+
+// addInterface parses an interface description block
+func (ng *ngReader) addInterface(body []byte) error {
+	if len(body) < 8 {
+		return errors.New("invalid pcapng interface block")
+	}
+	iface := pcapngInterface{linkType: int(ng.order.Uint16(body[0:2])), resolution: 1_000_000}
+	// Options: code, length, value padded to 4 bytes
+	for opts := body[8:]; len(opts) >= 4; {
+		code := ng.order.Uint16(opts[0:2])
+		length := int(ng.order.Uint16(opts[2:4]))
+		if code == 0 || 4+length > len(opts) { // opt_endofopt or truncated
+			break
+		}
+		if code == 9 && length >= 1 { // if_tsresol
+			v := opts[4]
+			exp := uint64(v & 0x7f)
+			if v&0x80 == 0 && exp <= 19 {
+				iface.resolution = uint64(math.Pow10(int(exp)))
+			} else if v&0x80 != 0 && exp < 64 {
+				iface.resolution = 1 << exp
+			}
+		}
+		next := 4 + (length+3)&^3
+		if next > len(opts) {
+			break
+		}
+		opts = opts[next:]
+	}
+	ng.interfaces = append(ng.interfaces, iface)
+	return nil
+}
@@ -0,0 +1,135 @@
+package pcap
+
+import (
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// Stream is the reassembled byte stream of one direction of a TCP connection
+type Stream struct {
+	Src, Dst netip.AddrPort
+	Data     []byte
+	// Truncated is set when a gap (lost or uncaptured segment) cut the
+	// stream short; Data holds the bytes before the gap
+	Truncated bool
+
+	marks []mark // Capture time of the segment each byte range came from
+}
+
+// mark records that the bytes from offset on arrived at ts
+type mark struct {
+	offset int
+	ts     time.Time
+}
+
+// TimeAt returns the capture time of the segment carrying the byte at offset
+func (s *Stream) TimeAt(offset int) time.Time {
+	i := sort.Search(len(s.marks), func(i int) bool { return s.marks[i].offset > offset })
+	if i == 0 {
+		return time.Time{}
+	}
+	return s.marks[i-1].ts
+}
+
+type flowKey struct {
+	src, dst netip.AddrPort
+}
+
+// flow collects the segments of one direction of a connection
+type flow struct {
+	key      flowKey
+	isn      uint32 // Sequence number of the first data byte, when the SYN was seen
+	haveISN  bool
+	segments []*Segment
+}
+
+// Assembler reassembles the byte streams of the TCP connections in a capture
+type Assembler struct {
+	active map[flowKey]*flow
+	flows  []*flow // In order of first appearance
+}
+
+// NewAssembler creates an empty assembler
+func NewAssembler() *Assembler {
+	return &Assembler{active: make(map[flowKey]*flow)}
+}
+
+// Add adds a captured segment
+func (a *Assembler) Add(seg *Segment) {
+	key := flowKey{src: seg.Src, dst: seg.Dst}
+	f := a.active[key]
+	// A new SYN on a used 4-tuple starts a new connection
+	if f == nil || (seg.SYN() && (!f.haveISN || f.isn != seg.Seq+1) && len(f.segments) > 0) {
+		f = &flow{key: key}
+		a.active[key] = f
+		a.flows = append(a.flows, f)
+	}
+	if seg.SYN() {
+		f.isn = seg.Seq + 1
+		f.haveISN = true
+	}
+	if len(seg.Payload) > 0 {
+		f.segments = append(f.segments, seg)
+	}
+}
+
+// Streams returns the reassembled streams that carried data, in order of
+// their first segment
+func (a *Assembler) Streams() []*Stream {
+	streams := make([]*Stream, 0, len(a.flows))
+	for _, f := range a.flows {
+		if len(f.segments) > 0 {
+			streams = append(streams, f.reassemble())
+		}
+	}
+	return streams
+}
+
+// reassemble orders the flow's segments by sequence number, dropping
+// retransmitted bytes, and stops at the first gap
+func (f *flow) reassemble() *Stream {
+	type positioned struct {
+		offset int64
+		seg    *Segment
+	}
+	// Offsets are relative to the ISN when the SYN was captured, otherwise
+	// to the lowest sequence number seen (handling wraparound)
+	segs := make([]positioned, len(f.segments))
+	base := f.isn
+	if !f.haveISN {
+		base = f.segments[0].Seq
+	}
+	var min int64
+	for i, seg := range f.segments {
+		var off int64
+		if f.haveISN {
+			off = int64(seg.Seq - base)
+		} else {
+			off = int64(int32(seg.Seq - base))
+		}
+		segs[i] = positioned{offset: off, seg: seg}
+		if off < min {
+			min = off
+		}
+	}
+	sort.SliceStable(segs, func(i, j int) bool { return segs[i].offset < segs[j].offset })
+
+	s := &Stream{Src: f.key.src, Dst: f.key.dst}
+	var cursor int64
+	for _, p := range segs {
+		off := p.offset - min
+		end := off + int64(len(p.seg.Payload))
+		if end <= cursor {
+			continue // Retransmission of bytes already assembled
+		}
+		if off > cursor {
+			s.Truncated = true
+			break
+		}
+		s.marks = append(s.marks, mark{offset: int(cursor), ts: p.seg.Timestamp})
+		s.Data = append(s.Data, p.seg.Payload[cursor-off:]...)
+		cursor = end
+	}
+	return s
+}
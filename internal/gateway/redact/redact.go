@@ -0,0 +1,59 @@
+// Package redact masks sensitive header values in captured traffic before it
+// is published, so credentials and session cookies of mirrored clients don't
+// end up on stream topics.
+package redact
+
+import (
+	"strings"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+)
+
+// Mask replaces the value of a redacted header. The header itself is kept so
+// consumers can tell it was present.
+const Mask = "[REDACTED]"
+
+// DefaultHeaders are the headers redacted unless configured otherwise
+var DefaultHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization", "X-Api-Key"}
+
+// Headers redacts a set of headers, matched case-insensitively
+type Headers struct {
+	names map[string]bool
+}
+
+// NewHeaders returns a redactor for the named headers; none redacts nothing
+func NewHeaders(names []string) *Headers {
+	h := &Headers{names: make(map[string]bool, len(names))}
+	for _, name := range names {
+		h.names[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	return h
+}
+
+// Apply masks the redacted headers of a header map in place
+func (h *Headers) Apply(headers map[string]string) {
+	if h == nil || len(h.names) == 0 {
+		return
+	}
+	for name := range headers {
+		if h.names[strings.ToLower(name)] {
+			headers[name] = Mask
+		}
+	}
+}
+
+// Request masks the redacted headers of a captured request and of its
+// response, if captured with it
+func (h *Headers) Request(req *capture.Request) {
+	if req.MirroredRequest != nil {
+		h.Apply(req.Headers)
+	}
+	h.Response(req.Response)
+}
+
+// Response masks the redacted headers of a captured response
+func (h *Headers) Response(resp *capture.Response) {
+	if resp != nil {
+		h.Apply(resp.Headers)
+	}
+}
@@ -0,0 +1,60 @@
+package redact
+
+import (
+	"testing"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaders_Apply(t *testing.T) {
+	tests := []struct {
+		name    string
+		names   []string
+		headers map[string]string
+		want    map[string]string
+	}{
+		{
+			name:    "defaults",
+			names:   DefaultHeaders,
+			headers: map[string]string{"authorization": "Bearer t", "Cookie": "a=1", "set-cookie": "b=2", "proxy-authorization": "Basic x", "X-API-KEY": "k", "accept": "*/*"},
+			want:    map[string]string{"authorization": Mask, "Cookie": Mask, "set-cookie": Mask, "proxy-authorization": Mask, "X-API-KEY": Mask, "accept": "*/*"},
+		},
+		{
+			name:    "custom",
+			names:   []string{" x-session "},
+			headers: map[string]string{"X-Session": "s", "authorization": "Bearer t"},
+			want:    map[string]string{"X-Session": Mask, "authorization": "Bearer t"},
+		},
+		{
+			name:    "disabled",
+			headers: map[string]string{"authorization": "Bearer t"},
+			want:    map[string]string{"authorization": "Bearer t"},
+		},
+		{
+			name:  "no headers",
+			names: DefaultHeaders,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			NewHeaders(tt.names).Apply(tt.headers)
+			assert.Equal(t, tt.want, tt.headers)
+		})
+	}
+}
+
+func TestHeaders_Request(t *testing.T) {
+	req := &capture.Request{
+		MirroredRequest: &ingestv1.MirroredRequest{Headers: map[string]string{"cookie": "a=1", "accept": "*/*"}},
+		Response:        &capture.Response{Status: 200, Headers: map[string]string{"set-cookie": "b=2"}},
+	}
+	NewHeaders(DefaultHeaders).Request(req)
+
+	assert.Equal(t, map[string]string{"cookie": Mask, "accept": "*/*"}, req.Headers)
+	assert.Equal(t, map[string]string{"set-cookie": Mask}, req.Response.Headers)
+
+	// Requests without a message or response are left alone
+	NewHeaders(DefaultHeaders).Request(&capture.Request{})
+}
@@ -0,0 +1,78 @@
+package routing
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Recent remembers where recent requests were routed so that responses
+// captured after their request follow it to the same destinations. It is
+// bounded in size; the oldest entries are evicted first.
+type Recent struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List // Oldest first
+}
+
+type recentEntry struct {
+	key          string
+	destinations []Destination
+	expires      time.Time
+}
+
+// NewRecent creates a Recent holding at most size entries for ttl each
+func NewRecent(size int, ttl time.Duration) *Recent {
+	return &Recent{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func recentKey(streamID, requestID string) string {
+	return streamID + "\x00" + requestID
+}
+
+// Add records the destinations a request on a stream was published to
+func (r *Recent) Add(streamID, requestID string, destinations []Destination) {
+	if r.size <= 0 || requestID == "" {
+		return
+	}
+	key := recentKey(streamID, requestID)
+	entry := &recentEntry{key: key, destinations: destinations, expires: time.Now().Add(r.ttl)}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if el, ok := r.entries[key]; ok {
+		r.order.Remove(el)
+	}
+	r.entries[key] = r.order.PushBack(entry)
+	for r.order.Len() > r.size {
+		oldest := r.order.Front()
+		r.order.Remove(oldest)
+		delete(r.entries, oldest.Value.(*recentEntry).key)
+	}
+}
+
+// Get returns the destinations of a recent request, if it is still remembered
+func (r *Recent) Get(streamID, requestID string) ([]Destination, bool) {
+	key := recentKey(streamID, requestID)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	el, ok := r.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*recentEntry)
+	if time.Now().After(entry.expires) {
+		r.order.Remove(el)
+		delete(r.entries, key)
+		return nil, false
+	}
+	return entry.destinations, true
+}
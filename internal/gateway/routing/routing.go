@@ -0,0 +1,154 @@
+// Package routing implements content-based routing of mirrored requests from a
+// "router" stream to one or more destination streams.
+package routing
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+)
+
+// Mode controls how many rules may match a single request
+type Mode string
+
+const (
+	// ModeFirstMatch routes to the destination of the first matching rule only
+	ModeFirstMatch Mode = "first_match"
+	// ModeAllMatch routes to the destinations of every matching rule
+	ModeAllMatch Mode = "all_match"
+)
+
+// Field is the MirroredRequest field a rule matches against
+type Field string
+
+const (
+	// FieldHost matches the Host header exactly (case-insensitive)
+	FieldHost Field = "host"
+	// FieldPathPrefix matches when the request path starts with the rule value
+	FieldPathPrefix Field = "path_prefix"
+	// FieldHeader matches when the named header equals the rule value
+	FieldHeader Field = "header"
+)
+
+// ParseMode validates and returns a routing mode
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case ModeFirstMatch, ModeAllMatch:
+		return Mode(s), nil
+	case "":
+		return ModeFirstMatch, nil
+	default:
+		return "", fmt.Errorf("invalid routing mode: %s", s)
+	}
+}
+
+// ParseField validates and returns a rule match field
+func ParseField(s string) (Field, error) {
+	switch Field(s) {
+	case FieldHost, FieldPathPrefix, FieldHeader:
+		return Field(s), nil
+	default:
+		return "", fmt.Errorf("invalid routing field: %s", s)
+	}
+}
+
+// Destination identifies a stream a request is routed to
+type Destination struct {
+	StreamID string // Stream UUID
+	Stream   string // Stream name
+	Topic    string // Broker topic of the stream
+}
+
+// Rule routes requests whose Field matches Value to Destination
+type Rule struct {
+	ID          string
+	Priority    int // Lower values are evaluated first
+	Field       Field
+	HeaderName  string // Only used with FieldHeader
+	Value       string
+	Destination Destination
+}
+
+// Matches reports whether the rule matches the given request
+func (r *Rule) Matches(req *ingestv1.MirroredRequest) bool {
+	if req == nil {
+		return false
+	}
+	switch r.Field {
+	case FieldHost:
+		host := HeaderValue(req.Headers, "Host")
+		// Ignore the port so "api.example.com" matches "api.example.com:8443"
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		return host != "" && strings.EqualFold(host, r.Value)
+	case FieldPathPrefix:
+		return strings.HasPrefix(req.Path, r.Value)
+	case FieldHeader:
+		value := HeaderValue(req.Headers, r.HeaderName)
+		return value != "" && value == r.Value
+	default:
+		return false
+	}
+}
+
+// Table is the set of routing rules configured for a router stream
+type Table struct {
+	Mode     Mode
+	Rules    []Rule
+	Fallback *Destination // Used when no rule matches; may be nil
+}
+
+// NewTable creates a routing table with rules sorted by priority
+func NewTable(mode Mode, rules []Rule, fallback *Destination) *Table {
+	sorted := make([]Rule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+	return &Table{Mode: mode, Rules: sorted, Fallback: fallback}
+}
+
+// Evaluate returns the destinations for a request. Duplicate destinations are
+// collapsed so a request is never published twice to the same topic. An empty
+// result means neither a rule nor the fallback applied.
+func (t *Table) Evaluate(req *ingestv1.MirroredRequest) []Destination {
+	var dests []Destination
+	seen := make(map[string]bool)
+
+	for i := range t.Rules {
+		rule := &t.Rules[i]
+		if !rule.Matches(req) {
+			continue
+		}
+		if !seen[rule.Destination.Topic] {
+			seen[rule.Destination.Topic] = true
+			dests = append(dests, rule.Destination)
+		}
+		if t.Mode != ModeAllMatch {
+			break
+		}
+	}
+
+	if len(dests) == 0 && t.Fallback != nil {
+		dests = append(dests, *t.Fallback)
+	}
+	return dests
+}
+
+// HeaderValue looks up a header case-insensitively, since SDKs don't agree
+// on header name canonicalisation
+func HeaderValue(headers map[string]string, name string) string {
+	if v, ok := headers[name]; ok {
+		return v
+	}
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package routing
+
+import (
+	"testing"
+	"time"
+
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTable_Evaluate(t *testing.T) {
+	orders := Destination{Stream: "orders", Topic: "stream-t-orders"}
+	billing := Destination{Stream: "billing", Topic: "stream-t-billing"}
+	acme := Destination{Stream: "acme", Topic: "stream-t-acme"}
+	other := Destination{Stream: "other", Topic: "stream-t-other"}
+
+	rules := []Rule{
+		{ID: "3", Priority: 30, Field: FieldHeader, HeaderName: "X-Tenant-Id", Value: "acme", Destination: acme},
+		{ID: "1", Priority: 10, Field: FieldHost, Value: "orders.example.com", Destination: orders},
+		{ID: "2", Priority: 20, Field: FieldPathPrefix, Value: "/billing/", Destination: billing},
+	}
+
+	req := &ingestv1.MirroredRequest{
+		Path: "/billing/invoices",
+		Headers: map[string]string{
+			"host":        "Orders.Example.com:8443",
+			"x-tenant-id": "acme",
+		},
+	}
+
+	t.Run("first match uses priority order", func(t *testing.T) {
+		table := NewTable(ModeFirstMatch, rules, &other)
+		assert.Equal(t, []Destination{orders}, table.Evaluate(req))
+	})
+
+	t.Run("all match returns every matching destination", func(t *testing.T) {
+		table := NewTable(ModeAllMatch, rules, &other)
+		assert.Equal(t, []Destination{orders, billing, acme}, table.Evaluate(req))
+	})
+
+	t.Run("duplicate destinations are collapsed", func(t *testing.T) {
+		dup := append([]Rule{{ID: "4", Priority: 40, Field: FieldPathPrefix, Value: "/", Destination: orders}}, rules...)
+		table := NewTable(ModeAllMatch, dup, nil)
+		assert.Equal(t, []Destination{orders, billing, acme}, table.Evaluate(req))
+	})
+
+	t.Run("fallback when nothing matches", func(t *testing.T) {
+		table := NewTable(ModeFirstMatch, rules, &other)
+		got := table.Evaluate(&ingestv1.MirroredRequest{Path: "/health"})
+		assert.Equal(t, []Destination{other}, got)
+	})
+
+	t.Run("no fallback yields no destinations", func(t *testing.T) {
+		table := NewTable(ModeFirstMatch, rules, nil)
+		assert.Empty(t, table.Evaluate(&ingestv1.MirroredRequest{Path: "/health"}))
+	})
+
+	t.Run("header rule requires a value", func(t *testing.T) {
+		rule := Rule{Field: FieldHeader, HeaderName: "X-Tenant-Id", Value: ""}
+		assert.False(t, rule.Matches(&ingestv1.MirroredRequest{}))
+	})
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("")
+	require.NoError(t, err)
+	assert.Equal(t, ModeFirstMatch, mode)
+
+	mode, err = ParseMode("all_match")
+	require.NoError(t, err)
+	assert.Equal(t, ModeAllMatch, mode)
+
+	_, err = ParseMode("random")
+	assert.Error(t, err)
+}
+
+func TestRecent(t *testing.T) {
+	orders := []Destination{{Stream: "orders", Topic: "stream-t-orders"}}
+	billing := []Destination{{Stream: "billing", Topic: "stream-t-billing"}}
+
+	t.Run("remembers destinations per stream", func(t *testing.T) {
+		recent := NewRecent(10, time.Minute)
+		recent.Add("s1", "req-1", orders)
+
+		got, ok := recent.Get("s1", "req-1")
+		require.True(t, ok)
+		assert.Equal(t, orders, got)
+
+		_, ok = recent.Get("s2", "req-1")
+		assert.False(t, ok)
+	})
+
+	t.Run("evicts the oldest entry when full", func(t *testing.T) {
+		recent := NewRecent(2, time.Minute)
+		recent.Add("s1", "req-1", orders)
+		recent.Add("s1", "req-2", billing)
+		recent.Add("s1", "req-3", orders)
+
+		_, ok := recent.Get("s1", "req-1")
+		assert.False(t, ok)
+		_, ok = recent.Get("s1", "req-3")
+		assert.True(t, ok)
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		recent := NewRecent(10, -time.Second)
+		recent.Add("s1", "req-1", orders)
+		_, ok := recent.Get("s1", "req-1")
+		assert.False(t, ok)
+	})
+}
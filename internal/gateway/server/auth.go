@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/frkr-io/frkr-common/plugins"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
+)
+
+// authenticate validates the credentials of r and, when streamName is set,
+// checks that the caller may write to the stream. Failures wrap the
+// apierror.ErrAuth* sentinels so apierror.AuthCode can classify them without
+// looking at messages; errors of plugins that don't wrap one count as
+// invalid credentials, or missing ones when r has no Authorization header.
+func (s *IngestGatewayServer) authenticate(ctx context.Context, r *http.Request, streamName string) (*plugins.AuthResult, error) {
+	if s.AuthPlugin == nil || s.SecretPlugin == nil {
+		return nil, errors.New("auth is not configured")
+	}
+
+	authResult, err := s.AuthPlugin.ValidateRequest(ctx, r, s.SecretPlugin)
+	if err != nil {
+		switch {
+		case isAuthError(err):
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		case r.Header.Get("Authorization") == "":
+			return nil, fmt.Errorf("%w: %v", apierror.ErrAuthMissing, err)
+		default:
+			return nil, fmt.Errorf("%w: %v", apierror.ErrAuthInvalid, err)
+		}
+	}
+	if authResult == nil {
+		return nil, errors.New("authentication returned nil result")
+	}
+	// Records and access checks are attributed to the user or client
+	if authResult.UserID == "" && authResult.ClientID == "" {
+		return nil, fmt.Errorf("%w: no user or client identified", apierror.ErrAuthInvalid)
+	}
+	if streamName == "" {
+		return authResult, nil
+	}
+
+	allowed, err := s.AuthPlugin.CanAccessStream(ctx, authResult, streamName, "write")
+	if err != nil {
+		if isAuthError(err) {
+			return nil, fmt.Errorf("authorization check failed: %w", err)
+		}
+		return nil, fmt.Errorf("%w: authorization check failed: %v", apierror.ErrAuthForbidden, err)
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s has no write permission for stream %s", apierror.ErrAuthForbidden, authUser(authResult), streamName)
+	}
+	return authResult, nil
+}
+
+// isAuthError reports whether err wraps one of the apierror.ErrAuth* sentinels
+func isAuthError(err error) bool {
+	return errors.Is(err, apierror.ErrAuthMissing) || errors.Is(err, apierror.ErrAuthInvalid) || errors.Is(err, apierror.ErrAuthForbidden)
+}
+
+// authUser returns the user or client an auth result is for
+func authUser(authResult *plugins.AuthResult) string {
+	if authResult.UserID != "" {
+		return authResult.UserID
+	}
+	return authResult.ClientID
+}
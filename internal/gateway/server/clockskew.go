@@ -0,0 +1,65 @@
+package server
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"github.com/frkr-io/frkr-common/plugins"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/clockskew"
+)
+
+// checkClockSkew measures the skew of a client's request timestamp from the
+// receive time, recording it per stream and client. Timestamps skewed beyond
+// the threshold are annotated, and replaced with the receive time when
+// correction is on. Timestamps the gateway filled in and imported requests
+// are skipped.
+func (s *IngestGatewayServer) checkClockSkew(streamName string, authResult *plugins.AuthResult, req *capture.Request, receivedAt time.Time) {
+	if req.TimestampNs == 0 || req.Imported || slices.Contains(req.ServerGenerated, capture.GeneratedTimestamp) {
+		return
+	}
+	skew := clockskew.Skew(req.TimestampNs, receivedAt)
+	recordClockSkew(streamName, skewClient(authResult), skew)
+
+	policy := s.Config.ClockSkew
+	if !policy.Exceeds(skew) {
+		return
+	}
+	log.Printf("Clock skew of %v from %s on stream %s exceeds threshold", skew, skewIdentity(authResult), streamName)
+	req.ClockSkew = skew
+	if policy.Correct {
+		req.OriginalTimestampNs = req.TimestampNs
+		req.TimestampNs = receivedAt.UnixNano()
+	}
+}
+
+// skewClient returns the client label of the clock skew metric. Only client
+// IDs are used as label values; users are unbounded in number, so they share
+// the "user" label and are identified in the log instead.
+func skewClient(authResult *plugins.AuthResult) string {
+	switch {
+	case authResult == nil:
+		return "unknown"
+	case authResult.ClientID != "":
+		return authResult.ClientID
+	case authResult.UserID != "":
+		return "user"
+	default:
+		return "unknown"
+	}
+}
+
+// skewIdentity returns the user or client ID logged for skewed timestamps
+func skewIdentity(authResult *plugins.AuthResult) string {
+	switch {
+	case authResult == nil:
+		return "unknown"
+	case authResult.UserID != "":
+		return "user " + authResult.UserID
+	case authResult.ClientID != "":
+		return "client " + authResult.ClientID
+	default:
+		return "unknown"
+	}
+}
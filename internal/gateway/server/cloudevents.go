@@ -0,0 +1,142 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/frkr-io/frkr-common/metrics"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/cloudevents"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/deadletter"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/durability"
+)
+
+// CloudEventsBatchResult is the response body of a batch mode
+// POST /ingest/cloudevents request
+type CloudEventsBatchResult struct {
+	Stream    string                  `json:"stream"`
+	Events    int                     `json:"events"`
+	Published int                     `json:"published"`
+	Failures  []CloudEventsBatchError `json:"failures,omitempty"`
+}
+
+// CloudEventsBatchError describes an event of a batch that could not be
+// published
+type CloudEventsBatchError struct {
+	Event   int    `json:"event"` // Index in the batch
+	ID      string `json:"id"`
+	Status  int    `json:"status"`
+	Code    string `json:"code"` // apierror.Code*
+	Message string `json:"message"`
+}
+
+// CloudEventsHandler handles POST /ingest/cloudevents/{stream}[/path] requests
+// carrying CloudEvents in binary, structured or batch mode. Each event is
+// published as a mirrored request through the normal ingest pipeline, with
+// its attributes in the record and in frkr-ce-* record headers. Every event of
+// a batch is validated before any is published; events that then fail to
+// publish are listed in the response, which is 202 when every event was
+// published, 207 when only some were, and the first failure's status when
+// none were.
+func (s *IngestGatewayServer) CloudEventsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		statusCode := http.StatusAccepted
+
+		defer func() {
+			duration := time.Since(start).Seconds()
+			metrics.RecordIngestRequest(r.Method, "/ingest/cloudevents", strconv.Itoa(statusCode), duration)
+		}()
+
+		if r.Method != http.MethodPost {
+			ierr := errMethodNotAllowed()
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+
+		streamName, path, ok := cloudevents.SplitPath(r.URL.Path)
+		if !ok {
+			statusCode = http.StatusNotFound
+			http.NotFound(w, r)
+			return
+		}
+
+		// Check if we're ready
+		if !s.HealthChecker.IsReady() {
+			ierr := errNotReady()
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+
+		// Authenticate and authorize
+		ctx := r.Context()
+		authResult, err := s.authenticate(ctx, r, streamName)
+		if err != nil {
+			ierr := authError(err)
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+
+		// Parse the events
+		body, ierr := s.readBody(w, r)
+		if ierr != nil {
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+		events, err := cloudevents.Decode(r, body)
+		if err != nil {
+			statusCode = http.StatusBadRequest
+			s.deadLetter(ctx, &deadletter.Record{
+				Reason:   deadletter.ReasonValidationFailed,
+				Error:    err.Error(),
+				StreamID: streamName,
+				TenantID: authResult.TenantID,
+				Value:    body,
+			})
+			writeIngestError(w, errInvalid(err))
+			return
+		}
+
+		if !cloudevents.IsBatch(r) {
+			// A single event is bounded by the request size limit
+			req := events[0].MirroredRequest(path, start)
+			result, ierr := s.ingest(ctx, authResult, streamName, req, int64(len(body)), r.Header.Get(durability.Header), start)
+			if ierr != nil {
+				statusCode = ierr.status
+				writeIngestError(w, ierr)
+				return
+			}
+			writeAccepted(w, r, result)
+			return
+		}
+
+		// Batched events are checked against the header and body limits; the
+		// batch as a whole was bounded while reading it
+		result := CloudEventsBatchResult{Stream: streamName, Events: len(events)}
+		for i, event := range events {
+			req := event.MirroredRequest(path, start)
+			if _, ierr := s.ingest(ctx, authResult, streamName, req, 0, r.Header.Get(durability.Header), start); ierr != nil {
+				result.Failures = append(result.Failures, CloudEventsBatchError{Event: i, ID: event.ID, Status: ierr.status, Code: ierr.code, Message: ierr.message})
+				continue
+			}
+			result.Published++
+		}
+
+		switch {
+		case len(result.Failures) == 0:
+			statusCode = http.StatusAccepted
+		case result.Published > 0:
+			statusCode = http.StatusMultiStatus
+		default:
+			statusCode = result.Failures[0].Status
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		_ = json.NewEncoder(w).Encode(result)
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/cloudevents"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloudEventsHandler_Binary(t *testing.T) {
+	s, w := newTestServer(t)
+
+	r := httptest.NewRequest(http.MethodPost, "/ingest/cloudevents/orders/created", strings.NewReader(`{"total":42}`))
+	r.Header.Set("Authorization", validToken)
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Ce-Specversion", "1.0")
+	r.Header.Set("Ce-Id", "evt-1")
+	r.Header.Set("Ce-Source", "/orders")
+	r.Header.Set("Ce-Type", "com.example.order.created")
+	rec := httptest.NewRecorder()
+	s.CloudEventsHandler()(rec, r)
+
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	var result IngestResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, "orders", result.Stream)
+	assert.NotEmpty(t, result.RequestID)
+
+	messages := w.written()
+	require.Len(t, messages, 1)
+	msg := messages[0]
+	assert.Equal(t, "frkr.orders", msg.Topic)
+	assert.Equal(t, "evt-1", header(msg, metadata.HeaderCloudEventID))
+	assert.Equal(t, "/orders", header(msg, metadata.HeaderCloudEventSource))
+	assert.Equal(t, "com.example.order.created", header(msg, metadata.HeaderCloudEventType))
+	req := decodeRecord(t, msg)
+	assert.Equal(t, "/created", req.Path)
+	assert.Equal(t, `{"total":42}`, req.Body)
+	require.NotNil(t, req.CloudEvent)
+	assert.Equal(t, "evt-1", req.CloudEvent.ID)
+	assert.Equal(t, result.RequestID, req.RequestId)
+}
+
+func TestCloudEventsHandler_Batch(t *testing.T) {
+	s, w := newTestServer(t)
+	s.Config.Limits.MaxBodyBytes = 16
+
+	body := `[
+		{"specversion":"1.0","id":"a","source":"/s","type":"t","data":"small"},
+		{"specversion":"1.0","id":"b","source":"/s","type":"t","data":"` + strings.Repeat("x", 17) + `"},
+		{"specversion":"1.0","id":"c","source":"/s","type":"t"}
+	]`
+	r := httptest.NewRequest(http.MethodPost, "/ingest/cloudevents/orders", strings.NewReader(body))
+	r.Header.Set("Authorization", validToken)
+	r.Header.Set("Content-Type", cloudevents.MediaTypeBatch)
+	rec := httptest.NewRecorder()
+	s.CloudEventsHandler()(rec, r)
+
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	var result CloudEventsBatchResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, "orders", result.Stream)
+	assert.Equal(t, 3, result.Events)
+	assert.Equal(t, 2, result.Published)
+	require.Len(t, result.Failures, 1)
+	assert.Equal(t, CloudEventsBatchError{
+		Event:   1,
+		ID:      "b",
+		Status:  http.StatusRequestEntityTooLarge,
+		Code:    apierror.CodePayloadTooLarge,
+		Message: result.Failures[0].Message,
+	}, result.Failures[0])
+
+	messages := w.written()
+	require.Len(t, messages, 2)
+	assert.Equal(t, "a", header(messages[0], metadata.HeaderCloudEventID))
+	assert.Equal(t, "c", header(messages[1], metadata.HeaderCloudEventID))
+}
+
+func TestCloudEventsHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		body        string
+		contentType string
+		auth        string
+		status      int
+		code        string
+	}{
+		{name: "method", method: http.MethodGet, path: "/ingest/cloudevents/orders", auth: validToken, status: http.StatusMethodNotAllowed, code: apierror.CodeMethodNotAllowed},
+		{name: "missing credentials", path: "/ingest/cloudevents/orders", contentType: cloudevents.MediaTypeStructured, body: `{}`, status: http.StatusUnauthorized, code: apierror.CodeAuthMissing},
+		{name: "invalid credentials", path: "/ingest/cloudevents/orders", contentType: cloudevents.MediaTypeStructured, body: `{}`, auth: "Bearer wrong", status: http.StatusUnauthorized, code: apierror.CodeAuthInvalid},
+		{name: "forbidden stream", path: "/ingest/cloudevents/forbidden", contentType: cloudevents.MediaTypeStructured, body: `{}`, auth: validToken, status: http.StatusForbidden, code: apierror.CodeAuthForbidden},
+		{name: "missing attributes", path: "/ingest/cloudevents/orders", contentType: cloudevents.MediaTypeStructured, body: `{"specversion":"1.0"}`, auth: validToken, status: http.StatusBadRequest, code: apierror.CodeInvalidRequest},
+		{
+			name:        "stale event time",
+			path:        "/ingest/cloudevents/orders",
+			contentType: cloudevents.MediaTypeStructured,
+			body:        `{"specversion":"1.0","id":"a","source":"/s","type":"t","time":"2020-01-01T00:00:00Z"}`,
+			auth:        validToken,
+			status:      http.StatusBadRequest,
+			code:        apierror.CodeInvalidRequest,
+		},
+		{
+			name:        "unknown stream",
+			path:        "/ingest/cloudevents/missing",
+			contentType: cloudevents.MediaTypeStructured,
+			body:        `{"specversion":"1.0","id":"a","source":"/s","type":"t"}`,
+			auth:        validToken,
+			status:      http.StatusNotFound,
+			code:        apierror.CodeStreamNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, w := newTestServer(t)
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+
+			r := httptest.NewRequest(method, tt.path, strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			s.CloudEventsHandler()(rec, r)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.code, decodeError(t, rec).Code)
+			assert.Empty(t, w.written())
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	tapv3 "github.com/envoyproxy/go-control-plane/envoy/service/tap/v3"
+	"github.com/frkr-io/frkr-common/metrics"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/envoy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// envoyReceiver receives traffic observed by Envoy over gRPC, as an external
+// processor and as a tap sink. It never changes traffic: every ext_proc
+// message is answered with "continue", or not at all in observability mode.
+type envoyReceiver struct {
+	extprocv3.UnimplementedExternalProcessorServer
+	tapv3.UnimplementedTapSinkServiceServer
+
+	s      *IngestGatewayServer
+	stream string // Stream used when Envoy doesn't send x-frkr-stream
+}
+
+// EnvoyGRPCServer returns a gRPC server implementing Envoy's ext_proc and tap
+// sink services. Each observed request is published, with its response, as a
+// mirrored request for the stream in the x-frkr-stream metadata or, without
+// it, for stream. Envoy authenticates with the authorization metadata.
+// Like the mirror sink, requests that can't be published are dropped and
+// counted.
+func (s *IngestGatewayServer) EnvoyGRPCServer(stream string) *grpc.Server {
+	receiver := &envoyReceiver{s: s, stream: stream}
+	srv := grpc.NewServer()
+	extprocv3.RegisterExternalProcessorServer(srv, receiver)
+	tapv3.RegisterTapSinkServiceServer(srv, receiver)
+	return srv
+}
+
+// Process handles one ext_proc stream, which covers a single HTTP request
+func (e *envoyReceiver) Process(srv extprocv3.ExternalProcessor_ProcessServer) error {
+	start := time.Now()
+	streamName, authReq := e.source(srv.Context())
+	defer func() {
+		metrics.RecordIngestRequest("GRPC", "/envoy/ext_proc", "200", time.Since(start).Seconds())
+	}()
+
+	exchange := envoy.NewExchange(e.s.Config.Limits.MaxRequestBytes)
+	for !exchange.Done() {
+		msg, err := srv.Recv()
+		if err != nil {
+			// Envoy ends the stream when the HTTP stream ends, possibly
+			// before the response was observed; publish what was seen
+			if !errors.Is(err, io.EOF) && status.Code(err) != codes.Canceled {
+				log.Printf("Envoy ext_proc stream for %s failed: %v", streamName, err)
+			}
+			break
+		}
+		exchange.Add(msg, time.Now())
+		if !msg.GetObservabilityMode() {
+			if err := srv.Send(envoy.Continue(msg)); err != nil {
+				return err
+			}
+		}
+	}
+
+	req, err := exchange.Request()
+	switch {
+	case errors.Is(err, envoy.ErrBodyTooLarge):
+		recordMirrorDrop(streamName, mirrorDropTooLarge)
+		return nil
+	case err != nil:
+		log.Printf("Dropping Envoy ext_proc exchange for %s: %v", streamName, err)
+		recordMirrorDrop(streamName, mirrorDropInvalid)
+		return nil
+	case !e.s.HealthChecker.IsReady():
+		recordMirrorDrop(streamName, mirrorDropNotReady)
+		return nil
+	}
+
+	e.s.publishInBackground(streamName, func(ctx context.Context) string {
+		return e.s.publishMirrored(ctx, authReq.WithContext(ctx), streamName, req, start)
+	})
+	return nil
+}
+
+// StreamTaps handles a tap sink stream, which carries traces for as long as
+// Envoy stays connected. The source is authenticated once per stream. Only
+// buffered HTTP traces are supported; streamed trace segments are dropped.
+func (e *envoyReceiver) StreamTaps(srv tapv3.TapSinkService_StreamTapsServer) error {
+	ctx := srv.Context()
+	streamName, authReq := e.source(ctx)
+	authResult, err := e.s.authenticate(ctx, authReq, streamName)
+	if err != nil {
+		log.Printf("Envoy tap authentication failed for stream %s: %v", streamName, err)
+		metrics.RecordAuthFailure("frkr-ingest-gateway", "auth_failed")
+		recordMirrorDrop(streamName, mirrorDropAuthFailed)
+		return status.Error(codes.Unauthenticated, "unauthorized")
+	}
+
+	for {
+		msg, err := srv.Recv()
+		if errors.Is(err, io.EOF) {
+			return srv.SendAndClose(&tapv3.StreamTapsResponse{})
+		}
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+
+		trace := msg.GetTrace().GetHttpBufferedTrace()
+		if trace == nil {
+			recordMirrorDrop(streamName, mirrorDropUnsupported)
+			continue
+		}
+		req, err := envoy.FromBufferedTrace(trace, start)
+		if err != nil {
+			log.Printf("Dropping Envoy tap trace for %s: %v", streamName, err)
+			recordMirrorDrop(streamName, mirrorDropInvalid)
+			continue
+		}
+		if !e.s.HealthChecker.IsReady() {
+			recordMirrorDrop(streamName, mirrorDropNotReady)
+			continue
+		}
+
+		e.s.publishInBackground(streamName, func(ctx context.Context) string {
+			return e.s.ingestMirrored(ctx, authResult, streamName, req, start)
+		})
+		metrics.RecordIngestRequest("GRPC", "/envoy/tap", "200", time.Since(start).Seconds())
+	}
+}
+
+// source returns the stream a gRPC call publishes to and a request carrying
+// its credentials for authentication
+func (e *envoyReceiver) source(ctx context.Context) (string, *http.Request) {
+	streamName := e.stream
+	authReq, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(envoy.MetadataStream); len(v) > 0 && v[0] != "" {
+			streamName = v[0]
+		}
+		if v := md.Get(envoy.MetadataAuthorization); len(v) > 0 {
+			authReq.Header.Set("Authorization", v[0])
+		}
+	}
+	return streamName, authReq
+}
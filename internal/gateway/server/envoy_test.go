@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tapdatav3 "github.com/envoyproxy/go-control-plane/envoy/data/tap/v3"
+	extprocv3 "github.com/envoyproxy/go-control-plane/envoy/service/ext_proc/v3"
+	tapv3 "github.com/envoyproxy/go-control-plane/envoy/service/tap/v3"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/envoy"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialEnvoy serves the Envoy receiver of s for stream in memory and returns
+// a client connection to it
+func dialEnvoy(t *testing.T, s *IngestGatewayServer, stream string) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := s.EnvoyGRPCServer(stream)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///envoy",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// envoyContext returns a context carrying the metadata Envoy sends
+func envoyContext(t *testing.T, kv ...string) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+func bufferedTrace(method, path, body string) *tapv3.StreamTapsRequest {
+	return &tapv3.StreamTapsRequest{Trace: &tapdatav3.TraceWrapper{Trace: &tapdatav3.TraceWrapper_HttpBufferedTrace{
+		HttpBufferedTrace: &tapdatav3.HttpBufferedTrace{
+			Request: &tapdatav3.HttpBufferedTrace_Message{
+				Headers: []*corev3.HeaderValue{
+					{Key: ":method", Value: method},
+					{Key: ":path", Value: path},
+					{Key: "x-request-id", Value: "envoy-1"},
+				},
+				Body: &tapdatav3.Body{BodyType: &tapdatav3.Body_AsString{AsString: body}},
+			},
+			Response: &tapdatav3.HttpBufferedTrace_Message{
+				Headers: []*corev3.HeaderValue{{Key: ":status", Value: "200"}},
+			},
+		},
+	}}}
+}
+
+func TestEnvoyStreamTaps(t *testing.T) {
+	s, w := newTestServer(t)
+	client := tapv3.NewTapSinkServiceClient(dialEnvoy(t, s, "orders"))
+
+	stream, err := client.StreamTaps(envoyContext(t, envoy.MetadataAuthorization, validToken))
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(bufferedTrace("POST", "/orders", `{"id":1}`)))
+	_, err = stream.CloseAndRecv()
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return len(w.written()) == 1 }, time.Second, 5*time.Millisecond)
+	msg := w.written()[0]
+	assert.Equal(t, "frkr.orders", msg.Topic)
+	assert.Equal(t, "envoy-1", string(msg.Key))
+	req := decodeRecord(t, msg)
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "/orders", req.Path)
+	assert.Equal(t, `{"id":1}`, req.Body)
+	require.NotNil(t, req.Response)
+	assert.Equal(t, 200, req.Response.Status)
+}
+
+func TestEnvoyStreamTaps_Unauthenticated(t *testing.T) {
+	tests := []struct {
+		name string
+		kv   []string
+	}{
+		{name: "missing credentials"},
+		{name: "invalid credentials", kv: []string{envoy.MetadataAuthorization, "Bearer wrong"}},
+		{name: "forbidden stream", kv: []string{envoy.MetadataAuthorization, validToken, envoy.MetadataStream, "forbidden"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, w := newTestServer(t)
+			client := tapv3.NewTapSinkServiceClient(dialEnvoy(t, s, "orders"))
+
+			stream, err := client.StreamTaps(envoyContext(t, tt.kv...))
+			require.NoError(t, err)
+			_, err = stream.CloseAndRecv()
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+			assert.Empty(t, w.written())
+		})
+	}
+}
+
+func TestEnvoyProcess(t *testing.T) {
+	s, w := newTestServer(t)
+	client := extprocv3.NewExternalProcessorClient(dialEnvoy(t, s, ""))
+
+	stream, err := client.Process(envoyContext(t, envoy.MetadataAuthorization, validToken, envoy.MetadataStream, "orders"))
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_RequestHeaders{
+		RequestHeaders: &extprocv3.HttpHeaders{
+			Headers: &corev3.HeaderMap{Headers: []*corev3.HeaderValue{
+				{Key: ":method", RawValue: []byte("GET")},
+				{Key: ":path", RawValue: []byte("/orders/1")},
+			}},
+			EndOfStream: true,
+		},
+	}}))
+
+	// Every message is answered with "continue"
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.IsType(t, &extprocv3.ProcessingResponse_RequestHeaders{}, resp.Response)
+	require.NoError(t, stream.CloseSend())
+
+	require.Eventually(t, func() bool { return len(w.written()) == 1 }, time.Second, 5*time.Millisecond)
+	req := decodeRecord(t, w.written()[0])
+	assert.Equal(t, "GET", req.Method)
+	assert.Equal(t, "/orders/1", req.Path)
+	assert.Nil(t, req.Response)
+}
+
+// Exchanges that fail validation are dropped as invalid
+func TestEnvoyProcess_Invalid(t *testing.T) {
+	s, w := newTestServer(t)
+	client := extprocv3.NewExternalProcessorClient(dialEnvoy(t, s, ""))
+	dropped := testutil.ToFloat64(mirrorDroppedTotal.WithLabelValues("orders", mirrorDropInvalid))
+
+	stream, err := client.Process(envoyContext(t, envoy.MetadataAuthorization, validToken, envoy.MetadataStream, "orders"))
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&extprocv3.ProcessingRequest{Request: &extprocv3.ProcessingRequest_RequestHeaders{
+		RequestHeaders: &extprocv3.HttpHeaders{
+			Headers: &corev3.HeaderMap{Headers: []*corev3.HeaderValue{
+				{Key: ":method", RawValue: []byte("GET")},
+				{Key: ":path", RawValue: []byte("/orders/1")},
+				{Key: "x-bad", RawValue: []byte("a\nb")},
+			}},
+			EndOfStream: true,
+		},
+	}}))
+	_, err = stream.Recv()
+	require.NoError(t, err)
+	require.NoError(t, stream.CloseSend())
+
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(mirrorDroppedTotal.WithLabelValues("orders", mirrorDropInvalid)) == dropped+1
+	}, time.Second, 5*time.Millisecond)
+	assert.Empty(t, w.written())
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/frkr-io/frkr-common/metrics"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/validation"
+)
+
+// retryAfter is how long clients are asked to wait before retrying a
+// retryable error
+const retryAfter = 5 * time.Second
+
+// newIngestError returns the error for a failure with an error code
+func newIngestError(code, message string) *ingestError {
+	return &ingestError{status: apierror.Status(code), code: code, message: message}
+}
+
+// errMethodNotAllowed is the error for a request with an unsupported method
+func errMethodNotAllowed() *ingestError {
+	return newIngestError(apierror.CodeMethodNotAllowed, "Method not allowed")
+}
+
+// errNotReady is the error for a request received while the gateway's
+// dependencies are not ready
+func errNotReady() *ingestError {
+	return newIngestError(apierror.CodeServiceUnavailable, "Service unavailable - dependencies not ready")
+}
+
+// errInvalid is the error for a request that could not be decoded or
+// validated, listing the invalid fields when validation found them
+func errInvalid(err error) *ingestError {
+	ierr := newIngestError(apierror.CodeInvalidRequest, "Invalid request: "+err.Error())
+	var verr *validation.Error
+	if errors.As(err, &verr) {
+		ierr.fields = verr.Fields
+	}
+	return ierr
+}
+
+// authError is the error for a failed authentication or authorization. The
+// plugin's reason is logged, not returned.
+func authError(err error) *ingestError {
+	log.Printf("Authentication failed: %v", err)
+	metrics.RecordAuthFailure("frkr-ingest-gateway", "auth_failed")
+	code := apierror.AuthCode(err)
+	switch code {
+	case apierror.CodeAuthMissing:
+		return newIngestError(code, "Missing credentials")
+	case apierror.CodeAuthForbidden:
+		return newIngestError(code, "Not allowed to write to stream")
+	case apierror.CodeInternal:
+		return newIngestError(code, "Failed to authenticate request")
+	default:
+		return newIngestError(code, "Unauthorized")
+	}
+}
+
+// withRequestID sets the ID of the request that failed, returning e
+func (e *ingestError) withRequestID(id string) *ingestError {
+	e.requestID = id
+	return e
+}
+
+// writeIngestError writes the JSON error response for a failed ingest
+func writeIngestError(w http.ResponseWriter, e *ingestError) {
+	resp := apierror.Error{
+		Code:      e.code,
+		Message:   e.message,
+		Retryable: apierror.Retryable(e.code),
+		RequestID: e.requestID,
+	}
+	if resp.Code == "" {
+		resp.Code = apierror.CodeInternal
+	}
+	if resp.Retryable {
+		resp.RetryAfter = int(retryAfter / time.Second)
+	}
+	if e.violation != nil {
+		resp.Limit = e.violation.Limit
+		resp.Max = e.violation.Max
+	}
+	resp.Fields = e.fields
+	writeError(w, e.status, resp)
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, status int, resp apierror.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if resp.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(resp.RetryAfter))
+	}
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
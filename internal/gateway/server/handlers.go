@@ -1,19 +1,24 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 
-	dbcommon "github.com/frkr-io/frkr-common/db"
-	"github.com/frkr-io/frkr-common/gateway"
 	"github.com/frkr-io/frkr-common/metrics"
+	"github.com/frkr-io/frkr-common/models"
+	"github.com/frkr-io/frkr-common/plugins"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/deadletter"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/durability"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/limits"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/metadata"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/routing"
 	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
-	"github.com/segmentio/kafka-go"
 )
 
 // IngestHandler handles POST /ingest requests
@@ -30,99 +35,283 @@ func (s *IngestGatewayServer) IngestHandler() http.HandlerFunc {
 
 		// TODO: Is there a better way to do this? Maybe HTTP method annotations that are enforced by middleware?
 		if r.Method != http.MethodPost {
-			statusCode = http.StatusMethodNotAllowed
-			http.Error(w, "Method not allowed", statusCode)
+			ierr := errMethodNotAllowed()
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
 			return
 		}
 
 		// Check if we're ready
 		if !s.HealthChecker.IsReady() {
-			statusCode = http.StatusServiceUnavailable
-			http.Error(w, "Service unavailable - dependencies not ready", statusCode)
+			ierr := errNotReady()
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
 			return
 		}
 
 		// Parse request
-		var req ingestv1.IngestRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ctx := r.Context()
+		body, ierr := s.readBody(w, r)
+		if ierr != nil {
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+		req, err := capture.Decode(body)
+		if err != nil {
 			statusCode = http.StatusBadRequest
-			http.Error(w, fmt.Sprintf("Invalid request: %v", err), statusCode)
+			s.deadLetterRejected(ctx, r, body, err)
+			writeIngestError(w, errInvalid(err))
 			return
 		}
-		streamID = req.StreamId
+		streamID = req.StreamID
+		requestID := ""
+		if req.Request != nil {
+			requestID = req.Request.GetRequestId()
+		}
 
 		// Authenticate and authorize
+		authResult, err := s.authenticate(ctx, r, req.StreamID)
+		if err != nil {
+			ierr := authError(err).withRequestID(requestID)
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+
+		if req.Request != nil && req.Request.MirroredRequest != nil {
+			req.Request.Imported, _ = strconv.ParseBool(r.Header.Get(HeaderImport))
+		}
+
+		// Validate and publish to the stream's destinations
+		result, ierr := s.ingest(ctx, authResult, streamID, req.Request, int64(len(body)), r.Header.Get(durability.Header), start)
+		if ierr != nil {
+			// The request ID is assigned once the request is valid
+			if req.Request != nil {
+				requestID = req.Request.GetRequestId()
+			}
+			statusCode = ierr.status
+			writeIngestError(w, ierr.withRequestID(requestID))
+			return
+		}
+
+		// Success
+		writeAccepted(w, r, result)
+	}
+}
+
+// ResponseHandler handles POST /ingest/response requests, which capture the
+// response to a request ingested earlier. The response is published with the
+// request's ID as key to the destinations the request was routed to, so
+// consumers can pair them.
+func (s *IngestGatewayServer) ResponseHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		statusCode := http.StatusAccepted
+
+		defer func() {
+			duration := time.Since(start).Seconds()
+			metrics.RecordIngestRequest(r.Method, "/ingest/response", strconv.Itoa(statusCode), duration)
+		}()
+
+		if r.Method != http.MethodPost {
+			ierr := errMethodNotAllowed()
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+
+		// Check if we're ready
+		if !s.HealthChecker.IsReady() {
+			ierr := errNotReady()
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+
+		// Parse request
 		ctx := r.Context()
-		_, err := gateway.AuthenticateHTTPRequest(ctx, r, s.AuthPlugin, s.SecretPlugin, req.StreamId, "write")
+		body, ierr := s.readBody(w, r)
+		if ierr != nil {
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+		req, err := capture.DecodeResponse(body)
 		if err != nil {
-			log.Printf("Authentication failed: %v", err)
-			statusCode = http.StatusUnauthorized
-			metrics.RecordAuthFailure("frkr-ingest-gateway", "auth_failed")
-			http.Error(w, "Unauthorized", statusCode)
+			statusCode = http.StatusBadRequest
+			s.deadLetterRejected(ctx, r, body, err)
+			writeIngestError(w, errInvalid(err))
 			return
 		}
 
-		// Get stream topic from database
-		topic, err := dbcommon.GetStreamTopic(s.DB, req.StreamId)
+		requestID := ""
+		if req.Response != nil {
+			requestID = req.Response.RequestID
+		}
+
+		// Authenticate and authorize
+		authResult, err := s.authenticate(ctx, r, req.StreamID)
 		if err != nil {
-			log.Printf("Failed to get stream topic: %v", err)
-			statusCode = http.StatusNotFound
-			http.Error(w, "Stream not found", statusCode)
+			ierr := authError(err)
+			statusCode = ierr.status
+			writeIngestError(w, ierr.withRequestID(requestID))
 			return
 		}
 
-		// Serialize request
-		messageData, err := json.Marshal(req.Request)
+		if err := req.Validate(); err != nil {
+			statusCode = http.StatusBadRequest
+			s.deadLetter(ctx, &deadletter.Record{
+				Reason:   deadletter.ReasonValidationFailed,
+				Error:    err.Error(),
+				StreamID: req.StreamID,
+				TenantID: authResult.TenantID,
+				Value:    body,
+			})
+			writeIngestError(w, errInvalid(err).withRequestID(requestID))
+			return
+		}
+
+		// Look up the stream
+		stream, ierr := s.lookupStream(ctx, req.StreamID)
+		if ierr != nil {
+			statusCode = ierr.status
+			writeIngestError(w, ierr.withRequestID(requestID))
+			return
+		}
+
+		mode, ierr := s.streamDurability(ctx, stream, r.Header.Get(durability.Header))
+		if ierr != nil {
+			statusCode = ierr.status
+			writeIngestError(w, ierr.withRequestID(requestID))
+			return
+		}
+
+		// Enforce the stream's size limits
+		streamLimits, err := s.streamLimits(ctx, stream)
 		if err != nil {
+			log.Printf("Failed to get settings for stream %s: %v", req.StreamID, err)
 			statusCode = http.StatusInternalServerError
-			http.Error(w, "Failed to serialize request", statusCode)
+			writeIngestError(w, newIngestError(apierror.CodeInternal, "Failed to look up stream").withRequestID(requestID))
+			return
+		}
+		if v := streamLimits.CheckMessage(req.Response.Headers, req.Response.Body); v != nil {
+			ierr := tooLarge(stream.Name, v)
+			statusCode = ierr.status
+			writeIngestError(w, ierr.withRequestID(requestID))
 			return
 		}
 
-		// Write to broker
-		err = s.Writer.WriteMessages(r.Context(), kafka.Message{
-			Topic: topic,
-			Key:   []byte(req.Request.RequestId),
-			Value: messageData,
-		})
-		if err != nil {
-			log.Printf("Failed to write to broker: %v", err)
-			errStr := err.Error()
-			if strings.Contains(errStr, "Unknown Topic") || strings.Contains(errStr, "does not exist") || strings.Contains(errStr, "UnknownTopic") || strings.Contains(errStr, "topic or partition") {
-				// Try to create the topic
-				log.Printf("Topic %s not found for stream %s, attempting to create it...", topic, req.StreamId)
-				if createErr := gateway.CreateTopicIfNotExists(s.BrokerURL, topic); createErr != nil {
-					log.Printf("Failed to create topic %s: %v", topic, createErr)
-					statusCode = http.StatusInternalServerError
-					metrics.RecordPublishError(streamID, "topic_creation_failed")
-					http.Error(w, fmt.Sprintf("Topic not found and creation failed: %v", createErr), statusCode)
-					return
-				}
-				// Retry the write
-				err = s.Writer.WriteMessages(r.Context(), kafka.Message{
-					Topic: topic,
-					Key:   []byte(req.Request.RequestId),
-					Value: messageData,
-				})
-				if err != nil {
-					log.Printf("Failed to write to broker after topic creation: %v", err)
-					statusCode = http.StatusInternalServerError
-					metrics.RecordPublishError(streamID, "write_retry_failed")
-					http.Error(w, fmt.Sprintf("Failed to ingest request: %v", err), statusCode)
-					return
-				}
-			} else {
+		// Follow the request's routing. Requests this replica doesn't remember
+		// (another replica, restart, expired) go where routing sends a request
+		// with no matching fields: the router's fallback or the stream itself.
+		destinations, ok := s.RecentRoutes.Get(stream.ID, req.Response.RequestID)
+		if !ok {
+			destinations, err = s.resolveDestinations(ctx, stream, &ingestv1.MirroredRequest{RequestId: req.Response.RequestID})
+			if err != nil {
+				log.Printf("Failed to resolve routes for stream %s: %v", req.StreamID, err)
 				statusCode = http.StatusInternalServerError
-				metrics.RecordPublishError(streamID, "write_failed")
-				http.Error(w, fmt.Sprintf("Failed to ingest request: %v", err), statusCode)
+				writeIngestError(w, newIngestError(apierror.CodeInternal, "Failed to resolve stream routes").withRequestID(requestID))
 				return
 			}
 		}
+		if ierr := s.authorizeDestinations(ctx, authResult, stream, destinations); ierr != nil {
+			statusCode = ierr.status
+			writeIngestError(w, ierr.withRequestID(requestID))
+			return
+		}
+
+		// Mask credentials, as for requests, then serialize the response
+		s.Redactor.Response(req.Response)
+		messageData, err := json.Marshal(req.Response)
+		if err != nil {
+			statusCode = http.StatusInternalServerError
+			writeIngestError(w, newIngestError(apierror.CodeInternal, "Failed to serialize response").withRequestID(requestID))
+			return
+		}
+
+		rec := &ingestRecord{
+			auth:            authResult,
+			receivedAt:      start,
+			key:             []byte(req.Response.RequestID),
+			payload:         messageData,
+			payloadType:     metadata.SchemaVersionMirroredResponse,
+			bodyEncoding:    req.Response.Encoding(),
+			bodyContentType: req.Response.ContentType,
+		}
+		result, ierr := s.publishRecord(ctx, rec, req.StreamID, destinations, mode)
+		if ierr != nil {
+			statusCode = ierr.status
+			writeIngestError(w, ierr.withRequestID(requestID))
+			return
+		}
+		result.RequestID = req.Response.RequestID
 
 		// Success
-		metrics.RecordMessagePublished(streamID)
-		w.WriteHeader(http.StatusAccepted)
-		_, _ = w.Write([]byte("OK"))
+		writeAccepted(w, r, result)
+	}
+}
+
+// resolveDestinations returns the streams a request should be published to.
+// Streams without routing configured publish to themselves. Router streams
+// publish to whichever destinations their rules select, falling back to the
+// router's own topic when neither a rule nor the fallback stream applies.
+func (s *IngestGatewayServer) resolveDestinations(ctx context.Context, stream *models.Stream, req *ingestv1.MirroredRequest) ([]routing.Destination, error) {
+	self := []routing.Destination{{StreamID: stream.ID, Stream: stream.Name, Topic: stream.Topic}}
+	if s.Routes == nil {
+		return self, nil
+	}
+
+	table, err := s.Routes.Get(ctx, stream.ID)
+	if err != nil {
+		return nil, err
+	}
+	if table == nil {
+		return self, nil
+	}
+
+	if dests := table.Evaluate(req); len(dests) > 0 {
+		return dests, nil
 	}
+	return self, nil
 }
 
+// authorizeDestinations checks that the caller may write to each stream a
+// router stream's rules selected. Write access to the router stream, checked
+// when the caller was authenticated, doesn't extend to the streams it routes to.
+func (s *IngestGatewayServer) authorizeDestinations(ctx context.Context, authResult *plugins.AuthResult, stream *models.Stream, destinations []routing.Destination) *ingestError {
+	for _, dest := range destinations {
+		if dest.StreamID == stream.ID {
+			continue
+		}
+		allowed, err := s.AuthPlugin.CanAccessStream(ctx, authResult, dest.StreamID, "write")
+		if err != nil {
+			log.Printf("Authorization check failed for stream %s routed from %s: %v", dest.Stream, stream.Name, err)
+		}
+		if err != nil || !allowed {
+			metrics.RecordAuthFailure("frkr-ingest-gateway", "routed_stream_denied")
+			return newIngestError(apierror.CodeAuthForbidden, "Not allowed to write to a stream the request is routed to")
+		}
+	}
+	return nil
+}
+
+// streamLimits returns the size limits for a stream: the gateway defaults
+// overridden by the stream's settings. The stream can't raise the request
+// size limit, which has already been enforced while reading the body.
+func (s *IngestGatewayServer) streamLimits(ctx context.Context, stream *models.Stream) (limits.Limits, error) {
+	l := s.Config.Limits
+	if s.Settings == nil {
+		return l, nil
+	}
+	settings, err := s.Settings.Get(ctx, stream.ID)
+	if err != nil {
+		return l, err
+	}
+	return l.Override(limits.Limits{
+		MaxRequestBytes: settings.MaxRequestBytes,
+		MaxHeaderCount:  settings.MaxHeaderCount,
+		MaxHeaderBytes:  settings.MaxHeaderBytes,
+		MaxBodyBytes:    settings.MaxBodyBytes,
+	}), nil
+}
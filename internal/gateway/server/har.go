@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/frkr-io/frkr-common/metrics"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/durability"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/har"
+)
+
+// HARImportResult is the response body of POST /ingest/har
+type HARImportResult struct {
+	Stream    string           `json:"stream"`
+	Entries   int              `json:"entries"`
+	Published int              `json:"published"`
+	Failures  []HARImportError `json:"failures,omitempty"`
+}
+
+// HARImportError describes an entry that could not be published
+type HARImportError struct {
+	Entry   int    `json:"entry"` // Index in log.entries
+	Status  int    `json:"status"`
+	Code    string `json:"code"` // apierror.Code*
+	Message string `json:"message"`
+}
+
+// HARHandler handles POST /ingest/har?stream={stream} requests, publishing
+// every entry of a HAR 1.2 file as a mirrored request (with its recorded
+// response) through the normal ingest pipeline. It responds 202 when every
+// entry was published, 207 when only some were, and with the first failure's
+// status when none were.
+func (s *IngestGatewayServer) HARHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		statusCode := http.StatusAccepted
+
+		defer func() {
+			duration := time.Since(start).Seconds()
+			metrics.RecordIngestRequest(r.Method, "/ingest/har", strconv.Itoa(statusCode), duration)
+		}()
+
+		if r.Method != http.MethodPost {
+			ierr := errMethodNotAllowed()
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+
+		// Check if we're ready
+		if !s.HealthChecker.IsReady() {
+			ierr := errNotReady()
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+
+		streamName := r.URL.Query().Get("stream")
+		if streamName == "" {
+			statusCode = http.StatusBadRequest
+			writeIngestError(w, newIngestError(apierror.CodeInvalidRequest, "Invalid request: missing stream parameter"))
+			return
+		}
+
+		// Authenticate and authorize
+		ctx := r.Context()
+		authResult, err := s.authenticate(ctx, r, streamName)
+		if err != nil {
+			ierr := authError(err)
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+
+		// Parse the HAR file
+		body, ierr := s.readBody(w, r)
+		if ierr != nil {
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+		harLog, err := har.Parse(bytes.NewReader(body))
+		if err != nil {
+			statusCode = http.StatusBadRequest
+			writeIngestError(w, errInvalid(err))
+			return
+		}
+
+		// The stream must exist before publishing anything
+		if _, ierr := s.lookupStream(ctx, streamName); ierr != nil {
+			statusCode = ierr.status
+			writeIngestError(w, ierr)
+			return
+		}
+
+		result := HARImportResult{Stream: streamName, Entries: len(harLog.Entries)}
+		for i := range harLog.Entries {
+			req, err := harLog.Entries[i].MirroredRequest()
+			if err != nil {
+				result.Failures = append(result.Failures, HARImportError{Entry: i, Status: http.StatusBadRequest, Code: apierror.CodeInvalidRequest, Message: err.Error()})
+				continue
+			}
+			// Entries are checked against the stream's header and body limits;
+			// the file as a whole was bounded while reading it
+			if _, ierr := s.ingest(ctx, authResult, streamName, req, 0, r.Header.Get(durability.Header), start); ierr != nil {
+				result.Failures = append(result.Failures, HARImportError{Entry: i, Status: ierr.status, Code: ierr.code, Message: ierr.message})
+				continue
+			}
+			result.Published++
+		}
+
+		switch {
+		case len(result.Failures) == 0:
+			statusCode = http.StatusAccepted
+		case result.Published > 0:
+			statusCode = http.StatusMultiStatus
+		default:
+			statusCode = result.Failures[0].Status
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		_ = json.NewEncoder(w).Encode(result)
+	}
+}
@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// harEntry returns a HAR entry for a POST request with body
+func harEntry(url, body string) string {
+	return `{
+		"startedDateTime": "2026-01-02T03:04:05Z",
+		"time": 12,
+		"request": {"method": "POST", "url": "` + url + `", "headers": [], "queryString": [], "postData": {"mimeType": "text/plain", "text": "` + body + `"}},
+		"response": {"status": 201, "headers": [], "content": {"size": 0, "mimeType": ""}}
+	}`
+}
+
+// harFile returns a HAR file with entries
+func harFile(entries ...string) string {
+	return `{"log": {"version": "1.2", "creator": {"name": "test", "version": "1"}, "entries": [` + strings.Join(entries, ",") + `]}}`
+}
+
+func TestHARHandler(t *testing.T) {
+	s, w := newTestServer(t)
+	s.Config.Limits.MaxBodyBytes = 16
+
+	body := harFile(
+		harEntry("https://shop.example.com/cart?item=1", "qty=1"),
+		harEntry("https://shop.example.com/upload", strings.Repeat("a", 17)),
+		harEntry("https://shop.example.com/checkout", "pay"),
+	)
+	r := httptest.NewRequest(http.MethodPost, "/ingest/har?stream=orders", strings.NewReader(body))
+	r.Header.Set("Authorization", validToken)
+	rec := httptest.NewRecorder()
+	s.HARHandler()(rec, r)
+
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	var result HARImportResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, "orders", result.Stream)
+	assert.Equal(t, 3, result.Entries)
+	assert.Equal(t, 2, result.Published)
+	require.Len(t, result.Failures, 1)
+	assert.Equal(t, 1, result.Failures[0].Entry)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, result.Failures[0].Status)
+	assert.Equal(t, apierror.CodePayloadTooLarge, result.Failures[0].Code)
+
+	messages := w.written()
+	require.Len(t, messages, 2)
+	first := decodeRecord(t, messages[0])
+	assert.Equal(t, "POST", first.Method)
+	assert.Equal(t, "/cart", first.Path)
+	assert.Equal(t, "qty=1", first.Body)
+	require.NotNil(t, first.Response)
+	assert.Equal(t, 201, first.Response.Status)
+	assert.Equal(t, "/checkout", decodeRecord(t, messages[1]).Path)
+}
+
+func TestHARHandler_AllPublished(t *testing.T) {
+	s, w := newTestServer(t)
+
+	r := httptest.NewRequest(http.MethodPost, "/ingest/har?stream=orders", strings.NewReader(harFile(harEntry("https://shop.example.com/cart", "qty=1"))))
+	r.Header.Set("Authorization", validToken)
+	rec := httptest.NewRecorder()
+	s.HARHandler()(rec, r)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	var result HARImportResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, HARImportResult{Stream: "orders", Entries: 1, Published: 1}, result)
+	assert.Len(t, w.written(), 1)
+}
+
+func TestHARHandler_Errors(t *testing.T) {
+	valid := harFile(harEntry("https://shop.example.com/cart", "qty=1"))
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		auth   string
+		status int
+		code   string
+	}{
+		{name: "method", method: http.MethodGet, url: "/ingest/har?stream=orders", auth: validToken, status: http.StatusMethodNotAllowed, code: apierror.CodeMethodNotAllowed},
+		{name: "missing stream", url: "/ingest/har", body: valid, auth: validToken, status: http.StatusBadRequest, code: apierror.CodeInvalidRequest},
+		{name: "missing credentials", url: "/ingest/har?stream=orders", body: valid, status: http.StatusUnauthorized, code: apierror.CodeAuthMissing},
+		{name: "forbidden stream", url: "/ingest/har?stream=forbidden", body: valid, auth: validToken, status: http.StatusForbidden, code: apierror.CodeAuthForbidden},
+		{name: "malformed har", url: "/ingest/har?stream=orders", body: `{"log":`, auth: validToken, status: http.StatusBadRequest, code: apierror.CodeInvalidRequest},
+		{name: "unknown stream", url: "/ingest/har?stream=missing", body: valid, auth: validToken, status: http.StatusNotFound, code: apierror.CodeStreamNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, w := newTestServer(t)
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+
+			r := httptest.NewRequest(method, tt.url, strings.NewReader(tt.body))
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			s.HARHandler()(rec, r)
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.code, decodeError(t, rec).Code)
+			assert.Empty(t, w.written())
+		})
+	}
+}
+
+// Entries are validated like POST /ingest requests
+func TestHARHandler_InvalidEntry(t *testing.T) {
+	s, w := newTestServer(t)
+
+	invalid := strings.Replace(harEntry("https://shop.example.com/cart", "qty=1"), `"method": "POST"`, `"method": "POST /cart"`, 1)
+	r := httptest.NewRequest(http.MethodPost, "/ingest/har?stream=orders", strings.NewReader(harFile(invalid, harEntry("https://shop.example.com/checkout", "pay"))))
+	r.Header.Set("Authorization", validToken)
+	rec := httptest.NewRecorder()
+	s.HARHandler()(rec, r)
+
+	assert.Equal(t, http.StatusMultiStatus, rec.Code)
+	var result HARImportResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, 1, result.Published)
+	require.Len(t, result.Failures, 1)
+	assert.Equal(t, 0, result.Failures[0].Entry)
+	assert.Equal(t, apierror.CodeInvalidRequest, result.Failures[0].Code)
+	assert.Len(t, w.written(), 1)
+}
+
+// When no entry is published the response has the first failure's status
+func TestHARHandler_NonePublished(t *testing.T) {
+	s, w := newTestServer(t)
+	s.Config.Limits.MaxBodyBytes = 4
+
+	r := httptest.NewRequest(http.MethodPost, "/ingest/har?stream=orders", strings.NewReader(harFile(harEntry("https://shop.example.com/cart", "qty=1"))))
+	r.Header.Set("Authorization", validToken)
+	rec := httptest.NewRecorder()
+	s.HARHandler()(rec, r)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	var result HARImportResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, 0, result.Published)
+	require.Len(t, result.Failures, 1)
+	assert.Equal(t, apierror.CodePayloadTooLarge, result.Failures[0].Code)
+	assert.Empty(t, w.written())
+}
@@ -0,0 +1,293 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/frkr-io/frkr-common/metrics"
+	"github.com/frkr-io/frkr-common/models"
+	"github.com/frkr-io/frkr-common/plugins"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/durability"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/limits"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/metadata"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/routing"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/store"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/validation"
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+)
+
+// ingestError is a failed ingest and the HTTP response it maps to
+type ingestError struct {
+	status    int
+	code      string // apierror.Code*
+	message   string
+	requestID string                // Set once the failed request's ID is known
+	violation *limits.Violation     // Set for 413 Request Entity Too Large
+	fields    []apierror.FieldError // Invalid fields, for invalid_request
+}
+
+func (e *ingestError) Error() string {
+	return e.message
+}
+
+// tooLarge returns the error for a request that exceeded a size limit,
+// counting the rejection against the stream. Oversized requests are not
+// dead-lettered: their bodies would be as large on the dead-letter topic.
+func tooLarge(streamName string, v *limits.Violation) *ingestError {
+	recordLimitRejection(streamName, v.Limit)
+	ierr := newIngestError(apierror.CodePayloadTooLarge, fmt.Sprintf("Request exceeds the %s limit of %d", v.Limit, v.Max))
+	ierr.violation = v
+	return ierr
+}
+
+// readBody reads a request body, refusing to read more than the request size
+// limit. The stream is not known yet, so rejections are counted as unknown.
+func (s *IngestGatewayServer) readBody(w http.ResponseWriter, r *http.Request) ([]byte, *ingestError) {
+	if max := s.Config.Limits.MaxRequestBytes; max > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, tooLarge(unknownStream, &limits.Violation{Limit: limits.LimitRequestBytes, Max: maxErr.Limit})
+		}
+		return nil, errInvalid(err)
+	}
+	return body, nil
+}
+
+// ingest validates a mirrored request and publishes it to its stream's
+// destinations; every ingestion path goes through it. requestBytes is the
+// size of the raw ingest call, checked against the stream's request size
+// limit. requestedDurability is the caller's X-Frkr-Durability, empty for the
+// stream's default.
+func (s *IngestGatewayServer) ingest(ctx context.Context, authResult *plugins.AuthResult, streamName string, req *capture.Request, requestBytes int64, requestedDurability string, receivedAt time.Time) (*IngestResult, *ingestError) {
+	if err := validation.Validate(&capture.IngestRequest{StreamID: streamName, Request: req}, receivedAt, s.Config.TimestampBounds); err != nil {
+		s.deadLetterInvalid(ctx, authResult, streamName, req, err)
+		return nil, errInvalid(err)
+	}
+
+	req.FillMissing(receivedAt)
+	s.checkClockSkew(streamName, authResult, req, receivedAt)
+
+	// Look up the stream
+	stream, ierr := s.lookupStream(ctx, streamName)
+	if ierr != nil {
+		return nil, ierr
+	}
+
+	mode, ierr := s.streamDurability(ctx, stream, requestedDurability)
+	if ierr != nil {
+		return nil, ierr
+	}
+
+	// Enforce the stream's size limits
+	streamLimits, err := s.streamLimits(ctx, stream)
+	if err != nil {
+		log.Printf("Failed to get settings for stream %s: %v", streamName, err)
+		return nil, newIngestError(apierror.CodeInternal, "Failed to look up stream")
+	}
+	v := streamLimits.Check(requestBytes, req.MirroredRequest)
+	if v == nil && req.Response != nil {
+		v = streamLimits.CheckMessage(req.Response.Headers, req.Response.Body)
+	}
+	if v != nil {
+		return nil, tooLarge(stream.Name, v)
+	}
+
+	// Resolve destination streams (content-based routing for router streams)
+	destinations, err := s.resolveDestinations(ctx, stream, req.MirroredRequest)
+	if err != nil {
+		log.Printf("Failed to resolve routes for stream %s: %v", streamName, err)
+		return nil, newIngestError(apierror.CodeInternal, "Failed to resolve stream routes")
+	}
+	if ierr := s.authorizeDestinations(ctx, authResult, stream, destinations); ierr != nil {
+		return nil, ierr
+	}
+
+	// Offload a large body to the blob store (claim check)
+	claimCheck, ierr := s.offloadBody(ctx, stream, req)
+	if ierr != nil {
+		return nil, ierr
+	}
+
+	// Mask credentials before anything is published. Routing has already
+	// seen the original headers; partition keys see the masked ones.
+	s.Redactor.Request(req)
+
+	// Serialize request
+	messageData, err := json.Marshal(req)
+	if err != nil {
+		s.discardClaimCheck(ctx, claimCheck)
+		return nil, newIngestError(apierror.CodeInternal, "Failed to serialize request")
+	}
+
+	rec := &ingestRecord{
+		auth:            authResult,
+		receivedAt:      receivedAt,
+		key:             []byte(req.RequestId),
+		request:         req.MirroredRequest,
+		payload:         messageData,
+		payloadType:     metadata.SchemaVersionMirroredRequest,
+		bodyEncoding:    req.Encoding(),
+		bodyContentType: req.ContentType,
+		claimCheck:      claimCheck,
+		cloudEvent:      req.CloudEvent,
+		serverGenerated: req.ServerGenerated,
+		clockSkew:       req.ClockSkew,
+		originalTime:    req.OriginalTimestampNs,
+	}
+
+	// Publish one request per partition key at a time in ordered mode
+	turn, ierr := s.awaitTurn(ctx, stream, req)
+	if ierr != nil {
+		s.discardClaimCheck(ctx, claimCheck)
+		return nil, ierr
+	}
+	if turn != nil {
+		defer turn.Release()
+		rec.sequence = req.Sequence
+		rec.order = turn.Status
+	}
+
+	result, ierr := s.publishRecord(ctx, rec, streamName, destinations, mode)
+	if ierr != nil {
+		return nil, ierr
+	}
+	result.RequestID = req.RequestId
+	result.ServerGenerated = req.ServerGenerated
+
+	// Remember where the request went so a late response can follow it
+	s.RecentRoutes.Add(stream.ID, req.RequestId, destinations)
+	return result, nil
+}
+
+// lookupStream looks up a stream by name
+func (s *IngestGatewayServer) lookupStream(ctx context.Context, name string) (*models.Stream, *ingestError) {
+	stream, err := s.Streams.Get(ctx, name)
+	if err != nil {
+		log.Printf("Failed to get stream: %v", err)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, newIngestError(apierror.CodeStreamNotFound, "Stream not found")
+		}
+		return nil, newIngestError(apierror.CodeInternal, "Failed to look up stream")
+	}
+	return stream, nil
+}
+
+// publishRecord builds and publishes a record to its destinations with a
+// durability mode, dead-lettering what could not be published. In async mode
+// it returns once the messages are queued. The result lists where the
+// records were stored, except in async mode. The record's claim-check blob is
+// deleted when no message referencing it was published or dead-lettered.
+func (s *IngestGatewayServer) publishRecord(ctx context.Context, rec *ingestRecord, streamID string, destinations []routing.Destination, mode string) (*IngestResult, *ingestError) {
+	result := &IngestResult{Stream: streamID, ReceivedAt: rec.receivedAt, Durability: mode}
+	if mode == durability.Async {
+		result.TrackingID = uuid.NewString()
+		rec.trackingID = result.TrackingID
+	}
+
+	messages := make([]kafka.Message, 0, len(destinations))
+	for _, dest := range destinations {
+		msg, err := s.buildMessage(ctx, rec, dest)
+		if err != nil {
+			log.Printf("Failed to build record for stream %s: %v", dest.Stream, err)
+			s.discardClaimCheck(ctx, rec.claimCheck)
+			return nil, newIngestError(apierror.CodeInternal, "Failed to serialize request")
+		}
+		messages = append(messages, msg)
+	}
+
+	if mode == durability.Async {
+		for i := range messages {
+			messages[i].WriterData = &asyncMessage{auth: rec.auth, streamID: streamID, dest: destinations[i]}
+		}
+		// Queueing only fails once the writer is closed
+		if err := s.writer(mode).WriteMessages(ctx, messages...); err != nil {
+			metrics.RecordPublishError(streamID, "queue_failed")
+			if !s.deadLetterMessages(ctx, rec.auth, streamID, messages, err) {
+				s.discardClaimCheck(ctx, rec.claimCheck)
+			}
+			return nil, newIngestError(apierror.CodeBrokerUnavailable, "Failed to queue request")
+		}
+		for _, dest := range destinations {
+			recordAccepted(dest.Stream, mode)
+		}
+		return result, nil
+	}
+
+	placements := make([]*placement, len(messages))
+	for i := range messages {
+		placements[i] = &placement{}
+		messages[i].WriterData = placements[i]
+	}
+
+	// Write to broker
+	if perr := s.publish(ctx, s.writer(mode), streamID, destinations, messages); perr != nil {
+		metrics.RecordPublishError(streamID, perr.errType)
+		deadLettered := s.deadLetterMessages(ctx, rec.auth, streamID, perr.failed, perr.err)
+		if !deadLettered && len(perr.failed) == len(messages) {
+			s.discardClaimCheck(ctx, rec.claimCheck)
+		}
+		return nil, newIngestError(perr.code, perr.message)
+	}
+
+	for i, dest := range destinations {
+		recordAccepted(dest.Stream, mode)
+		recordConfirmed(dest.Stream, mode)
+		metrics.RecordMessagePublished(dest.Stream)
+
+		// Writers without a completion callback (no durability writers
+		// configured) don't report placements
+		if p := placements[i]; p.written {
+			result.Records = append(result.Records, RecordResult{
+				Stream:    dest.Stream,
+				Topic:     dest.Topic,
+				Partition: p.partition,
+				Offset:    p.offset,
+			})
+		}
+	}
+	return result, nil
+}
+
+// streamDurability returns the durability mode for a request to a stream:
+// the requested mode if the stream's policy allows it, otherwise the
+// stream's default. Invalid stream settings fall back to the gateway policy.
+func (s *IngestGatewayServer) streamDurability(ctx context.Context, stream *models.Stream, requested string) (string, *ingestError) {
+	policy := s.Config.Durability
+	if s.Settings != nil {
+		settings, err := s.Settings.Get(ctx, stream.ID)
+		if err != nil {
+			log.Printf("Failed to get settings for stream %s: %v", stream.Name, err)
+			return "", newIngestError(apierror.CodeInternal, "Failed to look up stream")
+		}
+		override := policy.Override(durability.Policy{
+			Default: strings.ToLower(settings.Durability),
+			Min:     strings.ToLower(settings.DurabilityMin),
+			Max:     strings.ToLower(settings.DurabilityMax),
+		})
+		if err := override.Validate(); err != nil {
+			log.Printf("Ignoring durability settings of stream %s: %v", stream.Name, err)
+		} else {
+			policy = override
+		}
+	}
+
+	mode, err := policy.Resolve(requested)
+	if err != nil {
+		return "", newIngestError(apierror.CodeDurabilityNotAllowed, fmt.Sprintf("Invalid request: %v", err))
+	}
+	return mode, nil
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/signing"
+)
+
+// KeysHandler handles GET /.well-known/frkr-keys requests, serving the public
+// keys records are signed with as a JSON Web Key Set. The set is empty when
+// signing is disabled.
+func (s *IngestGatewayServer) KeysHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeIngestError(w, errMethodNotAllowed())
+			return
+		}
+
+		keys := s.SigningKeys
+		if keys == nil {
+			keys = &signing.KeySet{Keys: []signing.JWK{}}
+		}
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		_ = json.NewEncoder(w).Encode(keys)
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/frkr-io/frkr-common/metrics"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/clockskew"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Ingest gateway metrics not covered by the shared frkr-common ingest metrics
+var (
+	// deadLettersTotal counts messages written to the dead-letter topic
+	deadLettersTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "frkr",
+		Subsystem: "ingest",
+		Name:      "dead_letters_total",
+		Help:      "Total number of messages written to the dead-letter topic",
+	}, []string{"stream_id", "reason"})
+
+	// publishRetriesTotal counts publish attempts retried after a retriable broker error
+	publishRetriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "frkr",
+		Subsystem: "ingest",
+		Name:      "publish_retries_total",
+		Help:      "Total number of publish retries by broker error class",
+	}, []string{"stream_id", "error_class"})
+
+	// limitRejectionsTotal counts requests rejected for exceeding a size limit
+	limitRejectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "frkr",
+		Subsystem: "ingest",
+		Name:      "limit_rejections_total",
+		Help:      "Total number of requests rejected for exceeding a size limit",
+	}, []string{"stream_id", "limit"})
+
+	// mirrorDroppedTotal counts mirrored requests accepted but not published
+	mirrorDroppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "frkr",
+		Subsystem: "ingest",
+		Name:      "mirror_dropped_total",
+		Help:      "Total number of mirrored requests dropped instead of published",
+	}, []string{"stream_id", "reason"})
+
+	// messagesAcceptedTotal counts messages the gateway accepted, by durability
+	// mode. Async messages are accepted before the broker confirms them.
+	messagesAcceptedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "frkr",
+		Subsystem: "ingest",
+		Name:      "messages_accepted_total",
+		Help:      "Total number of messages accepted by durability mode",
+	}, []string{"stream_id", "durability"})
+
+	// messagesConfirmedTotal counts messages the broker acknowledged, by
+	// durability mode
+	messagesConfirmedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "frkr",
+		Subsystem: "ingest",
+		Name:      "messages_confirmed_total",
+		Help:      "Total number of messages confirmed by the broker by durability mode",
+	}, []string{"stream_id", "durability"})
+
+	// orderedRecordsTotal counts records published in ordered mode, by order
+	// status
+	orderedRecordsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "frkr",
+		Subsystem: "ingest",
+		Name:      "ordered_records_total",
+		Help:      "Total number of records published in ordered mode by order status",
+	}, []string{"stream_id", "order"})
+
+	// clockSkewSeconds observes how far client timestamps are from the
+	// gateway's receive time, by client and direction
+	clockSkewSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "frkr",
+		Subsystem: "ingest",
+		Name:      "clock_skew_seconds",
+		Help:      "Absolute skew between client request timestamps and the gateway receive time",
+		Buckets:   []float64{0.01, 0.1, 0.5, 1, 5, 30, 60, 300, 1800, 3600, 86400},
+	}, []string{"stream_id", "client", "direction"})
+)
+
+var registerOnce sync.Once
+
+// registerMetrics registers the gateway-local metrics with the frkr registry
+func registerMetrics() {
+	registerOnce.Do(func() {
+		metrics.MustRegister(
+			deadLettersTotal,
+			publishRetriesTotal,
+			limitRejectionsTotal,
+			mirrorDroppedTotal,
+			messagesAcceptedTotal,
+			messagesConfirmedTotal,
+			orderedRecordsTotal,
+			clockSkewSeconds,
+		)
+	})
+}
+
+// recordDeadLetter records a message written to the dead-letter topic
+func recordDeadLetter(streamID, reason string) {
+	deadLettersTotal.WithLabelValues(streamID, reason).Inc()
+}
+
+// recordPublishRetry records a publish retried after a retriable error
+func recordPublishRetry(streamID, errorClass string) {
+	publishRetriesTotal.WithLabelValues(streamID, errorClass).Inc()
+}
+
+// unknownStream labels rejections of requests whose stream is not known,
+// because the body was never decoded
+const unknownStream = "unknown"
+
+// recordLimitRejection records a request rejected for exceeding a size limit.
+// streamName is unknownStream when the request was rejected before it could
+// be decoded.
+func recordLimitRejection(streamName, limit string) {
+	limitRejectionsTotal.WithLabelValues(streamName, limit).Inc()
+}
+
+// recordMirrorDrop records a mirrored request that was dropped
+func recordMirrorDrop(stream, reason string) {
+	mirrorDroppedTotal.WithLabelValues(stream, reason).Inc()
+}
+
+// recordAccepted records a message accepted with a durability mode
+func recordAccepted(stream, mode string) {
+	messagesAcceptedTotal.WithLabelValues(stream, mode).Inc()
+}
+
+// recordConfirmed records a message the broker acknowledged
+func recordConfirmed(stream, mode string) {
+	messagesConfirmedTotal.WithLabelValues(stream, mode).Inc()
+}
+
+// recordOrder records the order status of a record published in ordered mode
+func recordOrder(stream, order string) {
+	orderedRecordsTotal.WithLabelValues(stream, order).Inc()
+}
+
+// recordClockSkew records the skew of a client's request timestamp
+func recordClockSkew(stream, client string, skew time.Duration) {
+	clockSkewSeconds.WithLabelValues(stream, client, clockskew.Direction(skew)).Observe(clockskew.Abs(skew).Seconds())
+}
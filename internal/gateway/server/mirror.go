@@ -0,0 +1,165 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/frkr-io/frkr-common/metrics"
+	"github.com/frkr-io/frkr-common/plugins"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/mirror"
+)
+
+// Reasons a mirrored request is dropped
+const (
+	mirrorDropNotReady    = "not_ready"
+	mirrorDropReadFailed  = "read_failed"
+	mirrorDropTooLarge    = "too_large"
+	mirrorDropAuthFailed  = "auth_failed"
+	mirrorDropOverloaded  = "overloaded"
+	mirrorDropNotFound    = "stream_not_found"
+	mirrorDropFailed      = "ingest_failed"
+	mirrorDropInvalid     = "invalid"
+	mirrorDropUnsupported = "unsupported"
+)
+
+// MirrorHandler handles raw HTTP traffic mirrored by a proxy, converting each
+// request into a mirrored request. With stream empty, requests are sent to
+// /mirror/{stream}/...; otherwise every request is captured for stream.
+//
+// The mirror source must never be slowed down, so once the path is valid the
+// handler always replies 202 as soon as the body is read and publishes in the
+// background. Requests that can't be published are dropped and counted.
+func (s *IngestGatewayServer) MirrorHandler(stream string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		streamName, path := stream, r.URL.Path
+		if streamName == "" {
+			var ok bool
+			if streamName, path, ok = mirror.SplitPath(r.URL.Path); !ok {
+				http.NotFound(w, r)
+				return
+			}
+		}
+
+		defer func() {
+			duration := time.Since(start).Seconds()
+			metrics.RecordIngestRequest(r.Method, "/mirror", strconv.Itoa(http.StatusAccepted), duration)
+		}()
+
+		req, authReq, reason := s.captureMirrored(r, streamName, path, start)
+		w.WriteHeader(http.StatusAccepted)
+		if reason != "" {
+			recordMirrorDrop(streamName, reason)
+			return
+		}
+
+		s.publishInBackground(streamName, func(ctx context.Context) string {
+			return s.publishMirrored(ctx, authReq.WithContext(ctx), streamName, req, start)
+		})
+	}
+}
+
+// publishInBackground runs publish, which returns a drop reason or "", in the
+// background within a mirror slot and the mirror timeout. Without a free slot
+// the request is dropped as overloaded.
+func (s *IngestGatewayServer) publishInBackground(streamName string, publish func(ctx context.Context) string) {
+	select {
+	case s.mirrorSlots <- struct{}{}:
+	default:
+		recordMirrorDrop(streamName, mirrorDropOverloaded)
+		return
+	}
+	s.mirrorWG.Add(1)
+	go func() {
+		defer s.mirrorWG.Done()
+		defer func() { <-s.mirrorSlots }()
+		ctx, cancel := context.WithTimeout(context.Background(), s.Config.MirrorTimeout)
+		defer cancel()
+		if reason := publish(ctx); reason != "" {
+			recordMirrorDrop(streamName, reason)
+		}
+	}()
+}
+
+// WaitMirrored waits until the mirrored requests being published in the
+// background are done, or ctx is done. Call it once the mirror and Envoy
+// receivers have stopped accepting traffic, before Close.
+func (s *IngestGatewayServer) WaitMirrored(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.mirrorWG.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// captureMirrored reads and converts a mirrored request. It returns the drop
+// reason if the request can't be published.
+func (s *IngestGatewayServer) captureMirrored(r *http.Request, streamName, path string, receivedAt time.Time) (*capture.Request, *http.Request, string) {
+	if !s.HealthChecker.IsReady() {
+		return nil, nil, mirrorDropNotReady
+	}
+
+	body := io.Reader(r.Body)
+	max := s.Config.Limits.MaxRequestBytes
+	if max > 0 {
+		body = io.LimitReader(r.Body, max+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, nil, mirrorDropReadFailed
+	}
+	if max > 0 && int64(len(data)) > max {
+		return nil, nil, mirrorDropTooLarge
+	}
+
+	authReq, err := mirror.AuthRequest(r)
+	if err != nil {
+		metrics.RecordAuthFailure("frkr-ingest-gateway", "mirror_missing_credentials")
+		return nil, nil, mirrorDropAuthFailed
+	}
+	return mirror.Capture(r, path, data, receivedAt), authReq, ""
+}
+
+// publishMirrored authenticates and publishes a captured mirrored request. It
+// returns the drop reason if it could not be published.
+func (s *IngestGatewayServer) publishMirrored(ctx context.Context, authReq *http.Request, streamName string, req *capture.Request, receivedAt time.Time) string {
+	authResult, err := s.authenticate(ctx, authReq, streamName)
+	if err != nil {
+		log.Printf("Mirror authentication failed for stream %s: %v", streamName, err)
+		metrics.RecordAuthFailure("frkr-ingest-gateway", "auth_failed")
+		return mirrorDropAuthFailed
+	}
+	return s.ingestMirrored(ctx, authResult, streamName, req, receivedAt)
+}
+
+// ingestMirrored publishes a mirrored request for an authenticated source. It
+// returns the drop reason if it could not be published.
+func (s *IngestGatewayServer) ingestMirrored(ctx context.Context, authResult *plugins.AuthResult, streamName string, req *capture.Request, receivedAt time.Time) string {
+	if _, ierr := s.ingest(ctx, authResult, streamName, req, int64(len(req.Body)), "", receivedAt); ierr != nil {
+		log.Printf("Failed to publish mirrored request for stream %s: %s", streamName, ierr.message)
+		switch ierr.code {
+		case apierror.CodeStreamNotFound:
+			return mirrorDropNotFound
+		case apierror.CodePayloadTooLarge:
+			return mirrorDropTooLarge
+		case apierror.CodeInvalidRequest:
+			return mirrorDropInvalid
+		default:
+			return mirrorDropFailed
+		}
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/metadata"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/mirror"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/redact"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorHandler(t *testing.T) {
+	s, w := newTestServer(t)
+
+	r := httptest.NewRequest(http.MethodPut, "/mirror/orders/orders/1?expand=items", strings.NewReader(`{"qty":2}`))
+	r.Header.Set(mirror.HeaderAuthorization, validToken)
+	r.Header.Set("Authorization", "Bearer app-token")
+	r.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	s.MirrorHandler("")(rec, r)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	// Mirrored requests are published in the background
+	require.Eventually(t, func() bool { return len(w.written()) == 1 }, time.Second, 5*time.Millisecond)
+	msg := w.written()[0]
+	assert.Equal(t, "frkr.orders", msg.Topic)
+	assert.Equal(t, testTenantID, header(msg, metadata.HeaderTenantID))
+	assert.Equal(t, capture.GeneratedRequestID+","+capture.GeneratedTimestamp, header(msg, metadata.HeaderServerGenerated))
+	req := decodeRecord(t, msg)
+	assert.Equal(t, "PUT", req.Method)
+	assert.Equal(t, "/orders/1", req.Path)
+	assert.Equal(t, map[string]string{"expand": "items"}, req.Query)
+	assert.Equal(t, `{"qty":2}`, req.Body)
+	assert.Equal(t, redact.Mask, req.Headers["authorization"])
+	assert.NotContains(t, req.Headers, "x-frkr-authorization")
+	assert.Equal(t, string(msg.Key), req.RequestId)
+}
+
+func TestMirrorHandler_FixedStream(t *testing.T) {
+	s, w := newTestServer(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/users", nil)
+	r.Header.Set(mirror.HeaderAuthorization, validToken)
+	rec := httptest.NewRecorder()
+	s.MirrorHandler("orders")(rec, r)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	require.Eventually(t, func() bool { return len(w.written()) == 1 }, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "/users", decodeRecord(t, w.written()[0]).Path)
+}
+
+// blockingWriter holds writes until release is closed
+type blockingWriter struct {
+	messageWriter
+	release chan struct{}
+}
+
+func (w blockingWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	<-w.release
+	return w.messageWriter.WriteMessages(ctx, msgs...)
+}
+
+// Shutdown waits for mirrored requests already accepted to be published
+func TestWaitMirrored(t *testing.T) {
+	s, w := newTestServer(t)
+	bw := blockingWriter{messageWriter: w, release: make(chan struct{})}
+	for mode := range s.writers {
+		s.writers[mode] = bw
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/mirror/orders/users", nil)
+	r.Header.Set(mirror.HeaderAuthorization, validToken)
+	rec := httptest.NewRecorder()
+	s.MirrorHandler("")(rec, r)
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.WaitMirrored(ctx), context.DeadlineExceeded)
+
+	close(bw.release)
+	require.NoError(t, s.WaitMirrored(context.Background()))
+	assert.Len(t, w.written(), 1)
+}
+
+func TestMirrorHandler_NotFound(t *testing.T) {
+	s, w := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	s.MirrorHandler("")(rec, httptest.NewRequest(http.MethodGet, "/mirror/", nil))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, w.written())
+}
+
+// Mirror sources always get 202; requests that can't be published are
+// dropped and counted by reason
+func TestMirrorHandler_Drops(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		auth   string
+		body   string
+		reason string
+	}{
+		{name: "missing credentials", stream: "orders", reason: mirrorDropAuthFailed},
+		{name: "invalid credentials", stream: "orders", auth: "Bearer wrong", reason: mirrorDropAuthFailed},
+		{name: "forbidden stream", stream: "forbidden", auth: validToken, reason: mirrorDropAuthFailed},
+		{name: "unknown stream", stream: "drop-unknown", auth: validToken, reason: mirrorDropNotFound},
+		{name: "too large", stream: "orders", auth: validToken, body: strings.Repeat("a", 65), reason: mirrorDropTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, w := newTestServer(t)
+			s.Config.Limits.MaxRequestBytes = 64
+			dropped := testutil.ToFloat64(mirrorDroppedTotal.WithLabelValues(tt.stream, tt.reason))
+
+			r := httptest.NewRequest(http.MethodPost, "/mirror/"+tt.stream+"/", strings.NewReader(tt.body))
+			if tt.auth != "" {
+				r.Header.Set(mirror.HeaderAuthorization, tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			s.MirrorHandler("")(rec, r)
+
+			assert.Equal(t, http.StatusAccepted, rec.Code)
+			require.Eventually(t, func() bool {
+				return testutil.ToFloat64(mirrorDroppedTotal.WithLabelValues(tt.stream, tt.reason)) == dropped+1
+			}, time.Second, 5*time.Millisecond)
+			assert.Empty(t, w.written())
+		})
+	}
+}
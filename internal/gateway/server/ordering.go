@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/frkr-io/frkr-common/models"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/ordering"
+)
+
+// orderingIdleTTL is how long the sequence state of an idle partition key is
+// kept in ordered mode
+const orderingIdleTTL = 10 * time.Minute
+
+// awaitTurn waits until a request may be published in its stream's ordering
+// mode. It returns nil when the stream is not ordered; otherwise the turn
+// must be released once the request is published.
+func (s *IngestGatewayServer) awaitTurn(ctx context.Context, stream *models.Stream, req *capture.Request) (*ordering.Turn, *ingestError) {
+	mode, window, err := s.streamOrdering(ctx, stream)
+	if err != nil {
+		log.Printf("Failed to get settings for stream %s: %v", stream.Name, err)
+		return nil, newIngestError(apierror.CodeInternal, "Failed to look up stream")
+	}
+	if mode == ordering.Off {
+		return nil, nil
+	}
+	if mode == ordering.Flag {
+		window = 0
+	}
+
+	strategy, _, err := s.streamPartitioning(ctx, stream.ID, stream.Name)
+	if err != nil {
+		log.Printf("Failed to get settings for stream %s: %v", stream.Name, err)
+		return nil, newIngestError(apierror.CodeInternal, "Failed to look up stream")
+	}
+	key := stream.ID + "/" + string(strategy.Key(req.MirroredRequest))
+	turn, err := s.sequencer.Acquire(ctx, key, req.Sequence, window)
+	if err != nil {
+		return nil, newIngestError(apierror.CodeServiceUnavailable, "Request cancelled while waiting for earlier requests")
+	}
+	recordOrder(stream.Name, turn.Status)
+	return turn, nil
+}
+
+// streamOrdering returns the ordering mode and reorder window of a stream.
+// Invalid stream settings fall back to the gateway defaults.
+func (s *IngestGatewayServer) streamOrdering(ctx context.Context, stream *models.Stream) (string, time.Duration, error) {
+	mode := s.Config.Ordering
+	window := s.Config.OrderingWindow
+	if s.Settings == nil {
+		return mode, window, nil
+	}
+	settings, err := s.Settings.Get(ctx, stream.ID)
+	if err != nil {
+		return mode, window, err
+	}
+	if settings.Ordering != "" {
+		if parsed, err := ordering.Parse(settings.Ordering); err != nil {
+			log.Printf("Ignoring ordering mode of stream %s: %v", stream.Name, err)
+		} else {
+			mode = parsed
+		}
+	}
+	if settings.OrderingWindowMs > 0 {
+		window = time.Duration(settings.OrderingWindowMs) * time.Millisecond
+	}
+	return mode, window, nil
+}
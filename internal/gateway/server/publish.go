@@ -0,0 +1,337 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/frkr-io/frkr-common/metrics"
+	"github.com/frkr-io/frkr-common/plugins"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/broker"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/config"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/deadletter"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/durability"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/partition"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/routing"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/store"
+	"github.com/segmentio/kafka-go"
+)
+
+// publishError describes a failed publish
+type publishError struct {
+	code    string          // apierror.Code* to respond with
+	errType string          // Metric label for RecordPublishError
+	message string          // Client-facing message, without broker details
+	failed  []kafka.Message // Messages that were not written
+	err     error
+}
+
+// publish writes messages to the broker. Errors are classified by their Kafka
+// error code: retriable classes are retried with backoff according to
+// the configured retry policy, and missing topics are created once before retrying. Only
+// messages the broker reported as failed are retried.
+func (s *IngestGatewayServer) publish(ctx context.Context, w messageWriter, streamID string, destinations []routing.Destination, messages []kafka.Message) *publishError {
+	pending := messages
+	topicsCreated := false
+
+	for attempt := 0; ; attempt++ {
+		err := w.WriteMessages(ctx, pending...)
+		if err == nil {
+			return nil
+		}
+		pending = failedMessages(pending, err)
+		class := broker.Classify(err)
+		log.Printf("Failed to write to broker (attempt %d, %s): %v", attempt+1, class.Name, err)
+
+		if class == broker.ClassUnknownTopic && !topicsCreated {
+			if !s.Config.AutoCreateTopics {
+				return &publishError{code: apierror.CodeTopicMissing, errType: "topic_missing", message: "Stream topic does not exist and topic auto-creation is disabled", failed: pending, err: err}
+			}
+			// Try to create the topics
+			for _, dest := range destinations {
+				if !containsTopic(pending, dest.Topic) {
+					continue
+				}
+				log.Printf("Topic %s not found for stream %s, attempting to create it...", dest.Topic, dest.Stream)
+				if createErr := s.createTopic(ctx, dest); createErr != nil {
+					log.Printf("Failed to create topic %s: %v", dest.Topic, createErr)
+					return &publishError{code: apierror.CodeBrokerUnavailable, errType: "topic_creation_failed", message: "Stream topic does not exist and could not be created", failed: pending, err: createErr}
+				}
+			}
+			topicsCreated = true
+		}
+
+		if !s.Config.Retry.ShouldRetry(class, attempt) {
+			return &publishError{code: apierror.CodeBrokerUnavailable, errType: class.Name, message: "Failed to ingest request: broker unavailable", failed: pending, err: err}
+		}
+		recordPublishRetry(streamID, class.Name)
+		if waitErr := s.Config.Retry.Wait(ctx, attempt); waitErr != nil {
+			return &publishError{code: apierror.CodeBrokerUnavailable, errType: broker.ClassCanceled.Name, message: "Failed to ingest request: request cancelled", failed: pending, err: err}
+		}
+	}
+}
+
+// messageWriter writes messages to the broker. It is implemented by
+// *kafka.Writer.
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// asyncRetryTimeout bounds republishing an async record the broker rejected
+const asyncRetryTimeout = 30 * time.Second
+
+// asyncMessage is the context of a message published in async durability
+// mode, carried in its WriterData until the broker has confirmed it
+type asyncMessage struct {
+	auth     *plugins.AuthResult
+	streamID string
+	dest     routing.Destination
+}
+
+// configureWriters creates a writer per durability mode, on the brokers of
+// the shared Writer, balancing each stream's records with its balancer
+func (s *IngestGatewayServer) configureWriters(cfg *config.IngestConfig) {
+	_ = s.Close()
+	if s.Writer == nil {
+		return
+	}
+	s.balancer = partition.NewTopicBalancer(cfg.PartitionBalancer)
+	s.writers = make(map[string]messageWriter, len(durability.Modes))
+	for _, mode := range durability.Modes {
+		w := broker.NewWriter(s.Writer, durability.RequiredAcks(mode), mode == durability.Async, cfg.AsyncBatchSize, cfg.AsyncBatchTimeout, s.writeCompleted)
+		w.Balancer = s.balancer
+		s.writers[mode] = w
+	}
+}
+
+// writer returns the writer for a durability mode
+func (s *IngestGatewayServer) writer(mode string) messageWriter {
+	if w, ok := s.writers[mode]; ok {
+		return w
+	}
+	return s.Writer
+}
+
+// placement is where the broker stored a synchronously published message,
+// filled in by writeCompleted
+type placement struct {
+	written   bool
+	partition int
+	offset    int64
+}
+
+// writeCompleted is called by the durability writers once the broker has
+// answered for a batch. Synchronous messages get their placement recorded.
+// Confirmed async messages are counted; rejected ones are republished through
+// the synchronous path, which retries, creates missing topics and
+// dead-letters what still fails.
+func (s *IngestGatewayServer) writeCompleted(messages []kafka.Message, err error) {
+	var writeErrs kafka.WriteErrors
+	perMessage := errors.As(err, &writeErrs) && len(writeErrs) == len(messages)
+
+	var failed []kafka.Message
+	for i, msg := range messages {
+		msgErr := err
+		if perMessage {
+			msgErr = writeErrs[i]
+		}
+		switch data := msg.WriterData.(type) {
+		case *placement:
+			if msgErr == nil {
+				*data = placement{written: true, partition: msg.Partition, offset: msg.Offset}
+			}
+		case *asyncMessage:
+			if msgErr != nil {
+				failed = append(failed, msg)
+				continue
+			}
+			recordConfirmed(data.dest.Stream, durability.Async)
+			metrics.RecordMessagePublished(data.dest.Stream)
+		}
+	}
+	if len(failed) > 0 {
+		log.Printf("Failed to write %d async message(s) to broker: %v", len(failed), err)
+		go s.republishAsync(failed)
+	}
+}
+
+// republishAsync republishes rejected async messages one by one with leader acks
+func (s *IngestGatewayServer) republishAsync(messages []kafka.Message) {
+	for _, msg := range messages {
+		am := msg.WriterData.(*asyncMessage)
+		msg.WriterData = nil
+
+		ctx, cancel := context.WithTimeout(context.Background(), asyncRetryTimeout)
+		perr := s.publish(ctx, s.writer(durability.Leader), am.streamID, []routing.Destination{am.dest}, []kafka.Message{msg})
+		if perr != nil {
+			metrics.RecordPublishError(am.streamID, perr.errType)
+			s.deadLetterMessages(ctx, am.auth, am.streamID, perr.failed, perr.err)
+		} else {
+			recordConfirmed(am.dest.Stream, durability.Async)
+			metrics.RecordMessagePublished(am.dest.Stream)
+		}
+		cancel()
+	}
+}
+
+// createTopic creates a destination stream's topic using the stream's settings:
+// retention.ms is derived from the stream retention, and partitions,
+// replication, cleanup policy and compression fall back to the gateway defaults.
+func (s *IngestGatewayServer) createTopic(ctx context.Context, dest routing.Destination) error {
+	spec := s.Config.TopicDefaults
+	spec.Name = dest.Topic
+
+	stream, err := store.GetStreamByName(ctx, s.DB, dest.Stream)
+	if err != nil {
+		return err
+	}
+	spec.RetentionMs = broker.RetentionMsFromDays(stream.RetentionDays)
+
+	settings, err := store.GetStreamSettings(ctx, s.DB, stream.ID)
+	if err != nil {
+		return err
+	}
+	if settings.TopicPartitions > 0 {
+		spec.Partitions = settings.TopicPartitions
+	}
+	if settings.TopicReplicationFactor > 0 {
+		spec.ReplicationFactor = settings.TopicReplicationFactor
+	}
+	if settings.TopicCleanupPolicy != "" {
+		spec.CleanupPolicy = settings.TopicCleanupPolicy
+	}
+	if settings.TopicCompression != "" {
+		spec.Compression = settings.TopicCompression
+	}
+
+	return broker.CreateTopic(ctx, s.BrokerURL, spec)
+}
+
+// containsTopic reports whether any message is destined for topic
+func containsTopic(messages []kafka.Message, topic string) bool {
+	for _, msg := range messages {
+		if msg.Topic == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// failedMessages returns the messages that failed in a write. When the broker
+// reports per-message errors only those messages are returned.
+func failedMessages(messages []kafka.Message, err error) []kafka.Message {
+	var writeErrs kafka.WriteErrors
+	if !errors.As(err, &writeErrs) || len(writeErrs) != len(messages) {
+		return messages
+	}
+	failed := make([]kafka.Message, 0, writeErrs.Count())
+	for i, e := range writeErrs {
+		if e != nil {
+			failed = append(failed, messages[i])
+		}
+	}
+	return failed
+}
+
+// deadLetter writes a record to the dead-letter topic if one is configured.
+// Failures are logged rather than returned since the caller is already on an
+// error path; it only reports whether the records were written.
+func (s *IngestGatewayServer) deadLetter(ctx context.Context, records ...*deadletter.Record) bool {
+	if s.DeadLetters == nil || len(records) == 0 {
+		return false
+	}
+	// Dead-letter even if the client has gone away
+	ctx = context.WithoutCancel(ctx)
+	if err := s.DeadLetters.Publish(ctx, records...); err != nil {
+		log.Printf("Failed to dead-letter %d message(s) to %s: %v", len(records), s.DeadLetters.Topic(), err)
+		return false
+	}
+	for _, rec := range records {
+		recordDeadLetter(rec.StreamID, rec.Reason)
+	}
+	return true
+}
+
+// deadLetterMessages dead-letters the messages of a failed publish. When the
+// broker reports per-message errors only the failed messages are written. It
+// reports whether any message was dead-lettered.
+func (s *IngestGatewayServer) deadLetterMessages(ctx context.Context, authResult *plugins.AuthResult, streamID string, messages []kafka.Message, publishErr error) bool {
+	var writeErrs kafka.WriteErrors
+	hasWriteErrs := errors.As(publishErr, &writeErrs) && len(writeErrs) == len(messages)
+
+	records := make([]*deadletter.Record, 0, len(messages))
+	for i, msg := range messages {
+		msgErr := publishErr
+		if hasWriteErrs {
+			if writeErrs[i] == nil {
+				continue
+			}
+			msgErr = writeErrs[i]
+		}
+
+		reason := deadletter.ReasonPublishFailed
+		if broker.Classify(msgErr) == broker.ClassMessageTooLarge {
+			reason = deadletter.ReasonMessageTooLarge
+		}
+
+		records = append(records, &deadletter.Record{
+			Reason:   reason,
+			Error:    msgErr.Error(),
+			StreamID: streamID,
+			TenantID: authResult.TenantID,
+			Topic:    msg.Topic,
+			Key:      msg.Key,
+			Value:    msg.Value,
+			Headers:  deadletter.HeadersFromMessage(msg.Headers),
+		})
+	}
+	return s.deadLetter(ctx, records...)
+}
+
+// deadLetterInvalid dead-letters a request that failed validation, with its
+// credentials masked like a published record's
+func (s *IngestGatewayServer) deadLetterInvalid(ctx context.Context, authResult *plugins.AuthResult, streamName string, req *capture.Request, cause error) {
+	if s.DeadLetters == nil {
+		return
+	}
+	if req != nil {
+		s.Redactor.Request(req)
+	}
+	value, err := json.Marshal(&capture.IngestRequest{StreamID: streamName, Request: req})
+	if err != nil {
+		log.Printf("Failed to serialize invalid request for stream %s: %v", streamName, err)
+		return
+	}
+	s.deadLetter(ctx, &deadletter.Record{
+		Reason:   deadletter.ReasonValidationFailed,
+		Error:    cause.Error(),
+		StreamID: streamName,
+		TenantID: authResult.TenantID,
+		Value:    value,
+	})
+}
+
+// deadLetterRejected dead-letters the raw body of a request that failed
+// validation before its stream could be resolved. Only authenticated callers
+// are dead-lettered so anonymous garbage can't fill the topic.
+func (s *IngestGatewayServer) deadLetterRejected(ctx context.Context, r *http.Request, body []byte, cause error) {
+	if s.DeadLetters == nil {
+		return
+	}
+	authResult, err := s.authenticate(ctx, r, "")
+	if err != nil {
+		return
+	}
+	s.deadLetter(ctx, &deadletter.Record{
+		Reason:   deadletter.ReasonValidationFailed,
+		Error:    cause.Error(),
+		TenantID: authResult.TenantID,
+		Value:    body,
+	})
+}
@@ -0,0 +1,284 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/frkr-io/frkr-common/models"
+	"github.com/frkr-io/frkr-common/plugins"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/blobstore"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/encryption"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/envelope"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/limits"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/metadata"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/partition"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/routing"
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+	"github.com/segmentio/kafka-go"
+)
+
+// ingestRecord is a serialized payload being published, with the context
+// needed to build its broker messages
+type ingestRecord struct {
+	auth            *plugins.AuthResult
+	receivedAt      time.Time
+	key             []byte                    // Default key, used when no request is set
+	request         *ingestv1.MirroredRequest // Request keyed by the stream's partition key strategy
+	payload         []byte                    // Serialized request or response
+	payloadType     string                    // metadata.SchemaVersion* of the payload
+	bodyEncoding    string
+	bodyContentType string
+	claimCheck      *blobstore.Reference // Set when the body was offloaded
+	cloudEvent      *capture.CloudEvent  // Set when the request carried a CloudEvent
+	trackingID      string               // Set for async requests
+	sequence        int64                // Client sequence number, in ordered mode
+	order           string               // ordering.* order status, in ordered mode
+	serverGenerated []string             // Request fields the gateway filled in
+	clockSkew       time.Duration        // Set when beyond the clock skew threshold
+	originalTime    int64                // Client timestamp, when corrected
+}
+
+// buildMessage builds the broker message for a record published to dest,
+// applying the destination stream's record format and attaching metadata headers
+func (s *IngestGatewayServer) buildMessage(ctx context.Context, rec *ingestRecord, dest routing.Destination) (kafka.Message, error) {
+	meta := s.recordMetadata(rec, dest)
+
+	format, err := s.recordFormat(ctx, dest)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	key, err := s.recordKey(ctx, rec, dest)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+
+	value := rec.payload
+	if format == envelope.FormatEnvelope {
+		env := envelope.New(dest.Stream, meta.TenantID, meta.GatewayInstance, rec.payloadType, rec.receivedAt, rec.payload)
+		if rec.claimCheck != nil {
+			env.ClaimCheck = &envelope.ClaimCheck{
+				Field:  "body",
+				URI:    rec.claimCheck.URI,
+				SHA256: rec.claimCheck.SHA256,
+				Size:   rec.claimCheck.Size,
+			}
+		}
+		if value, err = env.Marshal(); err != nil {
+			return kafka.Message{}, err
+		}
+		meta.SchemaVersion = envelope.SchemaVersion
+	}
+
+	// Encrypt the value with the destination stream's data key, bound to
+	// the stream ID so it can't be replayed into another stream
+	mode, keyID, err := s.streamEncryption(ctx, dest)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	if mode == encryption.AESGCM {
+		dataKey, err := s.DataKeys.Get(ctx, keyID)
+		if err != nil {
+			return kafka.Message{}, fmt.Errorf("failed to get data key %s: %w", keyID, err)
+		}
+		if value, err = encryption.Encrypt(dataKey, value, []byte(dest.StreamID)); err != nil {
+			return kafka.Message{}, err
+		}
+		meta.ContentEncoding = encryption.ContentEncoding
+		meta.EncryptionKeyID = keyID
+	}
+
+	msg := kafka.Message{
+		Topic:   dest.Topic,
+		Key:     key,
+		Value:   value,
+		Headers: s.RecordHeaders.Headers(meta),
+	}
+	if s.Signer != nil {
+		s.Signer.Sign(&msg)
+	}
+	return msg, nil
+}
+
+// recordFormat returns the record format of a destination stream, falling
+// back to the gateway default when the stream sets none or an invalid one
+func (s *IngestGatewayServer) recordFormat(ctx context.Context, dest routing.Destination) (string, error) {
+	if dest.StreamID == "" || s.Settings == nil {
+		return s.Config.RecordFormat, nil
+	}
+	settings, err := s.Settings.Get(ctx, dest.StreamID)
+	if err != nil {
+		return "", err
+	}
+	if settings.RecordFormat == "" {
+		return s.Config.RecordFormat, nil
+	}
+	format, err := envelope.ParseFormat(settings.RecordFormat)
+	if err != nil {
+		log.Printf("Ignoring record format of stream %s: %v", dest.Stream, err)
+		return s.Config.RecordFormat, nil
+	}
+	return format, nil
+}
+
+// streamEncryption returns the record value encryption of a destination
+// stream and the ID of its data key, which defaults to the stream ID
+func (s *IngestGatewayServer) streamEncryption(ctx context.Context, dest routing.Destination) (string, string, error) {
+	mode := s.Config.Encryption
+	keyID := dest.StreamID
+	if dest.StreamID == "" || s.Settings == nil {
+		return mode, keyID, nil
+	}
+	settings, err := s.Settings.Get(ctx, dest.StreamID)
+	if err != nil {
+		return "", "", err
+	}
+	if settings.Encryption != "" {
+		// Unlike other settings, an invalid mode fails closed
+		if mode, err = encryption.Parse(settings.Encryption); err != nil {
+			return "", "", err
+		}
+	}
+	if settings.EncryptionKeyID != "" {
+		keyID = settings.EncryptionKeyID
+	}
+	return mode, keyID, nil
+}
+
+// recordKey returns the key of a record published to dest, using the
+// destination stream's partition key strategy, and chooses the balancer of
+// the destination topic
+func (s *IngestGatewayServer) recordKey(ctx context.Context, rec *ingestRecord, dest routing.Destination) ([]byte, error) {
+	strategy, balancer, err := s.streamPartitioning(ctx, dest.StreamID, dest.Stream)
+	if err != nil {
+		return nil, err
+	}
+	if s.balancer != nil {
+		s.balancer.Set(dest.Topic, balancer)
+	}
+
+	if rec.request == nil {
+		return rec.key, nil
+	}
+	return strategy.Key(rec.request), nil
+}
+
+// streamPartitioning returns the partition key strategy and balancer of a
+// stream. Invalid stream settings fall back to the gateway defaults.
+func (s *IngestGatewayServer) streamPartitioning(ctx context.Context, streamID, streamName string) (partition.Strategy, string, error) {
+	strategy := s.partitionKey
+	balancer := s.Config.PartitionBalancer
+	if streamID == "" || s.Settings == nil {
+		return strategy, balancer, nil
+	}
+	settings, err := s.Settings.Get(ctx, streamID)
+	if err != nil {
+		return strategy, balancer, err
+	}
+	if settings.PartitionKey != "" {
+		if parsed, err := partition.ParseStrategy(settings.PartitionKey); err != nil {
+			log.Printf("Ignoring partition key of stream %s: %v", streamName, err)
+		} else {
+			strategy = parsed
+		}
+	}
+	if settings.PartitionBalancer != "" {
+		if parsed, err := partition.ParseBalancer(settings.PartitionBalancer); err != nil {
+			log.Printf("Ignoring partition balancer of stream %s: %v", streamName, err)
+		} else {
+			balancer = parsed
+		}
+	}
+	return strategy, balancer, nil
+}
+
+// recordMetadata returns the ingest metadata for a record published to dest
+func (s *IngestGatewayServer) recordMetadata(rec *ingestRecord, dest routing.Destination) *metadata.Metadata {
+	authUser := rec.auth.UserID
+	if authUser == "" {
+		authUser = rec.auth.ClientID
+	}
+	meta := &metadata.Metadata{
+		TenantID:        rec.auth.TenantID,
+		StreamID:        dest.Stream,
+		AuthSource:      rec.auth.AuthSource,
+		AuthUser:        authUser,
+		ReceivedAt:      rec.receivedAt,
+		GatewayInstance: s.Config.GatewayInstanceID,
+		ContentType:     metadata.ContentTypeJSON,
+		ContentEncoding: metadata.ContentEncodingIdentity,
+		SchemaVersion:   rec.payloadType,
+		BodyEncoding:    rec.bodyEncoding,
+		BodyContentType: rec.bodyContentType,
+	}
+	if rec.claimCheck != nil {
+		meta.ClaimCheckURI = rec.claimCheck.URI
+		meta.ClaimCheckHash = rec.claimCheck.SHA256
+		meta.ClaimCheckSize = rec.claimCheck.Size
+	}
+	meta.TrackingID = rec.trackingID
+	meta.Sequence = rec.sequence
+	meta.Order = rec.order
+	meta.ServerGenerated = rec.serverGenerated
+	meta.ClockSkew = rec.clockSkew
+	meta.OriginalTimestampNs = rec.originalTime
+	if rec.cloudEvent != nil {
+		meta.CloudEventID = rec.cloudEvent.ID
+		meta.CloudEventSource = rec.cloudEvent.Source
+		meta.CloudEventType = rec.cloudEvent.Type
+		meta.CloudEventTime = rec.cloudEvent.Time
+	}
+	return meta
+}
+
+// offloadBody moves bodies above the stream's claim-check threshold to the
+// blob store, clearing req.Body. The blob holds the decoded body bytes. It
+// returns nil when the body stays inline, and a 413 error when the body is
+// above the claim-check maximum.
+func (s *IngestGatewayServer) offloadBody(ctx context.Context, stream *models.Stream, req *capture.Request) (*blobstore.Reference, *ingestError) {
+	size := int64(len(req.Body))
+	threshold := s.Config.ClaimCheckThresholdBytes
+	if s.Settings != nil {
+		settings, err := s.Settings.Get(ctx, stream.ID)
+		if err != nil {
+			log.Printf("Failed to get settings for stream %s: %v", stream.Name, err)
+			return nil, newIngestError(apierror.CodeInternal, "Failed to look up stream")
+		}
+		if settings.ClaimCheckThresholdBytes > 0 {
+			threshold = settings.ClaimCheckThresholdBytes
+		}
+	}
+	if threshold <= 0 || size <= threshold || s.BlobStore == nil {
+		return nil, nil
+	}
+	if max := s.Config.ClaimCheckMaxBytes; max > 0 && size > max {
+		return nil, tooLarge(stream.ID, &limits.Violation{Limit: limits.LimitClaimCheckBytes, Max: max, Actual: size})
+	}
+
+	prefix := stream.ID + "/" + time.Now().UTC().Format("2006-01-02")
+	body, err := req.BodyBytes()
+	if err != nil {
+		return nil, errInvalid(err)
+	}
+	ref, err := blobstore.Put(ctx, s.BlobStore, prefix, body)
+	if err != nil {
+		log.Printf("Failed to offload body for stream %s: %v", stream.Name, err)
+		return nil, newIngestError(apierror.CodeInternal, "Failed to store request body")
+	}
+	req.Body = ""
+	return ref, nil
+}
+
+// discardClaimCheck deletes the blob of a record that was neither published
+// nor dead-lettered, so failed requests don't leave orphaned blobs
+func (s *IngestGatewayServer) discardClaimCheck(ctx context.Context, ref *blobstore.Reference) {
+	if ref == nil || s.BlobStore == nil {
+		return
+	}
+	if err := s.BlobStore.Delete(context.WithoutCancel(ctx), ref.URI); err != nil {
+		log.Printf("Failed to delete claim-check blob %s: %v", ref.URI, err)
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HeaderRequestID carries the ID of an ingested request in the response, so
+// clients that don't accept JSON can correlate requests the gateway assigned
+// an ID to
+const HeaderRequestID = "X-Frkr-Request-Id"
+
+// HeaderImport marks a POST /ingest request as replayed from a recording, such
+// as a packet capture, when set to "true"; see capture.Request.Imported
+const HeaderImport = "X-Frkr-Import"
+
+// IngestResult is the response body of a successful ingest for clients that
+// accept application/json; other clients get a plain "OK"
+type IngestResult struct {
+	RequestID  string    `json:"request_id"`
+	Stream     string    `json:"stream"`
+	ReceivedAt time.Time `json:"received_at"`
+	Durability string    `json:"durability"`
+
+	// ServerGenerated lists the request fields the gateway filled in
+	// because the client omitted them (request_id, timestamp_ns)
+	ServerGenerated []string `json:"server_generated,omitempty"`
+
+	// TrackingID identifies an async request, whose records are published
+	// after the response; it is attached to them as frkr-tracking-id
+	TrackingID string `json:"tracking_id,omitempty"`
+
+	// Records lists where each destination's record was stored; it is
+	// omitted for async requests
+	Records []RecordResult `json:"records,omitempty"`
+}
+
+// RecordResult is where the broker stored one published record
+type RecordResult struct {
+	Stream    string `json:"stream"`
+	Topic     string `json:"topic"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+}
+
+// writeAccepted responds 202 Accepted to a successful ingest
+func writeAccepted(w http.ResponseWriter, r *http.Request, result *IngestResult) {
+	if result != nil && result.RequestID != "" {
+		w.Header().Set(HeaderRequestID, result.RequestID)
+	}
+	if result == nil || !acceptsJSON(r) {
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte("OK"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// acceptsJSON reports whether the Accept header explicitly asks for
+// application/json. Wildcards don't count, so existing clients keep getting
+// the plain text response.
+func acceptsJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil || mediaType != "application/json" {
+				continue
+			}
+			if q := strings.TrimSpace(params["q"]); q == "0" || q == "0.0" || q == "0.00" || q == "0.000" {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
@@ -1,16 +1,38 @@
 package server
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
+	"time"
 
 	"github.com/frkr-io/frkr-common/gateway"
 	"github.com/frkr-io/frkr-common/metrics"
+	"github.com/frkr-io/frkr-common/models"
 	"github.com/frkr-io/frkr-common/plugins"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/blobstore"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/cloudevents"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/config"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/deadletter"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/encryption"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/metadata"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/mirror"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/ordering"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/partition"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/redact"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/routing"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/signing"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/store"
 	"github.com/segmentio/kafka-go"
 )
 
+// configCacheTTL bounds how long routing rule and stream setting changes take
+// to reach the gateway
+const configCacheTTL = 30 * time.Second
+
 // IngestGatewayServer holds the gateway server dependencies
 type IngestGatewayServer struct {
 	DB            *sql.DB
@@ -19,6 +41,25 @@ type IngestGatewayServer struct {
 	HealthChecker *gateway.GatewayHealthChecker
 	AuthPlugin    plugins.AuthPlugin
 	SecretPlugin  plugins.SecretPlugin
+	Streams       *store.Cache[*models.Stream] // Streams by name
+	Routes        *store.Cache[*routing.Table]
+	Settings      *store.Cache[*store.StreamSettings]
+	DeadLetters   *deadletter.Publisher // nil when dead-lettering is disabled
+	Config        *config.IngestConfig
+	RecordHeaders *metadata.HeaderSet
+	Redactor      *redact.Headers      // Headers masked in captured traffic before publishing
+	BlobStore     blobstore.Store      // nil when claim checks are disabled
+	RecentRoutes  *routing.Recent      // Destinations of recent requests, for late responses
+	DataKeys      *store.Cache[[]byte] // Record encryption keys by key ID
+	Signer        *signing.Signer      // nil when signing is disabled
+	SigningKeys   *signing.KeySet      // Public keys served at /.well-known/frkr-keys
+
+	mirrorSlots  chan struct{}            // Bounds mirrored requests published in the background
+	mirrorWG     sync.WaitGroup           // Mirrored requests being published in the background
+	writers      map[string]messageWriter // Writer per durability mode
+	balancer     *partition.TopicBalancer // Balancer of the durability writers
+	partitionKey partition.Strategy       // Default partition key strategy
+	sequencer    *ordering.Sequencer      // Turns to publish in ordered mode
 }
 
 // NewIngestGatewayServer creates a new ingest gateway server
@@ -32,9 +73,10 @@ func NewIngestGatewayServer(
 ) *IngestGatewayServer {
 	// Register ingest-specific metrics
 	metrics.RegisterIngestMetrics()
+	registerMetrics()
 	metrics.SetServiceInfo("frkr-ingest-gateway", "0.1.0")
 
-	return &IngestGatewayServer{
+	s := &IngestGatewayServer{
 		DB:            db,
 		Writer:        writer,
 		BrokerURL:     brokerURL,
@@ -42,6 +84,52 @@ func NewIngestGatewayServer(
 		AuthPlugin:    authPlugin,
 		SecretPlugin:  secretPlugin,
 	}
+	s.Configure(config.Default())
+	s.Streams = store.NewCache(func(ctx context.Context, name string) (*models.Stream, error) {
+		return store.GetStreamByName(ctx, s.DB, name)
+	}, configCacheTTL)
+	s.Routes = store.NewCache(func(ctx context.Context, streamID string) (*routing.Table, error) {
+		return store.LoadRoutingTable(ctx, s.DB, streamID)
+	}, configCacheTTL)
+	s.Settings = store.NewCache(func(ctx context.Context, streamID string) (*store.StreamSettings, error) {
+		return store.GetStreamSettings(ctx, s.DB, streamID)
+	}, configCacheTTL)
+	s.DataKeys = store.NewCache(s.loadDataKey, configCacheTTL)
+	return s
+}
+
+// loadDataKey reads a record encryption key from the secret plugin
+func (s *IngestGatewayServer) loadDataKey(ctx context.Context, keyID string) ([]byte, error) {
+	secret, err := s.SecretPlugin.GetSecret(ctx, plugins.SecretTypeEncryptionKey, keyID)
+	if err != nil {
+		return nil, err
+	}
+	return encryption.ParseKey(secret)
+}
+
+// Configure applies ingest configuration to the server
+func (s *IngestGatewayServer) Configure(cfg *config.IngestConfig) {
+	s.Config = cfg
+	s.RecordHeaders = metadata.NewHeaderSet(cfg.DisabledRecordHeaders)
+	s.Redactor = redact.NewHeaders(cfg.RedactHeaders)
+	s.RecentRoutes = routing.NewRecent(cfg.ResponseCorrelationSize, cfg.ResponseCorrelationTTL)
+	s.mirrorSlots = make(chan struct{}, cfg.MirrorMaxInFlight)
+	s.partitionKey, _ = partition.ParseStrategy(cfg.PartitionKey) // Checked by cfg.Validate
+	s.sequencer = ordering.NewSequencer(orderingIdleTTL)
+	s.configureWriters(cfg)
+}
+
+// Close flushes records queued in async durability mode and closes the
+// durability writers. The shared Writer is owned by the caller.
+func (s *IngestGatewayServer) Close() error {
+	var errs []error
+	for _, w := range s.writers {
+		if err := w.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.writers = nil
+	return errors.Join(errs...)
 }
 
 // SetupHandlers registers all HTTP handlers on the provided mux
@@ -74,7 +162,9 @@ func (s *IngestGatewayServer) SetupHandlers(mux *http.ServeMux, cfg *gateway.Gat
 
 	// Business endpoint
 	mux.HandleFunc("/ingest", s.IngestHandler())
+	mux.HandleFunc("/ingest/response", s.ResponseHandler())
+	mux.HandleFunc("/ingest/har", s.HARHandler())
+	mux.HandleFunc(signing.WellKnownPath, s.KeysHandler())
+	mux.HandleFunc(cloudevents.PathPrefix, s.CloudEventsHandler())
+	mux.HandleFunc(mirror.PathPrefix, s.MirrorHandler(""))
 }
-
-
-
@@ -0,0 +1,560 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/frkr-io/frkr-common/gateway"
+	"github.com/frkr-io/frkr-common/models"
+	"github.com/frkr-io/frkr-common/plugins"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/config"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/durability"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/encryption"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/envelope"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/limits"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/metadata"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/redact"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/store"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Credentials accepted by fakeAuth
+const (
+	validToken   = "Bearer valid-token"
+	testTenantID = "tenant-1"
+	testUserID   = "alice"
+
+	// anonymousToken is accepted without identifying a user or client
+	anonymousToken = "Bearer anonymous"
+)
+
+// testStreams are the streams known to test servers, by name. The caller
+// may not write to "forbidden".
+var testStreams = map[string]*models.Stream{
+	"orders":    {ID: "stream-orders", Name: "orders", Topic: "frkr.orders"},
+	"forbidden": {ID: "stream-forbidden", Name: "forbidden", Topic: "frkr.forbidden"},
+}
+
+// fakeAuth accepts validToken and allows writes to every stream but "forbidden"
+type fakeAuth struct{}
+
+func (fakeAuth) ValidateRequest(ctx context.Context, r *http.Request, secretPlugin plugins.SecretPlugin) (*plugins.AuthResult, error) {
+	return fakeAuth{}.ValidateAuthHeader(ctx, r.Header.Get("Authorization"), secretPlugin)
+}
+
+func (fakeAuth) ValidateAuthHeader(_ context.Context, authHeader string, _ plugins.SecretPlugin) (*plugins.AuthResult, error) {
+	switch authHeader {
+	case "":
+		return nil, errors.New("no credentials")
+	case validToken:
+		return &plugins.AuthResult{UserID: testUserID, TenantID: testTenantID, AuthSource: "test"}, nil
+	case anonymousToken:
+		return &plugins.AuthResult{TenantID: testTenantID, AuthSource: "test"}, nil
+	default:
+		return nil, errors.New("invalid token")
+	}
+}
+
+func (fakeAuth) CanAccessStream(_ context.Context, _ *plugins.AuthResult, streamID string, _ string) (bool, error) {
+	return streamID != "forbidden", nil
+}
+
+// fakeSecrets serves generic secrets by type and identifier, joined by "/";
+// fakeAuth doesn't use it
+type fakeSecrets map[string][]byte
+
+func (fakeSecrets) GetUserPassword(context.Context, string) (string, string, error) {
+	return "", "", errors.New("not supported")
+}
+
+func (fakeSecrets) GetClientSecret(context.Context, string) (string, string, error) {
+	return "", "", errors.New("not supported")
+}
+
+func (fakeSecrets) GetEncryptionKey(context.Context, string) ([]byte, []byte, error) {
+	return nil, nil, errors.New("not supported")
+}
+
+func (f fakeSecrets) GetSecret(_ context.Context, secretType, identifier string) ([]byte, error) {
+	secret, ok := f[secretType+"/"+identifier]
+	if !ok {
+		return nil, errors.New("secret not found")
+	}
+	return secret, nil
+}
+
+// fakeWriter records the messages written to it, reporting placements to
+// the completion callback like the durability writers do
+type fakeWriter struct {
+	completed func(messages []kafka.Message, err error)
+
+	mu       sync.Mutex
+	err      error // Returned by every write when set
+	messages []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	err := w.err
+	if err == nil {
+		for i := range msgs {
+			msgs[i].Offset = int64(len(w.messages))
+			w.messages = append(w.messages, msgs[i])
+		}
+	}
+	w.mu.Unlock()
+
+	if w.completed != nil {
+		w.completed(msgs, err)
+	}
+	return err
+}
+
+func (w *fakeWriter) Close() error {
+	return nil
+}
+
+// written returns a copy of the messages written so far
+func (w *fakeWriter) written() []kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]kafka.Message(nil), w.messages...)
+}
+
+// pingDriver is a database driver whose connections only answer pings
+type pingDriver struct{}
+
+func (pingDriver) Open(string) (driver.Conn, error) { return pingConn{}, nil }
+
+type pingConn struct{}
+
+func (pingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (pingConn) Close() error                        { return nil }
+func (pingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+var registerPingDriver sync.Once
+
+// readyHealthChecker returns a health checker that has seen a reachable
+// database and broker
+func readyHealthChecker(t *testing.T) *gateway.GatewayHealthChecker {
+	t.Helper()
+	registerPingDriver.Do(func() { sql.Register("ping", pingDriver{}) })
+	db, err := sql.Open("ping", "")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	// The broker check only dials
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = lis.Close() })
+
+	hc := gateway.NewGatewayHealthChecker("frkr-ingest-gateway", "test")
+	hc.CheckDependencies(db, lis.Addr().String())
+	require.True(t, hc.IsReady())
+	return hc
+}
+
+// newTestServer returns a ready server publishing every durability mode
+// through the returned writer
+func newTestServer(t *testing.T) (*IngestGatewayServer, *fakeWriter) {
+	t.Helper()
+	s := &IngestGatewayServer{
+		HealthChecker: readyHealthChecker(t),
+		AuthPlugin:    fakeAuth{},
+		SecretPlugin:  fakeSecrets{},
+	}
+	s.Configure(config.Default())
+	s.Streams = store.NewCache(func(_ context.Context, name string) (*models.Stream, error) {
+		if stream, ok := testStreams[name]; ok {
+			return stream, nil
+		}
+		return nil, store.ErrNotFound
+	}, time.Minute)
+
+	w := &fakeWriter{completed: s.writeCompleted}
+	s.writers = make(map[string]messageWriter, len(durability.Modes))
+	for _, mode := range durability.Modes {
+		s.writers[mode] = w
+	}
+	return s, w
+}
+
+// decodeError decodes a JSON error response
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) apierror.Error {
+	t.Helper()
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var resp apierror.Error
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	return resp
+}
+
+// decodeRecord decodes the mirrored request published in a message
+func decodeRecord(t *testing.T, msg kafka.Message) *capture.Request {
+	t.Helper()
+	var req capture.Request
+	require.NoError(t, json.Unmarshal(msg.Value, &req))
+	require.NotNil(t, req.MirroredRequest)
+	return &req
+}
+
+// header returns the value of a message header
+func header(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestIngestHandler(t *testing.T) {
+	s, w := newTestServer(t)
+
+	body := `{"stream_id":"orders","request":{"method":"POST","path":"/orders","body":"{\"id\":1}","request_id":"req-1"}}`
+	r := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	r.Header.Set("Authorization", validToken)
+	r.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	s.IngestHandler()(rec, r)
+
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "req-1", rec.Header().Get(HeaderRequestID))
+	var result IngestResult
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	assert.Equal(t, "req-1", result.RequestID)
+	assert.Equal(t, "orders", result.Stream)
+	assert.Equal(t, durability.Leader, result.Durability)
+	assert.Equal(t, []string{capture.GeneratedTimestamp}, result.ServerGenerated)
+	assert.Equal(t, []RecordResult{{Stream: "orders", Topic: "frkr.orders", Offset: 0}}, result.Records)
+
+	messages := w.written()
+	require.Len(t, messages, 1)
+	msg := messages[0]
+	assert.Equal(t, "frkr.orders", msg.Topic)
+	assert.Equal(t, "req-1", string(msg.Key))
+	assert.Equal(t, testTenantID, header(msg, metadata.HeaderTenantID))
+	assert.Equal(t, testUserID, header(msg, metadata.HeaderAuthUser))
+	assert.Equal(t, "orders", header(msg, metadata.HeaderStreamID))
+	req := decodeRecord(t, msg)
+	assert.Equal(t, "POST", req.Method)
+	assert.Equal(t, "/orders", req.Path)
+	assert.Equal(t, `{"id":1}`, req.Body)
+	assert.NotZero(t, req.TimestampNs)
+}
+
+// Credentials in captured traffic are masked before the record is published
+func TestIngestHandler_RedactsHeaders(t *testing.T) {
+	s, w := newTestServer(t)
+
+	body := `{"stream_id":"orders","request":{"method":"GET","path":"/orders","headers":{"Authorization":"Bearer app-token","cookie":"sid=1","accept":"*/*"},` +
+		`"response":{"status":200,"headers":{"set-cookie":"sid=2","content-type":"text/plain"}}}}`
+	r := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	r.Header.Set("Authorization", validToken)
+	rec := httptest.NewRecorder()
+	s.IngestHandler()(rec, r)
+
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	messages := w.written()
+	require.Len(t, messages, 1)
+	req := decodeRecord(t, messages[0])
+	assert.Equal(t, map[string]string{"Authorization": redact.Mask, "cookie": redact.Mask, "accept": "*/*"}, req.Headers)
+	require.NotNil(t, req.Response)
+	assert.Equal(t, map[string]string{"set-cookie": redact.Mask, "content-type": "text/plain"}, req.Response.Headers)
+}
+
+// Imported captures keep their historical timestamps: they are exempt from
+// the maximum age and from clock skew correction
+func TestIngestHandler_Import(t *testing.T) {
+	captured := time.Now().Add(-48 * time.Hour).UnixNano()
+	body := fmt.Sprintf(`{"stream_id":"orders","request":{"method":"GET","path":"/orders","timestamp_ns":%d}}`, captured)
+
+	tests := []struct {
+		name     string
+		imported string
+		status   int
+	}{
+		{name: "imported", imported: "true", status: http.StatusAccepted},
+		{name: "not imported", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, w := newTestServer(t)
+			s.Config.ClockSkew.Correct = true
+
+			r := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+			r.Header.Set("Authorization", validToken)
+			if tt.imported != "" {
+				r.Header.Set(HeaderImport, tt.imported)
+			}
+			rec := httptest.NewRecorder()
+			s.IngestHandler()(rec, r)
+
+			require.Equal(t, tt.status, rec.Code)
+			if tt.status != http.StatusAccepted {
+				assert.Empty(t, w.written())
+				return
+			}
+			messages := w.written()
+			require.Len(t, messages, 1)
+			assert.Equal(t, captured, decodeRecord(t, messages[0]).TimestampNs)
+			assert.Empty(t, header(messages[0], metadata.HeaderClockSkew))
+		})
+	}
+}
+
+// The clock skew metric is labelled by client ID only, so the number of
+// authenticated users doesn't set its cardinality
+func TestSkewClient(t *testing.T) {
+	tests := []struct {
+		name       string
+		authResult *plugins.AuthResult
+		want       string
+	}{
+		{name: "client", authResult: &plugins.AuthResult{ClientID: "svc-orders"}, want: "svc-orders"},
+		{name: "user", authResult: &plugins.AuthResult{UserID: testUserID}, want: "user"},
+		{name: "user and client", authResult: &plugins.AuthResult{UserID: testUserID, ClientID: "svc-orders"}, want: "svc-orders"},
+		{name: "unidentified", authResult: &plugins.AuthResult{TenantID: testTenantID}, want: "unknown"},
+		{name: "no auth result", want: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, skewClient(tt.authResult))
+		})
+	}
+}
+
+// Data keys come from the secret plugin; a missing key fails the request
+// rather than publishing plaintext
+func TestIngestHandler_Encrypted(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	secret := []byte(base64.StdEncoding.EncodeToString(key))
+	tests := []struct {
+		name    string
+		secrets fakeSecrets
+		status  int
+	}{
+		{name: "key found", secrets: fakeSecrets{plugins.SecretTypeEncryptionKey + "/stream-orders": secret}, status: http.StatusAccepted},
+		{name: "key missing", secrets: fakeSecrets{}, status: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, w := newTestServer(t)
+			s.SecretPlugin = tt.secrets
+			s.DataKeys = store.NewCache(s.loadDataKey, time.Minute)
+			s.Config.Encryption = encryption.AESGCM
+
+			body := `{"stream_id":"orders","request":{"method":"GET","path":"/orders"}}`
+			r := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+			r.Header.Set("Authorization", validToken)
+			rec := httptest.NewRecorder()
+			s.IngestHandler()(rec, r)
+
+			require.Equal(t, tt.status, rec.Code)
+			if tt.status != http.StatusAccepted {
+				assert.Empty(t, w.written())
+				return
+			}
+			messages := w.written()
+			require.Len(t, messages, 1)
+			msg := messages[0]
+			assert.Equal(t, "stream-orders", header(msg, metadata.HeaderEncryptionKeyID))
+			plaintext, err := encryption.Decrypt(key, msg.Value, []byte("stream-orders"))
+			require.NoError(t, err)
+			var req capture.Request
+			require.NoError(t, json.Unmarshal(plaintext, &req))
+			assert.Equal(t, "/orders", req.Path)
+		})
+	}
+}
+
+// An invalid stream record format falls back to the gateway default rather
+// than failing every publish to the stream
+func TestIngestHandler_RecordFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		envelope bool
+	}{
+		{name: "default", format: "", envelope: false},
+		{name: "envelope", format: envelope.FormatEnvelope, envelope: true},
+		{name: "invalid", format: "bogus", envelope: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, w := newTestServer(t)
+			s.Settings = store.NewCache(func(_ context.Context, streamID string) (*store.StreamSettings, error) {
+				return &store.StreamSettings{StreamID: streamID, RecordFormat: tt.format}, nil
+			}, time.Minute)
+
+			body := `{"stream_id":"orders","request":{"method":"GET","path":"/orders"}}`
+			r := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+			r.Header.Set("Authorization", validToken)
+			rec := httptest.NewRecorder()
+			s.IngestHandler()(rec, r)
+
+			require.Equal(t, http.StatusAccepted, rec.Code)
+			messages := w.written()
+			require.Len(t, messages, 1)
+			if tt.envelope {
+				env, err := envelope.Unmarshal(messages[0].Value)
+				require.NoError(t, err)
+				assert.Equal(t, "orders", env.Stream)
+				return
+			}
+			assert.Equal(t, "/orders", decodeRecord(t, messages[0]).Path)
+		})
+	}
+}
+
+func TestIngestHandler_PlainResponse(t *testing.T) {
+	s, w := newTestServer(t)
+
+	body := `{"stream_id":"orders","request":{"method":"GET","path":"/orders"}}`
+	r := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	r.Header.Set("Authorization", validToken)
+	rec := httptest.NewRecorder()
+	s.IngestHandler()(rec, r)
+
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "OK", rec.Body.String())
+	messages := w.written()
+	require.Len(t, messages, 1)
+	assert.Equal(t, rec.Header().Get(HeaderRequestID), decodeRecord(t, messages[0]).RequestId)
+}
+
+func TestIngestHandler_Errors(t *testing.T) {
+	const valid = `{"stream_id":"orders","request":{"method":"GET","path":"/orders","request_id":"req-1"}}`
+	tests := []struct {
+		name      string
+		method    string
+		body      string
+		auth      string
+		notReady  bool
+		writeErr  error
+		status    int
+		code      string
+		requestID string
+		fields    []string
+	}{
+		{name: "method", method: http.MethodGet, body: valid, auth: validToken, status: http.StatusMethodNotAllowed, code: apierror.CodeMethodNotAllowed},
+		{name: "not ready", body: valid, auth: validToken, notReady: true, status: http.StatusServiceUnavailable, code: apierror.CodeServiceUnavailable},
+		{name: "malformed json", body: `{"stream_id":`, auth: validToken, status: http.StatusBadRequest, code: apierror.CodeInvalidRequest},
+		{name: "missing credentials", body: valid, status: http.StatusUnauthorized, code: apierror.CodeAuthMissing, requestID: "req-1"},
+		{name: "invalid credentials", body: valid, auth: "Bearer wrong", status: http.StatusUnauthorized, code: apierror.CodeAuthInvalid, requestID: "req-1"},
+		{name: "no user or client", body: valid, auth: anonymousToken, status: http.StatusUnauthorized, code: apierror.CodeAuthInvalid, requestID: "req-1"},
+		{
+			name:      "forbidden stream",
+			body:      `{"stream_id":"forbidden","request":{"method":"GET","path":"/","request_id":"req-1"}}`,
+			auth:      validToken,
+			status:    http.StatusForbidden,
+			code:      apierror.CodeAuthForbidden,
+			requestID: "req-1",
+		},
+		{name: "missing request", body: `{"stream_id":"orders"}`, auth: validToken, status: http.StatusBadRequest, code: apierror.CodeInvalidRequest, fields: []string{"request"}},
+		{name: "missing stream", body: `{"request":{"method":"GET","path":"/","request_id":"req-1"}}`, auth: validToken, status: http.StatusBadRequest, code: apierror.CodeInvalidRequest, requestID: "req-1", fields: []string{"stream_id"}},
+		{
+			name:      "invalid fields",
+			body:      `{"stream_id":"orders","request":{"method":"GET","path":"orders","request_id":"req-1"}}`,
+			auth:      validToken,
+			status:    http.StatusBadRequest,
+			code:      apierror.CodeInvalidRequest,
+			requestID: "req-1",
+			fields:    []string{"request.path"},
+		},
+		{
+			name:      "unknown stream",
+			body:      `{"stream_id":"missing","request":{"method":"GET","path":"/","request_id":"req-1"}}`,
+			auth:      validToken,
+			status:    http.StatusNotFound,
+			code:      apierror.CodeStreamNotFound,
+			requestID: "req-1",
+		},
+		{name: "broker failure", body: valid, auth: validToken, writeErr: kafka.MessageSizeTooLarge, status: http.StatusServiceUnavailable, code: apierror.CodeBrokerUnavailable, requestID: "req-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, w := newTestServer(t)
+			if tt.notReady {
+				s.HealthChecker = gateway.NewGatewayHealthChecker("frkr-ingest-gateway", "test")
+			}
+			w.err = tt.writeErr
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+
+			r := httptest.NewRequest(method, "/ingest", strings.NewReader(tt.body))
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			s.IngestHandler()(rec, r)
+
+			assert.Equal(t, tt.status, rec.Code)
+			resp := decodeError(t, rec)
+			assert.Equal(t, tt.code, resp.Code)
+			assert.Equal(t, tt.requestID, resp.RequestID)
+			assert.Equal(t, apierror.Retryable(tt.code), resp.Retryable)
+			var fields []string
+			for _, f := range resp.Fields {
+				fields = append(fields, f.Field)
+			}
+			assert.Equal(t, tt.fields, fields)
+			assert.Empty(t, w.written())
+		})
+	}
+}
+
+func TestIngestHandler_TooLarge(t *testing.T) {
+	s, w := newTestServer(t)
+	s.Config.Limits.MaxRequestBytes = 64
+
+	body := `{"stream_id":"orders","request":{"method":"POST","path":"/orders","body":"` + strings.Repeat("a", 64) + `"}}`
+	r := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	r.Header.Set("Authorization", validToken)
+	rec := httptest.NewRecorder()
+	s.IngestHandler()(rec, r)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	resp := decodeError(t, rec)
+	assert.Equal(t, apierror.CodePayloadTooLarge, resp.Code)
+	assert.Equal(t, limits.LimitRequestBytes, resp.Limit)
+	assert.Equal(t, int64(64), resp.Max)
+	assert.Empty(t, w.written())
+}
+
+// Rejections are counted against the stream by name
+func TestIngestHandler_TooLargeBody(t *testing.T) {
+	s, w := newTestServer(t)
+	s.Config.Limits.MaxBodyBytes = 4
+	rejected := testutil.ToFloat64(limitRejectionsTotal.WithLabelValues("orders", limits.LimitBodyBytes))
+
+	body := `{"stream_id":"orders","request":{"method":"POST","path":"/orders","body":"too long"}}`
+	r := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+	r.Header.Set("Authorization", validToken)
+	rec := httptest.NewRecorder()
+	s.IngestHandler()(rec, r)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Equal(t, limits.LimitBodyBytes, decodeError(t, rec).Limit)
+	assert.Equal(t, rejected+1, testutil.ToFloat64(limitRejectionsTotal.WithLabelValues("orders", limits.LimitBodyBytes)))
+	assert.Empty(t, w.written())
+}
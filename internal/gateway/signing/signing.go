@@ -0,0 +1,218 @@
+// Package signing signs published records with Ed25519 keys managed through
+// the SecretPlugin, so consumers can prove a record is exactly what the
+// gateway published, and publishes the public keys as a JSON Web Key Set.
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/frkr-io/frkr-common/plugins"
+	"github.com/segmentio/kafka-go"
+)
+
+// SecretType is the SecretPlugin secret type of signing keys
+const SecretType = "signing_key"
+
+// Record headers attached to signed records
+const (
+	HeaderSignature = "frkr-signature"        // Standard base64 Ed25519 signature
+	HeaderKeyID     = "frkr-signature-key-id" // ID of the signing key
+)
+
+// WellKnownPath is where the gateway serves its public keys
+const WellKnownPath = "/.well-known/frkr-keys"
+
+// signatureContext prefixes the signed payload, so signatures can't be
+// replayed for another purpose
+const signatureContext = "frkr-record-signature-v1"
+
+// ParsePrivateKey returns the Ed25519 key held by a secret: a 32-byte seed or
+// 64-byte private key, in standard base64 or raw. A raw key that happens to
+// be valid base64 of the wrong length is taken as raw.
+func ParsePrivateKey(secret []byte) (ed25519.PrivateKey, error) {
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(secret))); err == nil {
+		if key, ok := privateKey(decoded); ok {
+			return key, nil
+		}
+	}
+	if key, ok := privateKey(secret); ok {
+		return key, nil
+	}
+	return nil, errors.New("signing key must be a 32-byte Ed25519 seed or 64-byte private key, raw or base64")
+}
+
+func privateKey(raw []byte) (ed25519.PrivateKey, bool) {
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), true
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(bytes.Clone(raw)), true
+	default:
+		return nil, false
+	}
+}
+
+// Payload returns the canonical bytes signed for a record: the signature
+// context, the topic, the record key, then each header name and value and
+// finally the record value, each preceded by its length as a 4-byte
+// big-endian integer. Headers are sorted by name, then value, so reordering
+// them doesn't break the signature; the signature headers are skipped.
+func Payload(msg kafka.Message) []byte {
+	headers := make([]kafka.Header, 0, len(msg.Headers))
+	size := len(signatureContext) + 12 + len(msg.Topic) + len(msg.Key) + len(msg.Value)
+	for _, h := range msg.Headers {
+		if h.Key == HeaderSignature || h.Key == HeaderKeyID {
+			continue
+		}
+		headers = append(headers, h)
+		size += 8 + len(h.Key) + len(h.Value)
+	}
+	slices.SortStableFunc(headers, func(a, b kafka.Header) int {
+		if c := strings.Compare(a.Key, b.Key); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.Value, b.Value)
+	})
+
+	buf := make([]byte, 0, size)
+	buf = append(buf, signatureContext...)
+	buf = appendField(buf, []byte(msg.Topic))
+	buf = appendField(buf, msg.Key)
+	for _, h := range headers {
+		buf = appendField(buf, []byte(h.Key))
+		buf = appendField(buf, h.Value)
+	}
+	return appendField(buf, msg.Value)
+}
+
+func appendField(buf, field []byte) []byte {
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(field)))
+	return append(buf, field...)
+}
+
+// Signer signs records with one key
+type Signer struct {
+	keyID string
+	key   ed25519.PrivateKey
+}
+
+// NewSigner returns a Signer for a key
+func NewSigner(keyID string, key ed25519.PrivateKey) *Signer {
+	return &Signer{keyID: keyID, key: key}
+}
+
+// KeyID returns the ID of the signing key
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// Sign signs a record's topic, key, headers and value, attaching the
+// signature headers
+func (s *Signer) Sign(msg *kafka.Message) {
+	sig := ed25519.Sign(s.key, Payload(*msg))
+	msg.Headers = append(msg.Headers,
+		kafka.Header{Key: HeaderSignature, Value: []byte(base64.StdEncoding.EncodeToString(sig))},
+		kafka.Header{Key: HeaderKeyID, Value: []byte(s.keyID)},
+	)
+}
+
+// Verify checks a signed record against the public keys of a key set
+func Verify(keys *KeySet, msg kafka.Message) error {
+	var sigHeader, keyID string
+	for _, h := range msg.Headers {
+		switch h.Key {
+		case HeaderSignature:
+			sigHeader = string(h.Value)
+		case HeaderKeyID:
+			keyID = string(h.Value)
+		}
+	}
+	if sigHeader == "" || keyID == "" {
+		return errors.New("record is not signed")
+	}
+	pub, ok := keys.PublicKey(keyID)
+	if !ok {
+		return fmt.Errorf("unknown signing key %s", keyID)
+	}
+	sig, err := base64.StdEncoding.DecodeString(sigHeader)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	if !ed25519.Verify(pub, Payload(msg), sig) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// JWK is an Ed25519 public key in JSON Web Key form (RFC 8037)
+type JWK struct {
+	Kty string `json:"kty"` // OKP
+	Crv string `json:"crv"` // Ed25519
+	X   string `json:"x"`   // base64url public key
+	Kid string `json:"kid"`
+	Use string `json:"use"` // sig
+	Alg string `json:"alg"` // EdDSA
+}
+
+// KeySet is the JSON Web Key Set served at WellKnownPath
+type KeySet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Add adds a public key to the set
+func (ks *KeySet) Add(keyID string, pub ed25519.PublicKey) {
+	ks.Keys = append(ks.Keys, JWK{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(pub),
+		Kid: keyID,
+		Use: "sig",
+		Alg: "EdDSA",
+	})
+}
+
+// PublicKey returns the public key with an ID
+func (ks *KeySet) PublicKey(keyID string) (ed25519.PublicKey, bool) {
+	for _, k := range ks.Keys {
+		if k.Kid != keyID || k.Crv != "Ed25519" {
+			continue
+		}
+		pub, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(pub) != ed25519.PublicKeySize {
+			return nil, false
+		}
+		return ed25519.PublicKey(pub), true
+	}
+	return nil, false
+}
+
+// Load reads the signing key keyID and the retired keys still published for
+// verification, returning a Signer for the signing key and the key set of
+// all their public keys
+func Load(ctx context.Context, secrets plugins.SecretPlugin, keyID string, retiredKeyIDs []string) (*Signer, *KeySet, error) {
+	keys := &KeySet{Keys: []JWK{}}
+	var signer *Signer
+	for _, id := range append([]string{keyID}, retiredKeyIDs...) {
+		secret, err := secrets.GetSecret(ctx, SecretType, id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get signing key %s: %w", id, err)
+		}
+		key, err := ParsePrivateKey(secret)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid signing key %s: %w", id, err)
+		}
+		if signer == nil {
+			signer = NewSigner(id, key)
+		}
+		keys.Add(id, key.Public().(ed25519.PublicKey))
+	}
+	return signer, keys, nil
+}
@@ -0,0 +1,134 @@
+package signing
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSecrets serves signing keys by ID
+type fakeSecrets map[string][]byte
+
+func (f fakeSecrets) GetUserPassword(context.Context, string) (string, string, error) {
+	return "", "", errors.New("not supported")
+}
+
+func (f fakeSecrets) GetClientSecret(context.Context, string) (string, string, error) {
+	return "", "", errors.New("not supported")
+}
+
+func (f fakeSecrets) GetEncryptionKey(context.Context, string) ([]byte, []byte, error) {
+	return nil, nil, errors.New("not supported")
+}
+
+func (f fakeSecrets) GetSecret(_ context.Context, secretType, id string) ([]byte, error) {
+	if secret, ok := f[id]; ok && secretType == SecretType {
+		return secret, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func seed(b byte) []byte {
+	return bytes.Repeat([]byte{b}, ed25519.SeedSize)
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	fromSeed, err := ParsePrivateKey(seed(1))
+	require.NoError(t, err)
+
+	fromBase64, err := ParsePrivateKey([]byte(base64.StdEncoding.EncodeToString(seed(1))))
+	require.NoError(t, err)
+	assert.Equal(t, fromSeed, fromBase64)
+
+	full, err := ParsePrivateKey([]byte(fromSeed))
+	require.NoError(t, err)
+	assert.Equal(t, fromSeed, full)
+
+	// A raw seed that is also valid base64 of the wrong length
+	rawSeed := []byte("abcdefghijklmnopqrstuvwxyz012345")
+	fromRaw, err := ParsePrivateKey(rawSeed)
+	require.NoError(t, err)
+	assert.Equal(t, ed25519.NewKeyFromSeed(rawSeed), fromRaw)
+
+	_, err = ParsePrivateKey([]byte("short"))
+	assert.Error(t, err)
+}
+
+func TestSignVerify(t *testing.T) {
+	signer, keys, err := Load(context.Background(), fakeSecrets{"k2": seed(2), "k1": seed(1)}, "k2", []string{"k1"})
+	require.NoError(t, err)
+	assert.Equal(t, "k2", signer.KeyID())
+	require.Len(t, keys.Keys, 2)
+	assert.Equal(t, "k2", keys.Keys[0].Kid)
+	assert.Equal(t, "OKP", keys.Keys[0].Kty)
+
+	msg := kafka.Message{
+		Topic: "orders",
+		Key:   []byte("req-1"),
+		Value: []byte(`{"method":"POST"}`),
+		Headers: []kafka.Header{
+			{Key: "frkr-stream-id", Value: []byte("orders")},
+			{Key: "frkr-received-at", Value: []byte("2026-01-02T03:04:05Z")},
+		},
+	}
+	signer.Sign(&msg)
+	require.Len(t, msg.Headers, 4)
+	assert.NoError(t, Verify(keys, msg))
+
+	// Header order doesn't matter
+	reordered := msg
+	reordered.Headers = []kafka.Header{msg.Headers[3], msg.Headers[1], msg.Headers[2], msg.Headers[0]}
+	assert.NoError(t, Verify(keys, reordered))
+
+	// Records signed with a retired key still verify
+	old := kafka.Message{Value: []byte("v")}
+	NewSigner("k1", ed25519.NewKeyFromSeed(seed(1))).Sign(&old)
+	assert.NoError(t, Verify(keys, old))
+
+	tampered := msg
+	tampered.Value = []byte(`{"method":"GET"}`)
+	assert.Error(t, Verify(keys, tampered))
+
+	tampered = msg
+	tampered.Headers = append([]kafka.Header{{Key: "frkr-stream-id", Value: []byte("payments")}}, msg.Headers[1:]...)
+	assert.Error(t, Verify(keys, tampered))
+
+	tampered = msg
+	tampered.Topic = "payments"
+	assert.Error(t, Verify(keys, tampered), "topic is signed")
+
+	tampered = msg
+	tampered.Key = []byte("req-2")
+	assert.Error(t, Verify(keys, tampered), "key is signed")
+
+	assert.Error(t, Verify(keys, kafka.Message{Value: []byte("v")}), "unsigned")
+	assert.Error(t, Verify(&KeySet{}, msg), "unknown key")
+}
+
+func TestPayload_FieldBoundaries(t *testing.T) {
+	a := Payload(kafka.Message{Headers: []kafka.Header{{Key: "ab", Value: []byte("c")}}})
+	b := Payload(kafka.Message{Headers: []kafka.Header{{Key: "a", Value: []byte("bc")}}})
+	assert.NotEqual(t, a, b)
+
+	a = Payload(kafka.Message{Topic: "ab", Key: []byte("c")})
+	b = Payload(kafka.Message{Topic: "a", Key: []byte("bc")})
+	assert.NotEqual(t, a, b)
+}
+
+func TestPayload_DuplicateHeaders(t *testing.T) {
+	a := Payload(kafka.Message{Headers: []kafka.Header{{Key: "x", Value: []byte("1")}, {Key: "x", Value: []byte("2")}}})
+	b := Payload(kafka.Message{Headers: []kafka.Header{{Key: "x", Value: []byte("2")}, {Key: "x", Value: []byte("1")}}})
+	assert.Equal(t, a, b)
+}
+
+func TestLoad_MissingKey(t *testing.T) {
+	_, _, err := Load(context.Background(), fakeSecrets{}, "k1", nil)
+	assert.Error(t, err)
+}
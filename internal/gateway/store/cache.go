@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LoaderFunc loads the value cached for a key
+type LoaderFunc[V any] func(ctx context.Context, key string) (V, error)
+
+// Cache caches per-stream lookups so configuration isn't queried on every
+// ingest call. Changes in the database take effect after the TTL expires.
+type Cache[V any] struct {
+	load LoaderFunc[V]
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry[V]
+}
+
+type cacheEntry[V any] struct {
+	value   V
+	expires time.Time
+}
+
+// NewCache creates a cache backed by the given loader
+func NewCache[V any](load LoaderFunc[V], ttl time.Duration) *Cache[V] {
+	return &Cache[V]{
+		load:    load,
+		ttl:     ttl,
+		entries: make(map[string]cacheEntry[V]),
+	}
+}
+
+// Get returns the value for a key, loading it if missing or expired
+func (c *Cache[V]) Get(ctx context.Context, key string) (V, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.value, nil
+	}
+
+	value, err := c.load(ctx, key)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = cacheEntry[V]{value: value, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return value, nil
+}
+
+// Invalidate drops the cached value for a key
+func (c *Cache[V]) Invalidate(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
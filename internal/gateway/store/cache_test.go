@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_Get(t *testing.T) {
+	loads := 0
+	cache := NewCache(func(ctx context.Context, key string) (int, error) {
+		loads++
+		return loads, nil
+	}, time.Minute)
+
+	v, err := cache.Get(context.Background(), "s1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+	v, err = cache.Get(context.Background(), "s1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, v)
+
+	cache.Invalidate("s1")
+	v, err = cache.Get(context.Background(), "s1")
+	require.NoError(t, err)
+	assert.Equal(t, 2, v)
+}
+
+func TestCache_Expiry(t *testing.T) {
+	loads := 0
+	cache := NewCache(func(ctx context.Context, key string) (int, error) {
+		loads++
+		return loads, nil
+	}, 0)
+
+	_, _ = cache.Get(context.Background(), "s1")
+	_, _ = cache.Get(context.Background(), "s1")
+	assert.Equal(t, 2, loads)
+}
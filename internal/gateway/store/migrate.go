@@ -0,0 +1,57 @@
+// Package store provides database access for state owned by the ingest gateway.
+// The shared frkr tables (tenants, streams, users, ...) are migrated by
+// frkr-common; the tables here are migrated by Migrate.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// migrationsTable records the applied ingest gateway migrations, separately
+// from frkr-common's schema_migrations
+const migrationsTable = "ingest_schema_migrations"
+
+// migrations holds the ingest gateway schema migrations
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrate runs all pending ingest gateway migrations through db, the
+// gateway's open connection pool, so they use the same connection settings.
+// The shared frkr tables must already be migrated.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	src, err := iofs.New(migrations, "migrations")
+	if err != nil {
+		return fmt.Errorf("failed to load ingest migrations: %w", err)
+	}
+	// Migrate on a single connection: closing the migration then releases
+	// the connection without closing db, which postgres.WithInstance would
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect for ingest migrations: %w", err)
+	}
+	target, err := postgres.WithConnection(ctx, conn, &postgres.Config{MigrationsTable: migrationsTable})
+	if err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+	m, err := migrate.NewWithInstance("iofs", src, "postgres", target)
+	if err != nil {
+		_ = target.Close()
+		return fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to run ingest migrations: %w", err)
+	}
+	return nil
+}
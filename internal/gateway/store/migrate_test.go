@@ -0,0 +1,32 @@
+package store
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations_Paired(t *testing.T) {
+	src, err := iofs.New(migrations, "migrations")
+	require.NoError(t, err)
+	defer src.Close()
+
+	version, err := src.First()
+	require.NoError(t, err)
+	for {
+		up, _, err := src.ReadUp(version)
+		require.NoError(t, err, "up migration %d", version)
+		up.Close()
+		down, _, err := src.ReadDown(version)
+		require.NoError(t, err, "down migration %d", version)
+		down.Close()
+
+		if version, err = src.Next(version); err != nil {
+			assert.ErrorIs(t, err, fs.ErrNotExist)
+			break
+		}
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE ingest_routing_rules;
+DROP TABLE ingest_routers;
@@ -0,0 +1,22 @@
+CREATE TABLE ingest_routers (
+    stream_id UUID PRIMARY KEY REFERENCES streams(id) ON DELETE CASCADE,
+    mode VARCHAR(20) NOT NULL DEFAULT 'first_match',
+    fallback_stream_id UUID REFERENCES streams(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE ingest_routing_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    router_stream_id UUID NOT NULL REFERENCES ingest_routers(stream_id) ON DELETE CASCADE,
+    priority INT NOT NULL DEFAULT 0,
+    match_field VARCHAR(20) NOT NULL,
+    header_name VARCHAR(255),
+    match_value TEXT NOT NULL,
+    destination_stream_id UUID NOT NULL REFERENCES streams(id) ON DELETE CASCADE,
+    enabled BOOL NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_ingest_routing_rules_router ON ingest_routing_rules (router_stream_id, priority);
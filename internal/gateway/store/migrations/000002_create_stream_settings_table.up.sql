@@ -0,0 +1,24 @@
+CREATE TABLE ingest_stream_settings (
+    stream_id UUID PRIMARY KEY REFERENCES streams(id) ON DELETE CASCADE,
+    topic_partitions INT NOT NULL DEFAULT 0,
+    topic_replication_factor INT NOT NULL DEFAULT 0,
+    topic_cleanup_policy VARCHAR(50) NOT NULL DEFAULT '',
+    topic_compression VARCHAR(20) NOT NULL DEFAULT '',
+    record_format VARCHAR(20) NOT NULL DEFAULT '',
+    claim_check_threshold_bytes BIGINT NOT NULL DEFAULT 0,
+    max_request_bytes BIGINT NOT NULL DEFAULT 0,
+    max_header_count INT NOT NULL DEFAULT 0,
+    max_header_bytes BIGINT NOT NULL DEFAULT 0,
+    max_body_bytes BIGINT NOT NULL DEFAULT 0,
+    durability VARCHAR(20) NOT NULL DEFAULT '',
+    durability_min VARCHAR(20) NOT NULL DEFAULT '',
+    durability_max VARCHAR(20) NOT NULL DEFAULT '',
+    partition_key VARCHAR(255) NOT NULL DEFAULT '',
+    partition_balancer VARCHAR(20) NOT NULL DEFAULT '',
+    ordering VARCHAR(20) NOT NULL DEFAULT '',
+    ordering_window_ms BIGINT NOT NULL DEFAULT 0,
+    encryption VARCHAR(20) NOT NULL DEFAULT '',
+    encryption_key_id VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/routing"
+)
+
+// LoadRoutingTable loads the routing table for a router stream. It returns a
+// nil table when the stream is not configured as a router.
+func LoadRoutingTable(ctx context.Context, db *sql.DB, streamID string) (*routing.Table, error) {
+	var mode string
+	var fallbackID, fallbackName, fallbackTopic sql.NullString
+	err := db.QueryRowContext(ctx, `
+		SELECT r.mode, f.id, f.name, f.topic
+		FROM ingest_routers r
+		LEFT JOIN streams f ON f.id = r.fallback_stream_id AND f.deleted_at IS NULL
+		WHERE r.stream_id = $1
+	`, streamID).Scan(&mode, &fallbackID, &fallbackName, &fallbackTopic)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query router: %w", err)
+	}
+
+	routingMode, err := routing.ParseMode(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	var fallback *routing.Destination
+	if fallbackTopic.Valid {
+		fallback = &routing.Destination{StreamID: fallbackID.String, Stream: fallbackName.String, Topic: fallbackTopic.String}
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT r.id, r.priority, r.match_field, COALESCE(r.header_name, ''), r.match_value, s.id, s.name, s.topic
+		FROM ingest_routing_rules r
+		JOIN streams s ON s.id = r.destination_stream_id AND s.deleted_at IS NULL
+		WHERE r.router_stream_id = $1 AND r.enabled
+		ORDER BY r.priority, r.created_at
+	`, streamID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query routing rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []routing.Rule
+	for rows.Next() {
+		var rule routing.Rule
+		var field string
+		if err := rows.Scan(
+			&rule.ID,
+			&rule.Priority,
+			&field,
+			&rule.HeaderName,
+			&rule.Value,
+			&rule.Destination.StreamID,
+			&rule.Destination.Stream,
+			&rule.Destination.Topic,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan routing rule: %w", err)
+		}
+		if rule.Field, err = routing.ParseField(field); err != nil {
+			return nil, fmt.Errorf("routing rule %s: %w", rule.ID, err)
+		}
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating routing rules: %w", err)
+	}
+
+	return routing.NewTable(routingMode, rules, fallback), nil
+}
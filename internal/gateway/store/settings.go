@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// StreamSettings holds per-stream ingest settings. Zero values mean "use the
+// gateway default", so streams without a settings row behave like before.
+type StreamSettings struct {
+	StreamID string
+
+	// Topic settings applied when the gateway creates the stream's topic
+	TopicPartitions        int
+	TopicReplicationFactor int
+	TopicCleanupPolicy     string
+	TopicCompression       string
+
+	// RecordFormat is "envelope" or "legacy" (bare MirroredRequest JSON)
+	RecordFormat string
+
+	// ClaimCheckThresholdBytes offloads request bodies larger than this to
+	// the blob store
+	ClaimCheckThresholdBytes int64
+
+	// Request size limits, overriding the gateway defaults
+	MaxRequestBytes int64
+	MaxHeaderCount  int
+	MaxHeaderBytes  int64
+	MaxBodyBytes    int64
+
+	// Durability is the stream's default durability mode (async, leader or
+	// all); DurabilityMin and DurabilityMax bound what callers may request
+	Durability    string
+	DurabilityMin string
+	DurabilityMax string
+
+	// PartitionKey is the stream's partition key strategy (see
+	// partition.ParseStrategy) and PartitionBalancer the balancer mapping
+	// keys to partitions (hash, murmur2 or least-bytes)
+	PartitionKey      string
+	PartitionBalancer string
+
+	// Ordering is the stream's ordering mode (off, flag or reorder) and
+	// OrderingWindowMs how long reorder mode holds back a request that
+	// arrived ahead of its sequence
+	Ordering         string
+	OrderingWindowMs int64
+
+	// Encryption is the stream's record value encryption (none or aes-gcm)
+	// and EncryptionKeyID the key directory data key its values are
+	// encrypted with, defaulting to the stream ID. Changing the key ID
+	// rotates the key; older records name the key they were encrypted with.
+	Encryption      string
+	EncryptionKeyID string
+}
+
+// GetStreamSettings retrieves the settings for a stream, returning zero-value
+// settings if none have been stored
+func GetStreamSettings(ctx context.Context, db *sql.DB, streamID string) (*StreamSettings, error) {
+	settings := StreamSettings{StreamID: streamID}
+	err := db.QueryRowContext(ctx, `
+		SELECT topic_partitions, topic_replication_factor, topic_cleanup_policy, topic_compression, record_format, claim_check_threshold_bytes,
+			max_request_bytes, max_header_count, max_header_bytes, max_body_bytes,
+			durability, durability_min, durability_max,
+			partition_key, partition_balancer,
+			ordering, ordering_window_ms,
+			encryption, encryption_key_id
+		FROM ingest_stream_settings
+		WHERE stream_id = $1
+	`, streamID).Scan(
+		&settings.TopicPartitions,
+		&settings.TopicReplicationFactor,
+		&settings.TopicCleanupPolicy,
+		&settings.TopicCompression,
+		&settings.RecordFormat,
+		&settings.ClaimCheckThresholdBytes,
+		&settings.MaxRequestBytes,
+		&settings.MaxHeaderCount,
+		&settings.MaxHeaderBytes,
+		&settings.MaxBodyBytes,
+		&settings.Durability,
+		&settings.DurabilityMin,
+		&settings.DurabilityMax,
+		&settings.PartitionKey,
+		&settings.PartitionBalancer,
+		&settings.Ordering,
+		&settings.OrderingWindowMs,
+		&settings.Encryption,
+		&settings.EncryptionKeyID,
+	)
+	if err == sql.ErrNoRows {
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stream settings: %w", err)
+	}
+	return &settings, nil
+}
+
+// UpsertStreamSettings creates or replaces the settings for a stream
+func UpsertStreamSettings(ctx context.Context, db *sql.DB, settings *StreamSettings) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO ingest_stream_settings (stream_id, topic_partitions, topic_replication_factor, topic_cleanup_policy, topic_compression, record_format, claim_check_threshold_bytes,
+			max_request_bytes, max_header_count, max_header_bytes, max_body_bytes,
+			durability, durability_min, durability_max,
+			partition_key, partition_balancer,
+			ordering, ordering_window_ms,
+			encryption, encryption_key_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
+		ON CONFLICT (stream_id) DO UPDATE
+		SET topic_partitions = EXCLUDED.topic_partitions,
+			topic_replication_factor = EXCLUDED.topic_replication_factor,
+			topic_cleanup_policy = EXCLUDED.topic_cleanup_policy,
+			topic_compression = EXCLUDED.topic_compression,
+			record_format = EXCLUDED.record_format,
+			claim_check_threshold_bytes = EXCLUDED.claim_check_threshold_bytes,
+			max_request_bytes = EXCLUDED.max_request_bytes,
+			max_header_count = EXCLUDED.max_header_count,
+			max_header_bytes = EXCLUDED.max_header_bytes,
+			max_body_bytes = EXCLUDED.max_body_bytes,
+			durability = EXCLUDED.durability,
+			durability_min = EXCLUDED.durability_min,
+			durability_max = EXCLUDED.durability_max,
+			partition_key = EXCLUDED.partition_key,
+			partition_balancer = EXCLUDED.partition_balancer,
+			ordering = EXCLUDED.ordering,
+			ordering_window_ms = EXCLUDED.ordering_window_ms,
+			encryption = EXCLUDED.encryption,
+			encryption_key_id = EXCLUDED.encryption_key_id,
+			updated_at = now()
+	`,
+		settings.StreamID,
+		settings.TopicPartitions,
+		settings.TopicReplicationFactor,
+		settings.TopicCleanupPolicy,
+		settings.TopicCompression,
+		settings.RecordFormat,
+		settings.ClaimCheckThresholdBytes,
+		settings.MaxRequestBytes,
+		settings.MaxHeaderCount,
+		settings.MaxHeaderBytes,
+		settings.MaxBodyBytes,
+		settings.Durability,
+		settings.DurabilityMin,
+		settings.DurabilityMax,
+		settings.PartitionKey,
+		settings.PartitionBalancer,
+		settings.Ordering,
+		settings.OrderingWindowMs,
+		settings.Encryption,
+		settings.EncryptionKeyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upsert stream settings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/frkr-io/frkr-common/models"
+)
+
+// ErrNotFound is returned when a requested record does not exist
+var ErrNotFound = errors.New("not found")
+
+// GetStreamByName retrieves an active stream by name. Like
+// dbcommon.GetStreamTopic it is not tenant scoped, since ingest requests
+// identify streams by name only.
+func GetStreamByName(ctx context.Context, db *sql.DB, name string) (*models.Stream, error) {
+	var stream models.Stream
+	var description sql.NullString
+	err := db.QueryRowContext(ctx, `
+		SELECT id, tenant_id, name, description, status, retention_days, topic, created_at, updated_at
+		FROM streams
+		WHERE name = $1 AND deleted_at IS NULL
+	`, name).Scan(
+		&stream.ID,
+		&stream.TenantID,
+		&stream.Name,
+		&description,
+		&stream.Status,
+		&stream.RetentionDays,
+		&stream.Topic,
+		&stream.CreatedAt,
+		&stream.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("stream '%s': %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stream: %w", err)
+	}
+	stream.Description = description.String
+	return &stream, nil
+}
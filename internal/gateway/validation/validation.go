@@ -0,0 +1,159 @@
+// Package validation checks POST /ingest payloads strictly before they are
+// published: required fields, the HTTP method and path, header names and
+// values, and that the capture timestamp is plausible. Every invalid field is
+// reported, so SDK authors can fix a payload in one round trip.
+package validation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/apierror"
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+)
+
+// MaxRequestIDLength bounds client-supplied request IDs
+const MaxRequestIDLength = 255
+
+// Bounds limits how far a request's timestamp may be from the time it is
+// received. Zero values mean no bound.
+type Bounds struct {
+	MaxAge   time.Duration // How far in the past
+	MaxAhead time.Duration // How far in the future
+}
+
+// Error is a request with invalid fields
+type Error struct {
+	Fields []apierror.FieldError
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate checks an ingest request received at now, returning an *Error
+// listing every invalid field, or nil. A missing timestamp or request ID is
+// not an error; the gateway fills them in. Imported requests may be older than
+// bounds.MaxAge.
+func Validate(req *capture.IngestRequest, now time.Time, bounds Bounds) error {
+	v := &validator{}
+	if req.StreamID == "" {
+		v.add("stream_id", "is required")
+	}
+	r := req.Request
+	if r == nil || r.MirroredRequest == nil {
+		v.add("request", "is required")
+		return v.err()
+	}
+
+	switch {
+	case r.Method == "":
+		v.add("request.method", "is required")
+	case !isToken(r.Method):
+		v.add("request.method", fmt.Sprintf("%q is not a valid HTTP method", r.Method))
+	}
+	switch {
+	case r.Path == "":
+		v.add("request.path", "is required")
+	case r.Path != "*" && !strings.HasPrefix(r.Path, "/"):
+		v.add("request.path", "must start with /")
+	case strings.IndexFunc(r.Path, isSpaceOrControl) >= 0:
+		v.add("request.path", "must not contain whitespace or control characters")
+	}
+
+	for _, name := range sortedKeys(r.Headers) {
+		field := "request.headers[" + name + "]"
+		if !isToken(name) {
+			v.add(field, "is not a valid header name")
+		} else if strings.ContainsAny(r.Headers[name], "\r\n\x00") {
+			v.add(field, "value must not contain CR, LF or NUL")
+		}
+	}
+
+	if r.TimestampNs < 0 {
+		v.add("request.timestamp_ns", "must not be negative")
+	} else if r.TimestampNs > 0 {
+		ts := time.Unix(0, r.TimestampNs)
+		if bounds.MaxAge > 0 && !r.Imported && ts.Before(now.Add(-bounds.MaxAge)) {
+			v.add("request.timestamp_ns", fmt.Sprintf("is more than %s in the past", bounds.MaxAge))
+		}
+		if bounds.MaxAhead > 0 && ts.After(now.Add(bounds.MaxAhead)) {
+			v.add("request.timestamp_ns", fmt.Sprintf("is more than %s in the future", bounds.MaxAhead))
+		}
+	}
+
+	switch {
+	case len(r.RequestId) > MaxRequestIDLength:
+		v.add("request.request_id", fmt.Sprintf("must be at most %d characters", MaxRequestIDLength))
+	case strings.IndexFunc(r.RequestId, isSpaceOrControl) >= 0:
+		v.add("request.request_id", "must not contain whitespace or control characters")
+	}
+
+	if r.Sequence < 0 {
+		v.add("request.sequence", "must not be negative")
+	}
+	if enc := r.Encoding(); enc != capture.BodyEncodingUTF8 && enc != capture.BodyEncodingBase64 {
+		v.add("request.body_encoding", fmt.Sprintf("unknown body encoding %q", r.BodyEncoding))
+	} else if _, err := r.BodyBytes(); err != nil {
+		v.add("request.body", "is not valid base64")
+	}
+	if r.Response != nil {
+		if err := r.Response.Validate(); err != nil {
+			v.add("request.response", err.Error())
+		}
+	}
+	return v.err()
+}
+
+// validator collects field errors
+type validator struct {
+	fields []apierror.FieldError
+}
+
+func (v *validator) add(field, message string) {
+	v.fields = append(v.fields, apierror.FieldError{Field: field, Message: message})
+}
+
+func (v *validator) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &Error{Fields: v.fields}
+}
+
+// isToken reports whether s is an RFC 9110 token, the syntax of HTTP methods
+// and header names
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isSpaceOrControl(r rune) bool {
+	return r <= ' ' || r == 0x7f
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,115 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/frkr-io/frkr-ingest-gateway/internal/gateway/capture"
+	ingestv1 "github.com/frkr-io/frkr-proto/go/ingest/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var now = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+var bounds = Bounds{MaxAge: 24 * time.Hour, MaxAhead: 5 * time.Minute}
+
+func validRequest() *capture.IngestRequest {
+	return &capture.IngestRequest{
+		StreamID: "orders",
+		Request: &capture.Request{MirroredRequest: &ingestv1.MirroredRequest{
+			Method:      "POST",
+			Path:        "/orders?id=1",
+			Headers:     map[string]string{"Content-Type": "application/json"},
+			TimestampNs: now.UnixNano(),
+			RequestId:   "req-1",
+		}},
+	}
+}
+
+func fields(err error) []string {
+	var names []string
+	if verr, ok := err.(*Error); ok {
+		for _, f := range verr.Fields {
+			names = append(names, f.Field)
+		}
+	}
+	return names
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*capture.IngestRequest)
+		fields []string
+	}{
+		{"valid", func(*capture.IngestRequest) {}, nil},
+		{"no timestamp or request id", func(r *capture.IngestRequest) {
+			r.Request.TimestampNs = 0
+			r.Request.RequestId = ""
+		}, nil},
+		{"asterisk path", func(r *capture.IngestRequest) { r.Request.Method, r.Request.Path = "OPTIONS", "*" }, nil},
+		{"missing stream", func(r *capture.IngestRequest) { r.StreamID = "" }, []string{"stream_id"}},
+		{"missing request", func(r *capture.IngestRequest) { r.Request = nil }, []string{"request"}},
+		{"empty method and path", func(r *capture.IngestRequest) {
+			r.Request.Method, r.Request.Path = "", ""
+		}, []string{"request.method", "request.path"}},
+		{"invalid method", func(r *capture.IngestRequest) { r.Request.Method = "GET /" }, []string{"request.method"}},
+		{"relative path", func(r *capture.IngestRequest) { r.Request.Path = "orders" }, []string{"request.path"}},
+		{"path with space", func(r *capture.IngestRequest) { r.Request.Path = "/a b" }, []string{"request.path"}},
+		{"invalid header name", func(r *capture.IngestRequest) {
+			r.Request.Headers["Bad Name"] = "v"
+		}, []string{"request.headers[Bad Name]"}},
+		{"header value with newline", func(r *capture.IngestRequest) {
+			r.Request.Headers["X-Injected"] = "a\r\nb: c"
+		}, []string{"request.headers[X-Injected]"}},
+		{"timestamp too old", func(r *capture.IngestRequest) {
+			r.Request.TimestampNs = now.Add(-25 * time.Hour).UnixNano()
+		}, []string{"request.timestamp_ns"}},
+		{"imported timestamp older than max age", func(r *capture.IngestRequest) {
+			r.Request.TimestampNs = now.Add(-25 * time.Hour).UnixNano()
+			r.Request.Imported = true
+		}, nil},
+		{"imported timestamp too far ahead", func(r *capture.IngestRequest) {
+			r.Request.TimestampNs = now.Add(time.Hour).UnixNano()
+			r.Request.Imported = true
+		}, []string{"request.timestamp_ns"}},
+		{"timestamp too far ahead", func(r *capture.IngestRequest) {
+			r.Request.TimestampNs = now.Add(time.Hour).UnixNano()
+		}, []string{"request.timestamp_ns"}},
+		{"negative timestamp", func(r *capture.IngestRequest) { r.Request.TimestampNs = -1 }, []string{"request.timestamp_ns"}},
+		{"request id with space", func(r *capture.IngestRequest) { r.Request.RequestId = "a b" }, []string{"request.request_id"}},
+		{"negative sequence", func(r *capture.IngestRequest) { r.Request.Sequence = -1 }, []string{"request.sequence"}},
+		{"unknown body encoding", func(r *capture.IngestRequest) { r.Request.BodyEncoding = "hex" }, []string{"request.body_encoding"}},
+		{"invalid base64 body", func(r *capture.IngestRequest) {
+			r.Request.BodyEncoding, r.Request.Body = capture.BodyEncodingBase64, "!!"
+		}, []string{"request.body"}},
+		{"invalid response", func(r *capture.IngestRequest) {
+			r.Request.Response = &capture.Response{Status: 42}
+		}, []string{"request.response"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := validRequest()
+			tt.modify(req)
+			err := Validate(req, now, bounds)
+			if tt.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Equal(t, tt.fields, fields(err))
+		})
+	}
+}
+
+func TestValidate_NoBounds(t *testing.T) {
+	req := validRequest()
+	req.Request.TimestampNs = now.Add(-365 * 24 * time.Hour).UnixNano()
+	assert.NoError(t, Validate(req, now, Bounds{}))
+}
+
+func TestError(t *testing.T) {
+	err := Validate(&capture.IngestRequest{}, now, bounds)
+	assert.EqualError(t, err, "stream_id: is required; request: is required")
+}